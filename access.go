@@ -0,0 +1,189 @@
+package filecache
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// accessed records the item access time and increments the item reads counter in the item file header (see
+// WithAccessTracking and WithHitCounting). Access time is updated at most once per the tracking precision, and file
+// modification time is preserved (so it still means "last writing"). Errors are ignored, because both values are
+// advisory.
+func (item *Item) accessed(f *file.File) {
+	var (
+		precision = item.pool.accessPrecision
+		counting  = item.pool.hitCounting
+		now       = item.pool.now()
+		touch     bool
+	)
+
+	if precision > 0 {
+		at, err := f.GetAccessedAt()
+		touch = err == nil && now.Sub(at) >= precision
+	}
+
+	if !touch && !counting {
+		return
+	}
+
+	var filePath = item.GetFilePath()
+
+	info, err := item.pool.backend.Stat(filePath)
+	if err != nil {
+		return
+	}
+
+	w, err := item.open()
+	if err != nil {
+		return
+	}
+
+	var setErr error
+
+	if touch {
+		setErr = w.SetAccessedAt(now)
+	}
+
+	if counting && setErr == nil {
+		if hits, hitsErr := w.GetHits(); hitsErr == nil && hits < math.MaxUint32 {
+			setErr = w.SetHits(hits + 1)
+		}
+	}
+
+	if err := w.Close(); err == nil && setErr == nil {
+		if tc, ok := item.pool.backend.(timesChanger); ok {
+			_ = tc.Chtimes(filePath, now, info.ModTime())
+		}
+	}
+}
+
+// Hits returns the number of successful reads of this cache item (see WithHitCounting). Zero will be returned, if
+// reads are not counted or counter cannot be read.
+func (item *Item) Hits() uint32 {
+	if !item.pool.acquire() {
+		return 0
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var hits uint32
+
+	_ = item.view(func(f *file.File) (err error) {
+		hits, err = f.GetHits()
+		return
+	})
+
+	return hits
+}
+
+// AccessedAt returns the last access time of this cache item (see WithAccessTracking). If access time was not
+// recorded - nil will be returned. Idle time can be calculated as the difference between the current time and the
+// returned one.
+func (item *Item) AccessedAt() *time.Time {
+	if !item.pool.acquire() {
+		return nil
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var at time.Time
+
+	if err := item.view(func(f *file.File) (err error) {
+		at, err = f.GetAccessedAt()
+		return
+	}); err != nil || at.IsZero() {
+		return nil
+	}
+
+	return &at
+}
+
+// lastUsed returns the latest of the item file modification and access times (access time is read from the file
+// header, if access tracking is enabled).
+func (pool *Pool) lastUsed(f *file.File, modTime time.Time) time.Time {
+	if pool.accessPrecision <= 0 {
+		return modTime
+	}
+
+	if at, err := f.GetAccessedAt(); err == nil && at.After(modTime) {
+		return at
+	}
+
+	return modTime
+}
+
+// HotKey is the cache item key with the reads counter (see Pool.HotKeys).
+type HotKey struct {
+	Key  string
+	Hits uint32
+}
+
+// HotKeys returns up to n most frequently read cache items keys (see WithHitCounting), ordered by the reads counter
+// (zero or negative n means "all keys"). Expired and invalidated items, items without reads, and items, written before
+// the keys persisting, are skipped. Item files are opened for the counters reading.
+func (pool *Pool) HotKeys(n int) ([]HotKey, error) {
+	if !pool.acquire() {
+		return nil, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		hot   = make([]HotKey, 0)
+		mu    sync.Mutex
+		epoch = pool.currentEpoch()
+	)
+
+	if err := pool.walkOverCacheFiles(context.Background(), func(filePath string) {
+		if !isItemFileName(filepath.Base(filePath)) || pool.fileOutdated(filePath, pool.now(), epoch) {
+			return
+		}
+
+		f, err := pool.openFile(filePath, os.O_RDONLY, 0)
+		if err != nil {
+			return
+		}
+		defer func(f *file.File) { _ = f.Close() }(f)
+
+		if matched, _ := f.SignatureMatched(); !matched {
+			return
+		}
+
+		hits, err := f.GetHits()
+		if err != nil || hits == 0 {
+			return
+		}
+
+		if key, err := f.GetKey(); err == nil && key != "" {
+			mu.Lock()
+			hot = append(hot, HotKey{Key: key, Hits: hits})
+			mu.Unlock()
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Hits != hot[j].Hits {
+			return hot[i].Hits > hot[j].Hits
+		}
+
+		return hot[i].Key < hot[j].Key
+	})
+
+	if n > 0 && len(hot) > n {
+		hot = hot[:n]
+	}
+
+	return hot, nil
+}
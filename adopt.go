@@ -0,0 +1,137 @@
+package filecache
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// AdoptMode defines the source file handling by Pool.Adopt.
+type AdoptMode uint8
+
+const (
+	// AdoptCopy copies the source file content into the cache item, and the source file is left untouched.
+	AdoptCopy AdoptMode = iota
+
+	// AdoptMove moves the source file into the cache: for the pools with split layout (see WithSplitLayout) the source
+	// file is renamed into the item raw data file, so the content is not copied, and it is copied and removed after
+	// that otherwise (and when the file cannot be renamed, e.g. it is located on another filesystem).
+	AdoptMove
+)
+
+// Adopt places the existing plain file (e.g. pre-existing build artifact) under the cache management: file content is
+// stored into the cache item with expiring time (zero means "without expiring time"). Source file is copied or moved
+// into the cache according to the mode (see AdoptMode), and it is left untouched on writing errors. Path is resolved
+// on the operating system filesystem (not on the pool backend, see WithBackend).
+func (pool *Pool) Adopt(key string, path string, expires time.Time, mode AdoptMode) (CacheItem, error) {
+	if mode == AdoptMove && pool.splitLayout && pool.onOS() {
+		if item, moved, err := pool.adoptRenaming(newItem(pool, key), path, file.Header{ExpiresAt: expires}); moved {
+			return item, err
+		}
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", path), err)
+	}
+
+	item, err := pool.put(key, src, file.Header{ExpiresAt: expires})
+
+	_ = src.Close() // opened files cannot be removed on some platforms
+
+	if err != nil || mode != AdoptMove {
+		return item, err
+	}
+
+	if err = os.Remove(path); err != nil {
+		return item, newError(ErrUnknown, fmt.Sprintf("adopted file [%s] cannot be removed", path), err)
+	}
+
+	return item, nil
+}
+
+// adoptRenaming renames the source file into the item raw data file and writes the item file with the data
+// descriptor. Flag "moved" is false, when the source file cannot be renamed (it is not changed in this case, and it
+// must be copied).
+func (pool *Pool) adoptRenaming(item *Item, path string, h file.Header) (_ CacheItem, moved bool, _ error) {
+	if !pool.acquire() {
+		return nil, true, errPoolClosed()
+	}
+	defer pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if err := item.mutable(); err != nil {
+		return item, true, err
+	}
+
+	if _, err := item.relayout(); err != nil {
+		return item, true, err
+	}
+
+	h = pool.jitter.header(pool.now(), h)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, true, newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", path), err)
+	}
+
+	if limit := pool.maxValueSize; limit > 0 && info.Size() > limit {
+		return item, true, item.onOversize(&h.ExpiresAt, false)
+	}
+
+	var tmpPath = dataTempPath(item.GetFilePath())
+
+	if err = os.Rename(path, tmpPath); err != nil {
+		return nil, false, nil
+	}
+
+	d, err := adoptedData(tmpPath)
+	if err == nil {
+		if err = pool.chmod(tmpPath, item.filePerm()); err == nil {
+			err = pool.chown(tmpPath)
+		}
+	}
+
+	if err != nil {
+		_ = os.Rename(tmpPath, path)
+
+		return item, true, writeError(fmt.Sprintf("cannot write into file [%s]", tmpPath), err)
+	}
+
+	if pool.deduplicated() {
+		pool.dedupData(tmpPath, int64(binary.LittleEndian.Uint64(d)), d[8:])
+	}
+
+	if err = item.publishData(tmpPath, d, h); err != nil {
+		_ = os.Rename(tmpPath, path)
+
+		return item, true, err
+	}
+
+	return item, true, nil
+}
+
+// adoptedData hashes the adopted raw data file and returns the encoded data descriptor.
+func adoptedData(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	hashing := sha1.New() //nolint:gosec
+
+	n, err := io.Copy(hashing, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeDataDescriptor(n, hashing.Sum(nil)), nil
+}
@@ -0,0 +1,130 @@
+package filecache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Append appends the data to the end of the cache item value (item is created, if it does not exist). Existing value
+// is read for the hash sum calculation (and verification), but not rewritten (for chunked values - see
+// WithChunkedWrites option - existing data is not read). Invalidated item value is replaced. Immutable items (see
+// Pool.PutImmutable) cannot be appended.
+// Value size limit (see WithMaxValueSize) is applied to the whole value.
+func (item *Item) Append(from io.Reader) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if err := item.mutable(); err != nil {
+		return err
+	}
+
+	if err := item.pool.ensureFreeSpace(); err != nil {
+		return err
+	}
+
+	var filePath = item.GetFilePath()
+
+	f, err := item.openOrCreateFile(filePath, item.filePerm())
+	if err != nil {
+		return err
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if isDetached(f) { // raw data file is never changed in place (it can be published using hard links)
+		return newError(ErrNotSupported, fmt.Sprintf("file [%s] data is detached (cannot be appended)", filePath), nil)
+	}
+
+	if item.fileInvalidated(f) {
+		if err := f.SetData(bytes.NewReader(nil)); err != nil {
+			return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
+		}
+
+		if err := f.SetEpoch(item.pool.currentEpoch()); err != nil {
+			return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
+		}
+	}
+
+	if flags, flagsErr := f.GetFlags(); flagsErr == nil && flags&transformFlags != 0 {
+		return newError(ErrUnknown, fmt.Sprintf("file [%s] data is transformed and cannot be appended", filePath), nil)
+	}
+
+	var limit = item.pool.maxValueSize
+
+	counter := &countingReader{r: from}
+
+	if limit > 0 {
+		size, sizeErr := f.DataSize()
+		if sizeErr != nil {
+			return newError(ErrFileReading, fmt.Sprintf("file [%s] read error", filePath), sizeErr)
+		}
+
+		counter.r, counter.n = io.LimitReader(from, limit-size+1), size // +1 byte for exceeding detection
+	}
+
+	if _, err := f.AppendData(counter); err != nil {
+		if errors.Is(err, file.ErrDataCorrupted) {
+			_ = f.Close() // file must be closed before removing
+
+			return item.onCorruption(newError(ErrCorrupted, fmt.Sprintf("file [%s] data is corrupted", filePath), err))
+		}
+
+		return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
+	}
+
+	if limit > 0 && counter.n > limit {
+		_ = f.Close()
+
+		return item.onOversize(nil, true)
+	}
+
+	if err := item.setVersion(f); err != nil {
+		return err
+	}
+
+	item.pool.fileChanged(item.key, filePath)
+
+	return nil
+}
+
+// Resume prepares interrupted chunked value writing resuming: incomplete (and corrupted) chunks are removed, and the
+// size of the valid data is returned, so the rest of the value can be written using Append. Values must be written in
+// chunked data format (see WithChunkedWrites option).
+func (item *Item) Resume() (int64, error) {
+	if !item.pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var filePath = item.GetFilePath()
+
+	f, openErr := item.open()
+	if openErr != nil {
+		return 0, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if !f.IsChunked() {
+		return 0, newError(ErrUnknown, fmt.Sprintf("file [%s] data is not chunked", filePath), nil)
+	}
+
+	size, err := f.RecoverChunks()
+	if err != nil {
+		return 0, writeError(fmt.Sprintf("cannot recover file [%s]", filePath), err)
+	}
+
+	item.pool.fileChanged(item.key, filePath)
+
+	return size, nil
+}
@@ -0,0 +1,248 @@
+package filecache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+type (
+	// Backend is the storage of the pool files (item files, index and epoch files). All the pool file operations are
+	// made using the backend, so the pool can work over in-memory, afero or remote filesystems (see WithBackend).
+	// Errors for missing files must satisfy os.IsNotExist (e.g. *os.PathError with os.ErrNotExist error).
+	// Implementations must be safe for concurrent usage.
+	Backend interface {
+		file.FS // files opening
+
+		// Stat returns the named file info.
+		Stat(name string) (os.FileInfo, error)
+
+		// Remove removes the named file.
+		Remove(name string) error
+
+		// Rename renames (moves) the file, replacing the existing target file.
+		Rename(oldPath, newPath string) error
+
+		// List returns the names of the directory entries (in any order).
+		List(dirPath string) ([]string, error)
+
+		// MkdirAll creates the directory with all necessary parents, like os.MkdirAll.
+		MkdirAll(path string, perm os.FileMode) error
+	}
+
+	// timesChanger is implemented by the backends, that allow files modification time changing (it is optional).
+	timesChanger interface {
+		Chtimes(name string, atime, mtime time.Time) error
+	}
+
+	// modeChanger is implemented by the backends, that allow files permissions changing (it is optional, see
+	// WithChmodOnRewrite).
+	modeChanger interface {
+		Chmod(name string, mode os.FileMode) error
+	}
+
+	// ownerChanger is implemented by the backends, that allow files owner changing (it is optional, see WithOwner).
+	ownerChanger interface {
+		Chown(name string, uid, gid int) error
+	}
+
+	// spaceReporter is implemented by the backends, that report the storage total and available space in bytes (it is
+	// optional, see WithMinFreeSpace).
+	spaceReporter interface {
+		DiskSpace(path string) (total, available uint64, err error)
+	}
+
+	// osBackend is the Backend implementation over the operating system filesystem.
+	osBackend struct{}
+)
+
+// OpenFile opens the file using os.OpenFile.
+func (osBackend) OpenFile(name string, flag int, perm os.FileMode) (file.Handle, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err // typed nil must not be returned
+	}
+
+	return f, nil
+}
+
+// Stat returns the file info using os.Stat.
+func (osBackend) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+// Remove removes the file using os.Remove.
+func (osBackend) Remove(name string) error { return os.Remove(name) }
+
+// Rename renames the file using os.Rename.
+func (osBackend) Rename(oldPath, newPath string) error { return os.Rename(oldPath, newPath) }
+
+// List reads the directory entry names.
+func (osBackend) List(dirPath string) ([]string, error) {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := dir.Readdirnames(-1)
+	_ = dir.Close()
+
+	return names, err
+}
+
+// MkdirAll creates the directory using os.MkdirAll.
+func (osBackend) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+// Chtimes changes the file access and modification times using os.Chtimes.
+func (osBackend) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Chmod changes the file permissions using os.Chmod.
+func (osBackend) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+
+// Chown changes the file owner and group using os.Chown.
+func (osBackend) Chown(name string, uid, gid int) error { return os.Chown(name, uid, gid) }
+
+// DiskSpace returns the filesystem total and available space.
+func (osBackend) DiskSpace(path string) (uint64, uint64, error) { return diskSpace(path) }
+
+// onOS reports whether the pool files are stored on the operating system filesystem (files cloning is available for
+// such pools only).
+func (pool *Pool) onOS() bool {
+	_, ok := pool.backend.(osBackend)
+
+	return ok
+}
+
+// openFile opens the cache item file with the header and entry completeness verification (see file.Open).
+func (pool *Pool) openFile(path string, flag int, perm os.FileMode) (*file.File, error) {
+	h, err := pool.openHandle(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.FromHandle(h, pool.signature)
+}
+
+// writeEntry creates or overwrites the cache item file and writes the whole entry into it (chunked data format is
+// used for positive chunk size, see file.WriteChunkedFile). Permissions of the existing file are changed, when it is
+// enabled (see WithChmodOnRewrite). Entry creation time is set to the current time, when it is not set (rewrites keep
+// the original creation time).
+func (pool *Pool) writeEntry(path string, perm os.FileMode, h file.Header, in io.Reader, chunkSize int) error {
+	var err error
+
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = pool.now()
+	}
+
+	if chunkSize > 0 {
+		err = file.WriteChunkedFileFS(poolFS{pool}, path, perm, pool.signature, h, in, chunkSize)
+	} else {
+		err = file.WriteFileFS(poolFS{pool}, path, perm, pool.signature, h, in)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = pool.chmod(path, perm); err != nil {
+		return err
+	}
+
+	return pool.chown(path)
+}
+
+// chmod sets the file permissions, if permissions changing is enabled (and supported by the backend).
+func (pool *Pool) chmod(path string, perm os.FileMode) error {
+	if !pool.chmodOnRewrite {
+		return nil
+	}
+
+	if mc, ok := pool.backend.(modeChanger); ok {
+		return mc.Chmod(path, perm)
+	}
+
+	return nil
+}
+
+// chown sets the file (or directory) owner and group, if they are set (see WithOwner) and the backend supports it.
+func (pool *Pool) chown(path string) error {
+	if pool.uid < 0 && pool.gid < 0 {
+		return nil
+	}
+
+	if oc, ok := pool.backend.(ownerChanger); ok {
+		return oc.Chown(path, pool.uid, pool.gid)
+	}
+
+	return nil
+}
+
+// readFile reads the whole file content.
+func (pool *Pool) readFile(path string) ([]byte, error) {
+	f, err := pool.backend.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(io.NewSectionReader(f, 0, info.Size()))
+}
+
+// writeFileAtomic writes the data into the temporary file and renames it to the target path.
+func (pool *Pool) writeFileAtomic(tmpPath, path string, data []byte) error {
+	f, err := pool.backend.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, pool.filePerm)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.WriteAt(data, 0); err == nil {
+		if s, ok := f.(interface{ Sync() error }); ok {
+			err = s.Sync()
+		}
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = pool.chown(tmpPath)
+	}
+
+	if err == nil {
+		err = pool.rename(tmpPath, path)
+	}
+
+	if err != nil {
+		_ = pool.backend.Remove(tmpPath)
+	}
+
+	return err
+}
+
+// appendTo writes the data at the end of the file (files, opened with os.O_APPEND flag, are written using io.Writer
+// interface, so appending is atomic for the operating system files).
+func appendTo(f file.Handle, data []byte) error {
+	if w, ok := f.(io.Writer); ok {
+		_, err := w.Write(data)
+
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteAt(data, info.Size())
+
+	return err
+}
@@ -0,0 +1,220 @@
+// Package benchmark provides the reusable cache pool benchmarks suite: scenarios cover small and large payloads, warm
+// and cold reads, writes, concurrent mixes and directory sizes. Scenarios are measured by the time-based harness (see
+// Run, RunAll and cmd/filecache-bench), so performance-sensitive changes can be evaluated with the same workloads.
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+// Payload sizes.
+const (
+	SmallPayload = 1024        // 1 KiB
+	LargePayload = 1024 * 1024 // 1 MiB
+)
+
+// Scenario is the benchmark scenario. Op is called with a fresh pool over the temporary directory, and it must reset
+// the benchmark timer after the preparation.
+type Scenario struct {
+	Name string
+	Op   func(b *B, pool *filecache.Pool)
+}
+
+// Scenarios returns all benchmark scenarios.
+func Scenarios() []Scenario {
+	return []Scenario{
+		{Name: "put/small", Op: put(SmallPayload)},
+		{Name: "put/large", Op: put(LargePayload)},
+		{Name: "set/small", Op: set(SmallPayload)},
+		{Name: "set/large", Op: set(LargePayload)},
+		{Name: "get/warm/small", Op: getWarm(SmallPayload)},
+		{Name: "get/warm/large", Op: getWarm(LargePayload)},
+		{Name: "get/cold/small", Op: getCold(SmallPayload)},
+		{Name: "get/cold/large", Op: getCold(LargePayload)},
+		{Name: "mix/parallel/small", Op: mixParallel(SmallPayload, 1000)},
+		{Name: "dir/1000/get", Op: getInDirectory(SmallPayload, 1000)},
+		{Name: "dir/10000/get", Op: getInDirectory(SmallPayload, 10000)},
+	}
+}
+
+// Run measures the scenario: it is run with increasing number of iterations, until it takes at least passed duration
+// (like "go test -bench" does). Every run uses a fresh pool with passed options.
+func Run(s Scenario, d time.Duration, opts ...filecache.Option) (Result, error) {
+	return measure(d, func(b *B) { run(b, s, opts...) })
+}
+
+// run runs the scenario with a fresh pool over the temporary directory.
+func run(b *B, s Scenario, opts ...filecache.Option) {
+	dir, err := ioutil.TempDir("", "filecache-bench-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	pool := filecache.NewPool(dir, opts...)
+	defer func() { _ = pool.Close() }()
+
+	s.Op(b, pool)
+	b.StopTimer() // temporary directory removing is not measured
+}
+
+// RunAll measures scenarios with names, matched with the filter (nil means "all scenarios"), and writes results into
+// the writer (see Run). Failed scenarios are reported, and the error is returned after running of all scenarios.
+func RunAll(w io.Writer, filter *regexp.Regexp, d time.Duration, opts ...filecache.Option) error {
+	var failed int
+
+	for _, s := range Scenarios() {
+		if filter != nil && !filter.MatchString(s.Name) {
+			continue
+		}
+
+		result, err := Run(s, d, opts...)
+		if err != nil {
+			failed++
+
+			_, _ = fmt.Fprintf(w, "%-24s FAIL: %v\n", s.Name, err)
+
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "%-24s %s %s\n", s.Name, result.String(), result.MemString())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d scenario(s) failed", failed)
+	}
+
+	return nil
+}
+
+// payload returns the payload of passed size.
+func payload(size int) []byte { return bytes.Repeat([]byte{'x'}, size) }
+
+// fill writes n items with keys "0".."n-1".
+func fill(b *B, pool *filecache.Pool, n int, data []byte) {
+	for i := 0; i < n; i++ {
+		if _, err := pool.PutForever(strconv.Itoa(i), bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// put writes new items.
+func put(size int) func(b *B, pool *filecache.Pool) {
+	return func(b *B, pool *filecache.Pool) {
+		data := payload(size)
+
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := pool.PutForever(strconv.Itoa(i), bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// set overwrites the same item.
+func set(size int) func(b *B, pool *filecache.Pool) {
+	return func(b *B, pool *filecache.Pool) {
+		data := payload(size)
+		item := pool.GetItem("key")
+
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := item.Set(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// getWarm reads the same item (file is in the OS page cache).
+func getWarm(size int) func(b *B, pool *filecache.Pool) {
+	return func(b *B, pool *filecache.Pool) {
+		fill(b, pool, 1, payload(size))
+
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := pool.GetItem("0").Get(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// getCold reads every item once. OS page cache can not be dropped from here, so "cold" means "every item is read for
+// the first time" (files are not opened and hashed before).
+func getCold(size int) func(b *B, pool *filecache.Pool) {
+	return func(b *B, pool *filecache.Pool) {
+		fill(b, pool, b.N, payload(size))
+
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := pool.GetItem(strconv.Itoa(i)).Get(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// mixParallel runs concurrent mix of reads (80%) and writes (20%) over the keys.
+func mixParallel(size, keys int) func(b *B, pool *filecache.Pool) {
+	return func(b *B, pool *filecache.Pool) {
+		data := payload(size)
+		fill(b, pool, keys, data)
+
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+
+		b.RunParallel(func(pb *PB) {
+			for i := 0; pb.Next(); i++ {
+				key := strconv.Itoa(i % keys)
+
+				if i%5 == 0 {
+					_, _ = pool.PutForever(key, bytes.NewReader(data))
+				} else {
+					_ = pool.GetItem(key).Get(ioutil.Discard)
+				}
+			}
+		})
+	}
+}
+
+// getInDirectory checks and reads items in the directory with passed number of files.
+func getInDirectory(size, files int) func(b *B, pool *filecache.Pool) {
+	return func(b *B, pool *filecache.Pool) {
+		fill(b, pool, files, payload(size))
+
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			key := strconv.Itoa(i % files)
+
+			if !pool.HasItem(key) {
+				b.Fatalf("item %s was not found", key)
+			}
+
+			if err := pool.GetItem(key).Get(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package benchmark
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// B is the scenario run state (it mimics testing.B, so the package does not depend on the testing package outside
+	// of tests). Scenario must run the operation N times.
+	B struct {
+		N int
+
+		bytes   int64
+		start   time.Time
+		elapsed time.Duration
+		running bool
+
+		startAllocs, allocs uint64
+		startBytes, mem     uint64
+	}
+
+	// PB is used by B.RunParallel for running parallel operations.
+	PB struct {
+		next *int64
+		n    int64
+	}
+
+	// Result is the scenario run result.
+	Result struct {
+		N         int           // number of iterations
+		T         time.Duration // total time taken
+		Bytes     int64         // bytes processed in one iteration
+		MemAllocs uint64        // total number of memory allocations
+		MemBytes  uint64        // total number of bytes allocated
+	}
+
+	// fatalError is the B.Fatal argument.
+	fatalError struct{ error }
+)
+
+// SetBytes records the number of bytes processed in a single operation.
+func (b *B) SetBytes(n int64) { b.bytes = n }
+
+// ResetTimer zeroes the elapsed time and memory allocation counters (and does not stop the timer).
+func (b *B) ResetTimer() {
+	if b.running {
+		var ms runtime.MemStats
+
+		runtime.ReadMemStats(&ms)
+		b.start, b.startAllocs, b.startBytes = time.Now(), ms.Mallocs, ms.TotalAlloc
+	}
+
+	b.elapsed, b.allocs, b.mem = 0, 0, 0
+}
+
+// StartTimer starts timing an operation (it is called automatically before the scenario running).
+func (b *B) StartTimer() {
+	if !b.running {
+		var ms runtime.MemStats
+
+		runtime.ReadMemStats(&ms)
+		b.start, b.startAllocs, b.startBytes, b.running = time.Now(), ms.Mallocs, ms.TotalAlloc, true
+	}
+}
+
+// StopTimer stops timing an operation (it is called automatically after the scenario running).
+func (b *B) StopTimer() {
+	if b.running {
+		b.elapsed += time.Since(b.start)
+
+		var ms runtime.MemStats
+
+		runtime.ReadMemStats(&ms)
+		b.allocs, b.mem, b.running = b.allocs+ms.Mallocs-b.startAllocs, b.mem+ms.TotalAlloc-b.startBytes, false
+	}
+}
+
+// Fatal stops the scenario running with the error, formatted like fmt.Sprint does. It must be called from the
+// scenario goroutine only.
+func (b *B) Fatal(args ...interface{}) { panic(fatalError{errors.New(fmt.Sprint(args...))}) }
+
+// Fatalf stops the scenario running with the error, formatted like fmt.Sprintf does (see Fatal).
+func (b *B) Fatalf(format string, args ...interface{}) {
+	panic(fatalError{fmt.Errorf(format, args...)})
+}
+
+// RunParallel runs the body in GOMAXPROCS goroutines, which share N iterations (see PB.Next).
+func (b *B) RunParallel(body func(*PB)) {
+	var (
+		next int64
+		wg   sync.WaitGroup
+	)
+
+	for i := runtime.GOMAXPROCS(0); i > 0; i-- {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			body(&PB{next: &next, n: int64(b.N)})
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Next reports whether there are more iterations to execute.
+func (pb *PB) Next() bool { return atomic.AddInt64(pb.next, 1) <= pb.n }
+
+// NsPerOp returns the "ns/op" metric.
+func (r Result) NsPerOp() int64 {
+	if r.N <= 0 {
+		return 0
+	}
+
+	return r.T.Nanoseconds() / int64(r.N)
+}
+
+// String returns the result summary (like testing.BenchmarkResult does).
+func (r Result) String() string {
+	s := fmt.Sprintf("%8d\t%10d ns/op", r.N, r.NsPerOp())
+
+	if r.Bytes > 0 && r.T > 0 {
+		s += fmt.Sprintf("\t%7.2f MB/s", float64(r.Bytes)*float64(r.N)/1e6/r.T.Seconds())
+	}
+
+	return s
+}
+
+// MemString returns the memory allocations summary (like testing.BenchmarkResult does).
+func (r Result) MemString() string {
+	if r.N <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%8d B/op\t%8d allocs/op", r.MemBytes/uint64(r.N), r.MemAllocs/uint64(r.N))
+}
+
+// runN runs the operation with passed number of iterations.
+func runN(n int, op func(b *B)) (res Result, err error) {
+	var b = B{N: n}
+
+	defer func() {
+		b.StopTimer()
+
+		if r := recover(); r != nil {
+			f, ok := r.(fatalError)
+			if !ok {
+				panic(r)
+			}
+
+			err = f.error
+		}
+
+		res = Result{N: b.N, T: b.elapsed, Bytes: b.bytes, MemAllocs: b.allocs, MemBytes: b.mem}
+	}()
+
+	runtime.GC()
+	b.StartTimer()
+	op(&b)
+
+	return res, nil
+}
+
+// measure runs the operation with increasing number of iterations, until it takes at least passed duration (like
+// "go test -bench" does).
+func measure(d time.Duration, op func(b *B)) (Result, error) {
+	const maxN = 1e9
+
+	res, err := runN(1, op)
+
+	for err == nil && res.T < d && res.N < maxN {
+		var (
+			prev = int64(res.N)
+			n    = int64(maxN)
+		)
+
+		if ns := res.T.Nanoseconds(); ns > 0 {
+			n = d.Nanoseconds() * prev / ns
+		}
+
+		n += n / 5 // run more iterations, than predicted
+
+		if n > 100*prev {
+			n = 100 * prev
+		}
+
+		if n <= prev {
+			n = prev + 1
+		}
+
+		if n > maxN {
+			n = maxN
+		}
+
+		res, err = runN(int(n), op)
+	}
+
+	return res, err
+}
@@ -0,0 +1,72 @@
+package benchmark
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMeasure(t *testing.T) {
+	const d = 20 * time.Millisecond
+
+	res, err := measure(d, func(b *B) {
+		b.SetBytes(10)
+		time.Sleep(time.Millisecond) // preparation is not measured
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			time.Sleep(time.Microsecond * 100)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.N <= 1 || res.T < d || res.Bytes != 10 {
+		t.Errorf("want more than 1 iteration, at least %v and 10 bytes, got %+v", d, res)
+	}
+}
+
+func TestMeasureFatal(t *testing.T) {
+	var runs int
+
+	_, err := measure(time.Second, func(b *B) {
+		runs++
+
+		b.Fatalf("failed on %d", b.N)
+	})
+
+	if err == nil || err.Error() != "failed on 1" || runs != 1 {
+		t.Errorf("want the first run error, got %v after %d runs", err, runs)
+	}
+}
+
+func TestMeasurePanic(t *testing.T) {
+	var errPanic = errors.New("panic")
+
+	defer func() {
+		if r := recover(); r == nil || !errors.Is(r.(error), errPanic) {
+			t.Errorf("want panic to be propagated, got %v", r)
+		}
+	}()
+
+	_, _ = measure(time.Second, func(b *B) { panic(errPanic) })
+}
+
+func TestRunParallel(t *testing.T) {
+	var (
+		b     = B{N: 1000}
+		count int64
+	)
+
+	b.RunParallel(func(pb *PB) {
+		for pb.Next() {
+			atomic.AddInt64(&count, 1)
+		}
+	})
+
+	if count != 1000 {
+		t.Errorf("want 1000 iterations, got %d", count)
+	}
+}
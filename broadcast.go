@@ -0,0 +1,182 @@
+package filecache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type (
+	// EventKind is the kind of the cache pool event.
+	EventKind uint8
+
+	// Event is the cache pool event, published for other nodes (pools, that share no filesystem).
+	Event struct {
+		Kind   EventKind `json:"kind"`
+		Key    string    `json:"key,omitempty"` // empty for EventClear
+		Origin string    `json:"origin"`        // publisher pool ID (pool events are not applied to itself)
+	}
+
+	// Broadcaster delivers cache pool events between nodes. Implementations must be safe for concurrent usage.
+	Broadcaster interface {
+		// Publish sends the event to all subscribers (including the publisher itself).
+		Publish(e Event) error
+
+		// Subscribe registers the events handler. Returned function cancels the subscription.
+		Subscribe(fn func(e Event)) (unsubscribe func(), err error)
+	}
+
+	// PubSub is the messages transport for the broadcaster (e.g. Redis pub/sub). For the go-redis client Publish
+	// should call `client.Publish(ctx, channel, message)`, and Subscribe should start the goroutine that reads
+	// `client.Subscribe(ctx, channel).Channel()` messages and passes their payloads to fn.
+	PubSub interface {
+		// Publish sends the message to the channel.
+		Publish(channel string, message []byte) error
+
+		// Subscribe registers the channel messages handler. Returned function cancels the subscription.
+		Subscribe(channel string, fn func(message []byte)) (unsubscribe func(), err error)
+	}
+
+	// localBroadcaster is in-process Broadcaster implementation (events are delivered synchronously).
+	localBroadcaster struct {
+		mu       sync.RWMutex
+		next     uint64
+		handlers map[uint64]func(Event)
+	}
+
+	// pubSubBroadcaster is Broadcaster implementation over the messages transport (events are JSON-encoded).
+	pubSubBroadcaster struct {
+		ps      PubSub
+		channel string
+	}
+)
+
+const (
+	EventDelete EventKind = iota + 1 // cache item was deleted
+	EventClear                       // cache pool was cleared
+)
+
+// NewLocalBroadcaster creates in-process broadcaster (for the pools in the same process, e.g. over different
+// directories).
+func NewLocalBroadcaster() Broadcaster {
+	return &localBroadcaster{handlers: make(map[uint64]func(Event))}
+}
+
+// Publish calls all registered handlers.
+func (b *localBroadcaster) Publish(e Event) error {
+	b.mu.RLock()
+	handlers := make([]func(Event), 0, len(b.handlers))
+
+	for _, fn := range b.handlers {
+		handlers = append(handlers, fn)
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(e)
+	}
+
+	return nil
+}
+
+// Subscribe registers the events handler.
+func (b *localBroadcaster) Subscribe(fn func(e Event)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.handlers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}, nil
+}
+
+// NewPubSubBroadcaster creates the broadcaster over the messages transport channel.
+func NewPubSubBroadcaster(ps PubSub, channel string) Broadcaster {
+	return &pubSubBroadcaster{ps: ps, channel: channel}
+}
+
+// Publish encodes and sends the event to the channel.
+func (b *pubSubBroadcaster) Publish(e Event) error {
+	message, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return b.ps.Publish(b.channel, message)
+}
+
+// Subscribe registers the events handler (malformed messages are skipped).
+func (b *pubSubBroadcaster) Subscribe(fn func(e Event)) (func(), error) {
+	return b.ps.Subscribe(b.channel, func(message []byte) {
+		var e Event
+
+		if err := json.Unmarshal(message, &e); err == nil {
+			fn(e)
+		}
+	})
+}
+
+// newNodeID generates random pool ID for the broadcasted events.
+func newNodeID() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[:])
+
+	return hex.EncodeToString(b[:])
+}
+
+// subscribe registers the pool events handler (subscription error disables events receiving, but not publishing).
+func (pool *Pool) subscribe() {
+	if pool.broadcaster == nil {
+		return
+	}
+
+	unsubscribe, err := pool.broadcaster.Subscribe(pool.onEvent)
+	if err != nil {
+		pool.reportError(newError(ErrUnknown, "cannot subscribe to the pool events", err))
+
+		return
+	}
+
+	pool.unsubscribe = unsubscribe
+}
+
+// onEvent applies the event, published by another node (without re-publishing).
+func (pool *Pool) onEvent(e Event) {
+	if e.Origin == pool.nodeID {
+		return
+	}
+
+	switch e.Kind {
+	case EventDelete:
+		if _, err := pool.deleteItem(e.Key); err != nil && !os.IsNotExist(err) {
+			pool.reportError(writeError(fmt.Sprintf("cannot remove item [%s] file", e.Key), err))
+		}
+
+	case EventClear:
+		_, err := pool.clear(context.Background(), nil)
+		pool.reportError(err)
+	}
+}
+
+// publish sends the event to other nodes. Broadcaster is nil-safe.
+func (pool *Pool) publish(kind EventKind, key string) error {
+	if pool.broadcaster == nil {
+		return nil
+	}
+
+	if err := pool.broadcaster.Publish(Event{Kind: kind, Key: key, Origin: pool.nodeID}); err != nil {
+		return newError(ErrUnknown, fmt.Sprintf("cannot publish the event for the key [%s]", key), err)
+	}
+
+	return nil
+}
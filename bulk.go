@@ -0,0 +1,132 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// ItemInfo contains the cache item attributes for bulk operations (see DeleteWhere).
+type ItemInfo struct {
+	Size       int64      // value size in bytes
+	ExpiresAt  time.Time  // zero value means "without expiring time"
+	ModTime    time.Time  // item file modification time
+	AccessedAt time.Time  // last access time (zero value, if it was not recorded - see WithAccessTracking)
+	Hits       uint32     // number of reads (see WithHitCounting)
+	Priority   uint8      // eviction priority (see Pool.PutWithPriority)
+	Flags      file.Flags // item flags
+	Tags       []string   // nil, if metadata store is not configured (see WithMetadataStore)
+}
+
+// DeleteWhere removes the cache items, for which the predicate returns true, and returns the number of removed items.
+// Predicate receives the item key (empty for items, written before the keys persisting - see Keys) and attributes,
+// and it is called concurrently (see WithWalkConcurrency option). EventDelete is published for every removed item with
+// known key (see WithBroadcaster option).
+func (pool *Pool) DeleteWhere(fn func(key string, info ItemInfo) bool) (int, error) {
+	return pool.DeleteWhereContext(context.Background(), fn)
+}
+
+// DeleteWhereContext is DeleteWhere with the context (removing is stopped when the context is canceled).
+func (pool *Pool) DeleteWhereContext(ctx context.Context, fn func(key string, info ItemInfo) bool) (int, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		removed int
+		lastErr error
+		mu      sync.Mutex
+	)
+
+	err := pool.walkOverCacheFiles(ctx, func(path string) {
+		var name = filepath.Base(path)
+
+		if !isItemFileName(name) {
+			return
+		}
+
+		lock := pool.itemLock(name)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		key, info, ok := pool.readItemInfo(path)
+		if !ok || !fn(key, info) {
+			return
+		}
+
+		rmErr := pool.removeFile(path)
+
+		var pubErr error
+
+		if rmErr == nil && key != "" {
+			pubErr = pool.publish(EventDelete, key)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if rmErr == nil {
+			removed++
+		} else if !os.IsNotExist(rmErr) {
+			lastErr = rmErr
+		}
+
+		if pubErr != nil {
+			lastErr = pubErr
+		}
+	})
+
+	if err != nil {
+		return removed, err
+	}
+
+	return removed, lastErr
+}
+
+// readItemInfo reads the key and attributes of the cache item file (false is returned for unreadable and foreign
+// files).
+func (pool *Pool) readItemInfo(path string) (string, ItemInfo, bool) {
+	f, err := pool.openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", ItemInfo{}, false
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	e, err := pool.fileIndexEntry(f, path)
+	if err != nil {
+		return "", ItemInfo{}, false
+	}
+
+	key, err := f.GetKey()
+	if err != nil {
+		return "", ItemInfo{}, false
+	}
+
+	info := ItemInfo{Size: e.Size, ExpiresAt: e.ExpiresAt, ModTime: e.ModTime, Flags: e.Flags}
+
+	if at, atErr := f.GetAccessedAt(); atErr == nil {
+		info.AccessedAt = at
+	}
+
+	if hits, hitsErr := f.GetHits(); hitsErr == nil {
+		info.Hits = hits
+	}
+
+	if priority, prErr := f.GetPriority(); prErr == nil {
+		info.Priority = priority
+	}
+
+	if pool.metadata != nil {
+		if m, exists, mErr := pool.metadata.store.Get(filepath.Base(path)); mErr == nil && exists {
+			info.Tags = m.Tags
+		}
+	}
+
+	return key, info, true
+}
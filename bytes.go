@@ -0,0 +1,52 @@
+package filecache
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// GetBytes returns the cache item value. Missing (or expired) item is not an error - false will be returned.
+func (pool *Pool) GetBytes(key string) ([]byte, bool, error) {
+	item := pool.GetItem(key)
+	if !item.IsHit() {
+		return nil, false, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := item.Get(buf); err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrExpired) {
+			return nil, false, nil // item was removed (or expired) after the hit checking
+		}
+
+		return nil, false, err
+	}
+
+	return buf.Bytes(), true, nil
+}
+
+// GetString returns the cache item value as a string (see GetBytes).
+func (pool *Pool) GetString(key string) (string, bool, error) {
+	data, found, err := pool.GetBytes(key)
+
+	return string(data), found, err
+}
+
+// SetBytes puts the cache item with passed value. Zero (or negative) ttl means "without expiring time".
+func (pool *Pool) SetBytes(key string, value []byte, ttl time.Duration) error {
+	var err error
+
+	if ttl > 0 {
+		_, err = pool.Put(key, bytes.NewReader(value), pool.now().Add(ttl))
+	} else {
+		_, err = pool.PutForever(key, bytes.NewReader(value))
+	}
+
+	return err
+}
+
+// SetString puts the cache item with passed string value (see SetBytes).
+func (pool *Pool) SetString(key, value string, ttl time.Duration) error {
+	return pool.SetBytes(key, []byte(value), ttl)
+}
@@ -0,0 +1,62 @@
+package filecache
+
+import (
+	"context"
+	"time"
+)
+
+// ExpiredCleanup defines when expired (and invalidated) cache items are removed (see WithExpiredCleanup).
+type ExpiredCleanup uint8
+
+const (
+	// ExpiredCleanupOnAccess removes expired items on access (Pool.GetItem, Pool.HasItem) - default policy.
+	ExpiredCleanupOnAccess ExpiredCleanup = iota
+
+	// ExpiredCleanupBackground removes expired items periodically in the background (see WithCleanupInterval), so
+	// reading does not cause files removing.
+	ExpiredCleanupBackground
+
+	// ExpiredCleanupManual never removes expired items automatically (Pool.Prune must be called).
+	ExpiredCleanupManual
+)
+
+// defaultCleanupInterval is the default interval of the background expired items cleanup.
+const defaultCleanupInterval = time.Minute
+
+// startCleanup starts the background expired items cleanup worker (if it is required by the cleanup policy).
+func (pool *Pool) startCleanup() {
+	if pool.expiredCleanup != ExpiredCleanupBackground || pool.cleanupInterval <= 0 {
+		return
+	}
+
+	pool.workers.Add(1)
+
+	go func() {
+		defer pool.workers.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ticker := time.NewTicker(pool.cleanupInterval)
+		defer ticker.Stop()
+
+		go func() {
+			select {
+			case <-pool.done: // in-flight pruning must be canceled on pool closing
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		for {
+			select {
+			case <-pool.done:
+				return
+
+			case <-ticker.C:
+				_, err := pool.PruneContext(ctx)
+				pool.reportError(err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,19 @@
+package filecache
+
+import "time"
+
+// Clock is the time source for the expiration logic (see WithClock). Implementations must be safe for concurrent
+// usage.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// systemClock is the Clock implementation, that uses the system time.
+type systemClock struct{}
+
+// Now returns the current system time.
+func (systemClock) Now() time.Time { return time.Now() }
+
+// now returns the current time of the pool clock.
+func (pool *Pool) now() time.Time { return pool.clock.Now() }
@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package filecache
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request number (_IOW(0x94, 9, int)).
+const ficlone = 0x40049409
+
+// cloneFile makes the dst file a copy-on-write clone (reflink) of the src file. Error is returned, if filesystem does
+// not support reflinks (or files are located on different filesystems).
+func cloneFile(dst, src *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd()); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package filecache
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile is not supported on this platform (files are copied by streaming).
+func cloneFile(_, _ *os.File) error { return errors.New("file cloning is not supported") }
@@ -0,0 +1,52 @@
+// Command filecache-bench runs the cache pool benchmarks suite (see benchmark package) and prints results, so
+// performance-sensitive changes can be compared with the same workloads.
+//
+// Usage example:
+//
+//	filecache-bench -run 'get/' -benchtime 3s -chunk-size 65536
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+	"github.com/tarampampam/go-filecache/benchmark"
+)
+
+func main() {
+	var (
+		run       = flag.String("run", "", "regular expression for the scenarios names filtering")
+		benchtime = flag.Duration("benchtime", time.Second, "minimal duration of every scenario measuring")
+		chunkSize = flag.Int("chunk-size", 0, "chunked data format chunk size (zero means \"regular data format\")")
+		mmap      = flag.Int64("mmap-threshold", 0, "memory mapped reads threshold in bytes (zero means \"disabled\")")
+		index     = flag.Bool("index", false, "enable the pool index")
+		handles   = flag.Int("handles", 0, "read handles cache size (zero means \"disabled\")")
+	)
+
+	flag.Parse()
+
+	var filter *regexp.Regexp
+
+	if *run != "" {
+		var err error
+
+		if filter, err = regexp.Compile(*run); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := benchmark.RunAll(os.Stdout, filter, *benchtime,
+		filecache.WithChunkedWrites(*chunkSize),
+		filecache.WithMmapReads(*mmap),
+		filecache.WithIndex(*index),
+		filecache.WithHandleCache(*handles, 0),
+	); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
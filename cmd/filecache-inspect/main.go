@@ -0,0 +1,86 @@
+// Command filecache-inspect describes the cache files (signature, header, format version, expiration time, flags and
+// data size) without the data reading (see file.Inspect), so broken and foreign files can be debugged.
+//
+// Usage example:
+//
+//	filecache-inspect /tmp/cache/*.cache
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+func main() {
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <cache file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var failed bool
+
+	for _, name := range flag.Args() {
+		info, err := file.Inspect(name)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+
+			continue
+		}
+
+		describe(name, info)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// describe writes the file description to the standard output.
+func describe(name string, info file.Info) {
+	var h = info.Header
+
+	fmt.Printf("%s:\n", name)
+	fmt.Printf("  size:            %d\n", info.Size)
+	fmt.Printf("  signature:       %x (valid: %t)\n", []byte(info.Signature), info.SignatureValid)
+
+	if info.HeaderValid {
+		fmt.Printf("  header:          valid\n")
+	} else {
+		fmt.Printf("  header:          invalid (%v)\n", info.HeaderError)
+	}
+
+	fmt.Printf("  complete:        %t\n", info.Complete)
+	fmt.Printf("  format version:  %d\n", info.Format.Version)
+	fmt.Printf("  checksum:        %s\n", info.Format.Checksum)
+	fmt.Printf("  flags:           %s\n", h.Flags)
+	fmt.Printf("  key:             %q\n", h.Key)
+	fmt.Printf("  expires at:      %s\n", formatTime(h.ExpiresAt))
+	fmt.Printf("  version:         %d\n", h.Version)
+	fmt.Printf("  data offset:     %d\n", info.DataOffset)
+	fmt.Printf("  data size:       %d\n", info.DataSize)
+
+	if info.Format.Chunked {
+		fmt.Printf("  chunks:          %d\n", info.Chunks)
+	}
+}
+
+// formatTime formats the header time (zero time means "not set").
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "not set"
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
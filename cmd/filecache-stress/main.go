@@ -0,0 +1,410 @@
+// Command filecache-stress drives configurable mixed workloads against a cache directory and reports throughput,
+// latency percentiles and post-run verification results.
+//
+// Usage example:
+//
+//	filecache-stress -dir /tmp/cache -keys 10000 -concurrency 16 -processes 4 -duration 1m
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// maxSamples limits the number of latency samples, stored per operation and worker (reservoir sampling is used).
+const maxSamples = 100000
+
+// Operation names.
+const (
+	opGet    = "get"
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+type config struct {
+	dir          string
+	keys         int
+	minSize      int
+	maxSize      int
+	minTTL       time.Duration
+	maxTTL       time.Duration
+	foreverRatio float64
+	readRatio    float64
+	deleteRatio  float64
+	concurrency  int
+	processes    int
+	duration     time.Duration
+	seed         int64
+	worker       bool
+}
+
+type (
+	// opStats contains statistics for a single operation type.
+	opStats struct {
+		Count     int64   `json:"count"`
+		Errors    int64   `json:"errors"`
+		Latencies []int64 `json:"latencies"` // sampled latencies in microseconds
+		seen      int64   // all latencies count (for reservoir sampling)
+	}
+
+	// report contains workload results (it is passed from the worker processes as JSON).
+	report struct {
+		Ops        map[string]*opStats `json:"ops"`
+		Misses     int64               `json:"misses"`
+		Mismatches int64               `json:"mismatches"` // read values, that belong to another key
+		Elapsed    time.Duration       `json:"elapsed"`
+	}
+
+	// verification contains post-run directory verification results.
+	verification struct {
+		Files     int
+		Valid     int
+		Corrupted int
+		Foreign   int // files without cache signature
+	}
+)
+
+func main() {
+	cfg := config{}
+
+	flag.StringVar(&cfg.dir, "dir", "", "cache directory (temporary directory will be used if empty)")
+	flag.IntVar(&cfg.keys, "keys", 1000, "number of distinct keys")
+	flag.IntVar(&cfg.minSize, "min-size", 64, "minimal value size in bytes")
+	flag.IntVar(&cfg.maxSize, "max-size", 64*1024, "maximal value size in bytes")
+	flag.DurationVar(&cfg.minTTL, "min-ttl", time.Second, "minimal entry TTL")
+	flag.DurationVar(&cfg.maxTTL, "max-ttl", time.Minute, "maximal entry TTL")
+	flag.Float64Var(&cfg.foreverRatio, "forever-ratio", 0.1, "ratio of puts without expiration time")
+	flag.Float64Var(&cfg.readRatio, "read-ratio", 0.8, "ratio of get operations")
+	flag.Float64Var(&cfg.deleteRatio, "delete-ratio", 0.05, "ratio of delete operations")
+	flag.IntVar(&cfg.concurrency, "concurrency", 8, "number of goroutines per process")
+	flag.IntVar(&cfg.processes, "processes", 1, "number of processes, sharing the cache directory")
+	flag.DurationVar(&cfg.duration, "duration", time.Second*10, "workload duration")
+	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "random generator seed")
+	flag.BoolVar(&cfg.worker, "worker", false, "run as a worker process (internal usage)")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config) error {
+	if cfg.keys < 1 || cfg.concurrency < 1 || cfg.processes < 1 || cfg.minSize < 0 || cfg.maxSize < cfg.minSize {
+		return fmt.Errorf("wrong workload configuration")
+	}
+
+	if cfg.worker {
+		return json.NewEncoder(os.Stdout).Encode(workload(cfg))
+	}
+
+	if cfg.dir == "" {
+		dir, err := ioutil.TempDir("", "filecache-stress-")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		cfg.dir = dir
+	}
+
+	var result *report
+
+	if cfg.processes == 1 {
+		result = workload(cfg)
+	} else {
+		var err error
+		if result, err = spawnWorkers(cfg); err != nil {
+			return err
+		}
+	}
+
+	v, err := verify(cfg.dir)
+	if err != nil {
+		return err
+	}
+
+	printReport(result, v)
+
+	if v.Corrupted > 0 || result.Mismatches > 0 {
+		return fmt.Errorf("consistency check failed")
+	}
+
+	return nil
+}
+
+// spawnWorkers starts worker processes (current executable with "-worker" flag) and merges their reports.
+func spawnWorkers(cfg config) (*report, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		lastErr error
+		merged  = newReport()
+	)
+
+	for i := 0; i < cfg.processes; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			args := append(append([]string{}, os.Args[1:]...),
+				"-worker", "-dir", cfg.dir, "-seed", strconv.FormatInt(cfg.seed+int64(i), 10),
+			)
+
+			out, err := exec.Command(os.Args[0], args...).Output() //nolint:gosec
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				lastErr = fmt.Errorf("worker process %d: %w", i, err)
+				return
+			}
+
+			r := newReport()
+			if err := json.Unmarshal(out, r); err != nil {
+				lastErr = fmt.Errorf("worker process %d: %w", i, err)
+				return
+			}
+
+			merged.merge(r)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return merged, lastErr
+}
+
+// workload runs the mixed workload in the current process.
+func workload(cfg config) *report {
+	var (
+		pool    = filecache.NewPool(cfg.dir)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		result  = newReport()
+		started = time.Now()
+		stopAt  = started.Add(cfg.duration)
+	)
+
+	defer func() { _ = pool.Close() }()
+
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+
+		go func(rnd *rand.Rand) {
+			defer wg.Done()
+
+			local := newReport()
+
+			for time.Now().Before(stopAt) {
+				operation(cfg, pool, rnd, local)
+			}
+
+			mu.Lock()
+			result.merge(local)
+			mu.Unlock()
+		}(rand.New(rand.NewSource(cfg.seed + int64(i)))) //nolint:gosec
+	}
+
+	wg.Wait()
+
+	result.Elapsed = time.Since(started)
+
+	return result
+}
+
+// operation executes a single random operation and records its result.
+func operation(cfg config, pool *filecache.Pool, rnd *rand.Rand, r *report) {
+	var (
+		key   = "key-" + strconv.Itoa(rnd.Intn(cfg.keys))
+		dice  = rnd.Float64()
+		name  string
+		err   error
+		start = time.Now()
+	)
+
+	switch {
+	case dice < cfg.readRatio:
+		name = opGet
+
+		item := pool.GetItem(key)
+		if !item.IsHit() {
+			r.Misses++
+			break
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err = item.Get(buf); err == nil && !bytes.HasPrefix(buf.Bytes(), []byte(key+"\x00")) {
+			r.Mismatches++
+		} else if errors.Is(err, filecache.ErrNotFound) || errors.Is(err, filecache.ErrExpired) {
+			r.Misses++ // item was removed (or expired) after the hit checking
+			err = nil
+		}
+
+	case dice < cfg.readRatio+cfg.deleteRatio:
+		name = opDelete
+
+		if _, err = pool.DeleteItem(key); os.IsNotExist(err) {
+			err = nil
+		}
+
+	default:
+		name = opPut
+		value := payload(rnd, key, cfg.minSize+rnd.Intn(cfg.maxSize-cfg.minSize+1))
+
+		if rnd.Float64() < cfg.foreverRatio {
+			_, err = pool.PutForever(key, bytes.NewReader(value))
+		} else {
+			ttl := cfg.minTTL + time.Duration(rnd.Int63n(int64(cfg.maxTTL-cfg.minTTL)+1))
+			_, err = pool.Put(key, bytes.NewReader(value), time.Now().Add(ttl))
+		}
+	}
+
+	r.record(rnd, name, time.Since(start), err)
+}
+
+// payload generates a value, prefixed with the key (so values, read by another key, can be detected).
+func payload(rnd *rand.Rand, key string, size int) []byte {
+	value := make([]byte, len(key)+1+size)
+	copy(value, key+"\x00")
+	_, _ = rnd.Read(value[len(key)+1:])
+
+	return value
+}
+
+// verify checks all cache files in the directory (signature and data hash sum).
+func verify(dir string) (verification, error) {
+	var v verification
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return v, err
+	}
+
+	for _, info := range files {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		f, openErr := file.OpenRead(filepath.Join(dir, info.Name()), filecache.DefaultItemFileSignature)
+		if errors.Is(openErr, file.ErrIncompleteEntry) { // there are no writes after the run
+			v.Files++
+			v.Corrupted++
+
+			continue
+		}
+
+		if openErr != nil {
+			continue // file was removed
+		}
+
+		v.Files++
+
+		if matched, _ := f.SignatureMatched(); !matched {
+			v.Foreign++
+		} else if err := f.GetData(ioutil.Discard); err != nil {
+			v.Corrupted++
+		} else {
+			v.Valid++
+		}
+
+		_ = f.Close()
+	}
+
+	return v, nil
+}
+
+func newReport() *report { return &report{Ops: make(map[string]*opStats)} }
+
+// record stores the operation result.
+func (r *report) record(rnd *rand.Rand, name string, latency time.Duration, err error) {
+	s, ok := r.Ops[name]
+	if !ok {
+		s = &opStats{}
+		r.Ops[name] = s
+	}
+
+	s.Count++
+	if err != nil {
+		s.Errors++
+	}
+
+	s.seen++
+	if us := int64(latency / time.Microsecond); len(s.Latencies) < maxSamples {
+		s.Latencies = append(s.Latencies, us)
+	} else if j := rnd.Int63n(s.seen); j < maxSamples {
+		s.Latencies[j] = us
+	}
+}
+
+// merge adds another report results into the current.
+func (r *report) merge(another *report) {
+	for name, s := range another.Ops {
+		if _, ok := r.Ops[name]; !ok {
+			r.Ops[name] = &opStats{}
+		}
+
+		r.Ops[name].Count += s.Count
+		r.Ops[name].Errors += s.Errors
+		r.Ops[name].Latencies = append(r.Ops[name].Latencies, s.Latencies...)
+	}
+
+	r.Misses += another.Misses
+	r.Mismatches += another.Mismatches
+
+	if another.Elapsed > r.Elapsed {
+		r.Elapsed = another.Elapsed
+	}
+}
+
+func printReport(r *report, v verification) {
+	names := make([]string, 0, len(r.Ops))
+	for name := range r.Ops {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Printf("elapsed: %s, misses: %d, mismatches: %d\n", r.Elapsed.Round(time.Millisecond), r.Misses, r.Mismatches)
+
+	for _, name := range names {
+		s := r.Ops[name]
+		sort.Slice(s.Latencies, func(i, j int) bool { return s.Latencies[i] < s.Latencies[j] })
+
+		fmt.Printf("%-6s count: %d, errors: %d, ops/s: %.0f, p50: %s, p90: %s, p99: %s, max: %s\n",
+			name, s.Count, s.Errors, float64(s.Count)/r.Elapsed.Seconds(),
+			percentile(s.Latencies, 0.5), percentile(s.Latencies, 0.9), percentile(s.Latencies, 0.99),
+			percentile(s.Latencies, 1),
+		)
+	}
+
+	fmt.Printf("verify: files: %d, valid: %d, corrupted: %d, foreign: %d\n", v.Files, v.Valid, v.Corrupted, v.Foreign)
+}
+
+// percentile returns the latency percentile for sorted (in microseconds) latencies.
+func percentile(sorted []int64, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+
+	return time.Duration(sorted[i]) * time.Microsecond
+}
@@ -0,0 +1,149 @@
+package filecache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+type (
+	// Codec encodes and decodes cache item values. Codec content type is stored in the item header, so values can be
+	// decoded only by the codec with the same content type (values of unknown content type are decoded by any codec).
+	Codec interface {
+		// ContentType returns the content type marker of encoded values.
+		ContentType() file.ContentType
+
+		// Marshal writes encoded v into w.
+		Marshal(w io.Writer, v interface{}) error
+
+		// Unmarshal reads encoded value from r into v (pointer is expected).
+		Unmarshal(r io.Reader, v interface{}) error
+	}
+
+	// JSONCodec encodes values using encoding/json package.
+	JSONCodec struct{}
+
+	// GobCodec encodes values using encoding/gob package.
+	GobCodec struct{}
+
+	// MsgpackCodec encodes values using the MessagePack format. Structs are encoded as maps with field names (name can
+	// be changed using the `msgpack:"name,omitempty"` field tag, "-" name skips the field), time.Time values are
+	// encoded using the timestamp extension type. Values are decoded into interface{} like encoding/json package does
+	// (integers are decoded as int64, floats as float64, maps with string keys as map[string]interface{}).
+	MsgpackCodec struct{}
+)
+
+// ContentType returns the content type marker of encoded values.
+func (JSONCodec) ContentType() file.ContentType { return file.ContentTypeJSON }
+
+// Marshal writes JSON encoded v into w.
+func (JSONCodec) Marshal(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+// Unmarshal reads JSON encoded value from r into v.
+func (JSONCodec) Unmarshal(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+// ContentType returns the content type marker of encoded values.
+func (GobCodec) ContentType() file.ContentType { return file.ContentTypeGob }
+
+// Marshal writes gob encoded v into w.
+func (GobCodec) Marshal(w io.Writer, v interface{}) error { return gob.NewEncoder(w).Encode(v) }
+
+// Unmarshal reads gob encoded value from r into v.
+func (GobCodec) Unmarshal(r io.Reader, v interface{}) error { return gob.NewDecoder(r).Decode(v) }
+
+// ContentType returns the content type marker of encoded values.
+func (MsgpackCodec) ContentType() file.ContentType { return file.ContentTypeMsgpack }
+
+// Marshal writes MessagePack encoded v into w.
+func (MsgpackCodec) Marshal(w io.Writer, v interface{}) error {
+	var e msgpackEncoder
+
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+
+	_, err := w.Write(e.buf.Bytes())
+
+	return err
+}
+
+// Unmarshal reads MessagePack encoded value from r into v.
+func (MsgpackCodec) Unmarshal(r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: non-nil pointer is expected, got %T", v)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	d := msgpackDecoder{data: data}
+
+	return d.decode(rv.Elem())
+}
+
+// PutValue puts the cache item with value, encoded by the pool codec (see WithCodec option, default is JSONCodec).
+// Zero (or negative) ttl means "without expiring time".
+func (pool *Pool) PutValue(key string, v interface{}, ttl time.Duration) error {
+	return pool.PutValueWith(pool.codec, key, v, ttl)
+}
+
+// GetValue decodes the cache item value into dst using the pool codec (see GetValueWith).
+func (pool *Pool) GetValue(key string, dst interface{}) (bool, error) {
+	return pool.GetValueWith(pool.codec, key, dst)
+}
+
+// PutValueWith puts the cache item with value, encoded by passed codec (codec content type marker is stored in the
+// header). Zero (or negative) ttl means "without expiring time".
+func (pool *Pool) PutValueWith(codec Codec, key string, v interface{}, ttl time.Duration) error {
+	buf := bytes.NewBuffer(nil)
+
+	if err := codec.Marshal(buf, v); err != nil {
+		return newError(ErrUnknown, fmt.Sprintf("cannot encode value for the key [%s]", key), err)
+	}
+
+	var h = file.Header{ContentType: codec.ContentType()}
+
+	if ttl > 0 {
+		h.ExpiresAt = pool.now().Add(ttl)
+	}
+
+	_, err := pool.put(key, buf, h)
+
+	return err
+}
+
+// GetValueWith decodes the cache item value into dst (pointer is expected) using passed codec. Missing (or expired)
+// item is not an error - false will be returned. Items, stored with another content type, cause ErrContentTypeMismatch
+// error.
+func (pool *Pool) GetValueWith(codec Codec, key string, dst interface{}) (bool, error) {
+	if !pool.GetItem(key).IsHit() {
+		return false, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := newItem(pool, key).getAs(codec.ContentType(), buf); err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrExpired) {
+			return false, nil // item was removed (or expired) after the hit checking
+		}
+
+		return false, err
+	}
+
+	if err := codec.Unmarshal(buf, dst); err != nil {
+		return false, newError(ErrUnknown, fmt.Sprintf("cannot decode value for the key [%s]", key), err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package filecache
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// ETag returns the cache item entity tag - hex-encoded hash sum of the stored value (value is not read).
+func (item *Item) ETag() (string, error) {
+	if !item.pool.acquire() {
+		return "", errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return "", item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	return item.etag(f)
+}
+
+func (item *Item) etag(f *file.File) (string, error) {
+	sum, err := f.ContentHash()
+	if err != nil {
+		return "", newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] hash sum", item.GetFilePath()), err)
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// ModTime returns the cache item file modification time.
+func (item *Item) ModTime() (time.Time, error) {
+	if !item.pool.acquire() {
+		return time.Time{}, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	info, err := item.pool.backend.Stat(item.GetFilePath())
+	if err != nil {
+		return time.Time{}, item.openError(err)
+	}
+
+	return info.ModTime(), nil
+}
+
+// GetIfNoneMatch retrieves the value, if the cache item entity tag (see ETag) does not match passed one (quotes and
+// weak validator prefix "W/" are allowed, so HTTP If-None-Match header value can be passed as is). ErrNotModified error
+// is returned (and the value is not copied) on match.
+func (item *Item) GetIfNoneMatch(to io.Writer, etag string) error {
+	etag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(etag), "W/"), `"`)
+
+	return item.getIf(to, func(f *file.File) (bool, error) {
+		current, err := item.etag(f)
+
+		return current == etag, err
+	})
+}
+
+// GetIfModifiedSince retrieves the value, if the cache item file was modified after passed time (with seconds
+// precision, as in HTTP If-Modified-Since header). ErrNotModified error is returned (and the value is not copied)
+// otherwise.
+func (item *Item) GetIfModifiedSince(to io.Writer, since time.Time) error {
+	return item.getIf(to, func(*file.File) (bool, error) {
+		info, err := item.pool.backend.Stat(item.GetFilePath())
+		if err != nil {
+			return false, item.openError(err)
+		}
+
+		return !info.ModTime().Truncate(time.Second).After(since), nil
+	})
+}
+
+// getIf retrieves the value, if the item is modified (unmodified function returns false).
+func (item *Item) getIf(to io.Writer, unmodified func(f *file.File) (bool, error)) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return item.openError(openErr)
+	}
+
+	if err := item.readable(f); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	same, err := unmodified(f)
+	_ = f.Close()
+
+	if err != nil {
+		return err
+	}
+
+	if same {
+		return newError(ErrNotModified, fmt.Sprintf("file [%s] was not modified", item.GetFilePath()), nil)
+	}
+
+	return item.get(to)
+}
@@ -0,0 +1,342 @@
+package filecache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// CopyTo streams the cache item into another pool (payload is not buffered). Expiration times are copied, and for the
+// destination *Pool custom metadata, content type and flags are copied too (destination pool data format and
+// limits are applied). Copying into the same pool does nothing.
+//
+// Item file is cloned (copy-on-write reflink, without data copying), if the destination *Pool is located on the same
+// filesystem with reflinks support (e.g. Btrfs or XFS on Linux) and uses the same signature and data format. Detached
+// values (e.g. immutable artifacts, see WithSplitLayout) are shared using hard links to the raw data files, if the
+// destination *Pool uses the split layout and it is located on the same filesystem (raw data files are never changed
+// in place, and linked data keeps the source file permissions). Cloned and linked data is not verified (unlike
+// streamed data).
+func (pool *Pool) CopyTo(dst CachePool, key string) error {
+	if dst == CachePool(pool) {
+		return nil
+	}
+
+	if p, ok := dst.(*Pool); ok {
+		if cloned, err := pool.cloneTo(p, key); cloned || err != nil {
+			return err
+		}
+
+		if linked, err := pool.linkDataTo(p, key); linked || err != nil {
+			return err
+		}
+	}
+
+	item := newItem(pool, key)
+
+	if !pool.acquire() {
+		return errPoolClosed()
+	}
+
+	item.mutex.Lock()
+	r, h, err := item.openReader()
+	item.mutex.Unlock()
+	pool.release()
+
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	if p, ok := dst.(*Pool); ok {
+		_, err = p.putHeader(key, r, file.Header{
+			ExpiresAt:   h.ExpiresAt,
+			Flags:       h.Flags.Without(file.FlagChunked),
+			ContentType: h.ContentType,
+			FreshUntil:  h.FreshUntil,
+			Meta:        h.Meta,
+		})
+	} else if h.ExpiresAt.IsZero() {
+		_, err = dst.PutForever(key, r)
+	} else {
+		_, err = dst.Put(key, r, h.ExpiresAt)
+	}
+
+	return err
+}
+
+// MoveTo streams the cache item into another pool (see CopyTo) and removes it from the current pool on success.
+func (pool *Pool) MoveTo(dst CachePool, key string) error {
+	if dst == CachePool(pool) {
+		return nil
+	}
+
+	if err := pool.CopyTo(dst, key); err != nil {
+		return err
+	}
+
+	_, err := pool.DeleteItem(key)
+
+	return err
+}
+
+// cloneTo clones the cache item file into the destination pool. False is returned (without error), if the file cannot
+// be cloned, so it must be streamed.
+func (pool *Pool) cloneTo(dst *Pool, key string) (bool, error) {
+	if !pool.onOS() || !dst.onOS() {
+		return false, nil
+	}
+
+	if !bytes.Equal(effectiveSignature(pool.signature), effectiveSignature(dst.signature)) {
+		return false, nil
+	}
+
+	tmp, err := pool.cloneItemFile(dst, key)
+	if err != nil || tmp == "" {
+		return false, err
+	}
+	defer func() { _ = os.Remove(tmp) }() // removing fails after the successful renaming
+
+	if !dst.acquire() {
+		return false, errPoolClosed()
+	}
+	defer dst.release()
+
+	item := newItem(dst, key)
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if err := item.mutable(); err != nil {
+		return false, err
+	}
+
+	if err := item.stampClone(tmp); err != nil {
+		return false, err
+	}
+
+	if err := dst.rename(tmp, item.GetFilePath()); err != nil {
+		return false, writeError(fmt.Sprintf("cannot write into file [%s]", item.GetFilePath()), err)
+	}
+
+	dst.fileChanged(key, item.GetFilePath())
+
+	return true, nil
+}
+
+// cloneItemFile clones the readable cache item file into the temporary file in the destination pool directory and
+// returns its path (empty path is returned, if the file cannot be cloned).
+func (pool *Pool) cloneItemFile(dst *Pool, key string) (string, error) {
+	if !pool.acquire() {
+		return "", errPoolClosed()
+	}
+	defer pool.release()
+
+	item := newItem(pool, key)
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	src, err := os.Open(item.GetFilePath())
+	if err != nil {
+		return "", item.openError(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	f := file.Shared(src, pool.signature, func() error { return nil })
+
+	for _, verify := range [...]func() error{f.VerifyHeader, f.VerifyComplete} {
+		if err = verify(); err != nil {
+			return "", item.openError(err)
+		}
+	}
+
+	if err = item.readable(f); err != nil {
+		return "", err
+	}
+
+	if f.IsChunked() != (dst.chunkSize > 0) || isDetached(f) {
+		return "", nil // destination data format must be applied
+	}
+
+	if flags, flagsErr := f.GetFlags(); flagsErr != nil || !pool.decodableBy(dst, flags) {
+		return "", nil // value must be decoded and encoded with the destination pool transformers
+	}
+
+	if size, sizeErr := f.DataSize(); dst.maxValueSize > 0 && (sizeErr != nil || size > dst.maxValueSize) {
+		return "", nil // destination limits must be applied
+	}
+
+	tmp, err := ioutil.TempFile(dst.dirPath, ".filecache-*.tmp")
+	if err != nil {
+		return "", nil
+	}
+
+	if err = cloneFile(tmp, src); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return "", nil
+	}
+
+	return tmp.Name(), nil
+}
+
+// linkDataTo publishes the detached cache item value into the destination pool with the split layout as a hard link to
+// the item raw data file. False is returned (without error), if the data cannot be linked (e.g. pools are located on
+// different filesystems), so it must be streamed.
+func (pool *Pool) linkDataTo(dst *Pool, key string) (bool, error) {
+	if !pool.onOS() || !dst.onOS() || !dst.splitLayout {
+		return false, nil
+	}
+
+	tmp, d, h, err := pool.linkItemData(dst, key)
+	if err != nil || tmp == "" {
+		return false, err
+	}
+	defer func() { _ = os.Remove(tmp) }() // removing fails after the successful publishing
+
+	if !dst.acquire() {
+		return false, errPoolClosed()
+	}
+	defer dst.release()
+
+	item := newItem(dst, key)
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if err := item.mutable(); err != nil {
+		return false, err
+	}
+
+	if _, err := item.relayout(); err != nil {
+		return false, err
+	}
+
+	if dst.deduplicated() {
+		dst.dedupData(tmp, int64(binary.LittleEndian.Uint64(d)), d[8:])
+	}
+
+	if err := item.publishData(tmp, d, h); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// linkItemData links the readable detached cache item raw data file into the temporary file in the destination pool
+// directory, and returns its path with the encoded data descriptor and the destination item header values (empty path
+// is returned, if the data cannot be linked).
+func (pool *Pool) linkItemData(dst *Pool, key string) (string, []byte, file.Header, error) {
+	if !pool.acquire() {
+		return "", nil, file.Header{}, errPoolClosed()
+	}
+	defer pool.release()
+
+	item := newItem(pool, key)
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return "", nil, file.Header{}, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if err := item.readable(f); err != nil {
+		return "", nil, file.Header{}, err
+	}
+
+	if !isDetached(f) {
+		return "", nil, file.Header{}, nil
+	}
+
+	h, err := f.GetHeader()
+	if err != nil || !pool.decodableBy(dst, h.Flags) {
+		return "", nil, file.Header{}, nil // value is streamed (and verified)
+	}
+
+	dh, d, err := pool.openDataFile(f)
+	if err != nil {
+		return "", nil, file.Header{}, nil
+	}
+
+	_ = dh.Close()
+
+	if dst.maxValueSize > 0 && d.size > dst.maxValueSize {
+		return "", nil, file.Header{}, nil // destination limits must be applied
+	}
+
+	tmp, err := ioutil.TempFile(dst.dirPath, ".filecache-*.tmp")
+	if err != nil {
+		return "", nil, file.Header{}, nil
+	}
+
+	// temporary file name is reserved only, because hard links cannot replace existing files
+	if err = tmp.Close(); err == nil {
+		if err = os.Remove(tmp.Name()); err == nil {
+			err = os.Link(dataPath(f.Name()), tmp.Name())
+		}
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return "", nil, file.Header{}, nil
+	}
+
+	return tmp.Name(), encodeDataDescriptor(d.size, d.sum), file.Header{
+		ExpiresAt:   h.ExpiresAt,
+		Flags:       h.Flags.Without(file.FlagChunked),
+		ContentType: h.ContentType,
+		FreshUntil:  h.FreshUntil,
+		Meta:        h.Meta,
+	}, nil
+}
+
+// stampClone sets the item epoch and version in the cloned item file, and resets its access statistics.
+func (item *Item) stampClone(path string) error {
+	current, err := item.version()
+	if err != nil {
+		return err
+	}
+
+	f, err := item.pool.openFile(path, os.O_RDWR, item.filePerm())
+	if err != nil {
+		return newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", path), err)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	for _, set := range [...]func() error{
+		func() error { return f.SetEpoch(item.pool.currentEpoch()) },
+		func() error { return f.SetVersion(uint64(item.pool.nextVersion(current))) },
+		func() error { return f.SetAccessedAt(time.Time{}) },
+		func() error { return f.SetHits(0) },
+	} {
+		if err := set(); err != nil {
+			return writeError(fmt.Sprintf("cannot write into file [%s]", path), err)
+		}
+	}
+
+	return nil
+}
+
+// effectiveSignature returns the item files signature (nil signature means file.DefaultSignature).
+func effectiveSignature(signature file.FSignature) file.FSignature {
+	if signature == nil {
+		return file.DefaultSignature
+	}
+
+	return signature
+}
@@ -0,0 +1,72 @@
+package filecache
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Count returns the number of valid (not expired and not invalidated) cache items (files with wrong signature, and
+// incomplete files are skipped). Pool index is used, if it is enabled.
+func (pool *Pool) Count() (int, error) { return pool.count(0) }
+
+// IsEmpty reports whether the pool contains no valid cache items (see Count). Walking over the cache files is stopped
+// on the first found item.
+func (pool *Pool) IsEmpty() (bool, error) {
+	n, err := pool.count(1)
+
+	return n == 0, err
+}
+
+// count counts valid cache items, up to the limit (zero means "without limit").
+func (pool *Pool) count(limit int64) (int, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		now   = pool.now()
+		epoch = pool.currentEpoch()
+		n     int64
+	)
+
+	if pool.index != nil {
+		if entries, err := pool.index.snapshot(); err == nil {
+			for _, e := range entries {
+				if !e.outdated(now, epoch) {
+					if n++; n == limit {
+						break
+					}
+				}
+			}
+
+			return int(n), nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := pool.walkOverCacheFiles(ctx, func(path string) {
+		if !isItemFileName(filepath.Base(path)) {
+			return
+		}
+
+		if e, err := pool.readIndexEntry(path); err == nil && !e.outdated(now, epoch) {
+			if atomic.AddInt64(&n, 1) == limit {
+				cancel() // walking is canceled, when the limit is reached
+			}
+		}
+	})
+
+	if n = atomic.LoadInt64(&n); limit > 0 && n >= limit {
+		return int(limit), nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
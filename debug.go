@@ -0,0 +1,230 @@
+package filecache
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDebugEntriesLimit is the default page size of the debug entries listing (see Pool.DebugHandler).
+const defaultDebugEntriesLimit = 100
+
+type (
+	// debugStats is the debug handler statistics response.
+	debugStats struct {
+		Items int    `json:"items"`
+		Size  int64  `json:"size"`           // total values size in bytes
+		Hits  uint64 `json:"hits,omitempty"` // total reads counter (see WithHitCounting)
+	}
+
+	// debugConfig is the debug handler configuration response.
+	debugConfig struct {
+		DirPath            string  `json:"dir_path"`
+		Index              bool    `json:"index"`
+		MetadataStore      bool    `json:"metadata_store"`
+		HandleCache        int     `json:"handle_cache"` // zero means "disabled"
+		Signature          string  `json:"signature"`
+		AccessPrecision    string  `json:"access_precision"` // zero means "disabled"
+		HitCounting        bool    `json:"hit_counting"`
+		EvictionPolicy     uint8   `json:"eviction_policy"`
+		CorruptionPolicy   uint8   `json:"corruption_policy"`
+		ExpiredReadPolicy  uint8   `json:"expired_read_policy"`
+		ExpiredCleanup     uint8   `json:"expired_cleanup"`
+		CleanupInterval    string  `json:"cleanup_interval"`
+		MmapThreshold      int64   `json:"mmap_threshold"` // zero means "disabled"
+		ChunkSize          int     `json:"chunk_size"`     // zero means "regular data format"
+		SplitLayout        bool    `json:"split_layout"`
+		MaxValueSize       int64   `json:"max_value_size"` // zero means "unlimited"
+		MinFreeSpace       float64 `json:"min_free_space"` // in percents, zero means "do not check"
+		WalkConcurrency    int     `json:"walk_concurrency"`
+		WalkSignatureCheck bool    `json:"walk_signature_check"`
+		StrictSignature    bool    `json:"strict_signature"`
+		TTLJitter          bool    `json:"ttl_jitter"`
+	}
+
+	// debugEntries is the debug handler entries listing response.
+	debugEntries struct {
+		Total   int          `json:"total"`
+		Offset  int          `json:"offset"`
+		Limit   int          `json:"limit"`
+		Entries []debugEntry `json:"entries"`
+	}
+
+	// debugEntry describes the cache item in the debug entries listing.
+	debugEntry struct {
+		Key       string     `json:"key"` // empty for items, written before the keys persisting (see Keys)
+		Size      int64      `json:"size"`
+		Age       string     `json:"age"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		Hits      uint32     `json:"hits,omitempty"`
+	}
+)
+
+// DebugHandler returns the HTTP handler for the runtime cache introspection, that serves JSON responses:
+//
+//	.../stats                  - number of items, total values size and reads counter
+//	.../config                 - pool configuration
+//	.../entries?offset=&limit= - cache items listing, ordered by keys (default limit is 100)
+//
+// Handler is intended to be mounted under the "/debug/filecache/" path (any prefix can be used, because requests are
+// routed by the last path element). Statistics and listing are collected by the cache directory walking, so handler
+// must not be exposed publicly.
+func (pool *Pool) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		var (
+			resp interface{}
+			err  error
+		)
+
+		switch path := strings.TrimSuffix(r.URL.Path, "/"); path[strings.LastIndex(path, "/")+1:] {
+		case "stats":
+			resp, err = pool.debugStats(r)
+
+		case "config":
+			resp = pool.debugConfig()
+
+		case "entries":
+			resp, err = pool.debugEntries(r)
+
+		default:
+			http.NotFound(w, r)
+
+			return
+		}
+
+		if err != nil {
+			status := http.StatusInternalServerError
+
+			if errors.Is(err, ErrPoolClosed) {
+				status = http.StatusServiceUnavailable
+			}
+
+			http.Error(w, err.Error(), status)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(resp)
+	})
+}
+
+// debugStats collects the cache statistics.
+func (pool *Pool) debugStats(r *http.Request) (debugStats, error) {
+	if !pool.acquire() {
+		return debugStats{}, errPoolClosed()
+	}
+	defer pool.release()
+
+	entries, err := pool.reportEntries(r.Context())
+	if err != nil {
+		return debugStats{}, err
+	}
+
+	var stats = debugStats{Items: len(entries)}
+
+	for _, e := range entries {
+		stats.Size += e.Size
+		stats.Hits += uint64(e.Hits)
+	}
+
+	return stats, nil
+}
+
+// debugConfig returns the pool configuration.
+func (pool *Pool) debugConfig() debugConfig {
+	var cfg = debugConfig{
+		DirPath:            pool.dirPath,
+		Index:              pool.indexEnabled,
+		MetadataStore:      pool.metadata != nil,
+		Signature:          string(effectiveSignature(pool.signature)),
+		AccessPrecision:    pool.accessPrecision.String(),
+		HitCounting:        pool.hitCounting,
+		EvictionPolicy:     uint8(pool.evictionPolicy),
+		CorruptionPolicy:   uint8(pool.corruptionPolicy),
+		ExpiredReadPolicy:  uint8(pool.expiredReadPolicy),
+		ExpiredCleanup:     uint8(pool.expiredCleanup),
+		CleanupInterval:    pool.cleanupInterval.String(),
+		MmapThreshold:      pool.mmapThreshold,
+		ChunkSize:          pool.chunkSize,
+		SplitLayout:        pool.splitLayout,
+		MaxValueSize:       pool.maxValueSize,
+		MinFreeSpace:       pool.minFreeSpace,
+		WalkConcurrency:    pool.walkConcurrency,
+		WalkSignatureCheck: pool.walkSignatureCheck,
+		StrictSignature:    pool.strictSignature,
+		TTLJitter:          pool.jitter != nil,
+	}
+
+	if pool.handles != nil {
+		cfg.HandleCache = pool.handles.capacity
+	}
+
+	return cfg
+}
+
+// debugEntries returns the page of cache items listing (offset and limit are read from the request query).
+func (pool *Pool) debugEntries(r *http.Request) (debugEntries, error) {
+	var (
+		query  = r.URL.Query()
+		offset = 0
+		limit  = defaultDebugEntriesLimit
+	)
+
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	if !pool.acquire() {
+		return debugEntries{}, errPoolClosed()
+	}
+	defer pool.release()
+
+	entries, err := pool.reportEntries(r.Context())
+	if err != nil {
+		return debugEntries{}, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	var page = debugEntries{Total: len(entries), Offset: offset, Limit: limit, Entries: make([]debugEntry, 0)}
+
+	if offset < len(entries) {
+		entries = entries[offset:]
+
+		if len(entries) > limit {
+			entries = entries[:limit]
+		}
+
+		for _, e := range entries {
+			de := debugEntry{Key: e.Key, Size: e.Size, Age: e.Age.Round(time.Millisecond).String(), Hits: e.Hits}
+
+			if !e.ExpiresAt.IsZero() {
+				expiresAt := e.ExpiresAt
+				de.ExpiresAt = &expiresAt
+			}
+
+			page.Entries = append(page.Entries, de)
+		}
+	}
+
+	return page, nil
+}
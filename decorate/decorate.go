@@ -0,0 +1,249 @@
+// Package decorate provides the cache pool decorators: cross-cutting behavior (metrics, logging, concurrent reads
+// collapsing) is added by wrapping any filecache.CachePool implementation, so decorators compose without the core pool
+// modifying (e.g. `decorate.WithLogging(decorate.WithSingleflight(pool), logger)`).
+package decorate
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+// Op is the observed operation name.
+type Op string
+
+// Observed operations (pool and item methods).
+const (
+	OpHasItem      Op = "HasItem"
+	OpClear        Op = "Clear"
+	OpDeleteItem   Op = "DeleteItem"
+	OpPut          Op = "Put"
+	OpPutForever   Op = "PutForever"
+	OpClose        Op = "Close"
+	OpGet          Op = "Get" // item value reading
+	OpIsHit        Op = "IsHit"
+	OpSet          Op = "Set" // item value writing
+	OpSetExpiresAt Op = "SetExpiresAt"
+	OpDelete       Op = "Delete" // item deleting
+)
+
+type (
+	// Event is the observed operation.
+	Event struct {
+		Op   Op
+		Key  string        // empty for pool-wide operations (Clear, Close)
+		Took time.Duration // operation duration
+		Hit  bool          // for HasItem, IsHit and Get (missing, expired and invalidated items are misses)
+		Err  error         // nil for misses
+	}
+
+	// Metrics receives the operations metrics (e.g. Prometheus histograms and counters are updated).
+	Metrics interface {
+		// Observe is called after every operation with its duration.
+		Observe(op Op, took time.Duration)
+
+		// Lookup is called after every HasItem, IsHit and Get operation with its result.
+		Lookup(op Op, hit bool)
+
+		// Error is called after every failed operation (misses are not errors).
+		Error(op Op, err error)
+	}
+
+	// Logger writes the log messages (*log.Logger implements it).
+	Logger interface {
+		Printf(format string, v ...interface{})
+	}
+
+	// observedPool wraps the cache pool and calls the observer after every operation.
+	observedPool struct {
+		filecache.CachePool
+
+		observe func(Event)
+	}
+
+	// observedItem wraps the cache item and calls the pool observer after every operation.
+	observedItem struct {
+		filecache.CacheItem
+
+		pool *observedPool
+	}
+)
+
+// Interfaces implementation checks.
+var (
+	_ filecache.CachePool = (*observedPool)(nil)
+	_ filecache.CacheItem = (*observedItem)(nil)
+)
+
+// WithObserver wraps the cache pool, so fn is called after every pool (and returned items) operation. Function must be
+// safe for concurrent calling.
+func WithObserver(pool filecache.CachePool, fn func(Event)) filecache.CachePool {
+	return &observedPool{CachePool: pool, observe: fn}
+}
+
+// WithMetrics wraps the cache pool, so operations durations, lookups results and errors are passed to the metrics.
+func WithMetrics(pool filecache.CachePool, m Metrics) filecache.CachePool {
+	return WithObserver(pool, func(e Event) {
+		m.Observe(e.Op, e.Took)
+
+		switch e.Op {
+		case OpHasItem, OpIsHit, OpGet:
+			if e.Err == nil {
+				m.Lookup(e.Op, e.Hit)
+			}
+		}
+
+		if e.Err != nil {
+			m.Error(e.Op, e.Err)
+		}
+	})
+}
+
+// WithLogging wraps the cache pool, so failed operations are logged (misses are not logged).
+func WithLogging(pool filecache.CachePool, l Logger) filecache.CachePool {
+	return WithObserver(pool, func(e Event) {
+		if e.Err == nil {
+			return
+		}
+
+		if e.Key == "" {
+			l.Printf("filecache: %s failed in %s: %v", e.Op, e.Took, e.Err)
+		} else {
+			l.Printf("filecache: %s [%s] failed in %s: %v", e.Op, e.Key, e.Took, e.Err)
+		}
+	})
+}
+
+// isMiss reports whether the error means the cache miss.
+func isMiss(err error) bool {
+	return errors.Is(err, filecache.ErrNotFound) ||
+		errors.Is(err, filecache.ErrExpired) ||
+		errors.Is(err, filecache.ErrInvalidated)
+}
+
+// done calls the observer with the operation duration.
+func (p *observedPool) done(e Event, start time.Time) {
+	e.Took = time.Since(start)
+	p.observe(e)
+}
+
+// GetItem returns the observed item wrapper (item getting is not observed itself).
+func (p *observedPool) GetItem(key string) filecache.CacheItem {
+	return p.wrap(p.CachePool.GetItem(key))
+}
+
+// HasItem calls the wrapped pool method.
+func (p *observedPool) HasItem(key string) bool {
+	start := time.Now()
+	hit := p.CachePool.HasItem(key)
+	p.done(Event{Op: OpHasItem, Key: key, Hit: hit}, start)
+
+	return hit
+}
+
+// Clear calls the wrapped pool method.
+func (p *observedPool) Clear() (bool, error) {
+	start := time.Now()
+	ok, err := p.CachePool.Clear()
+	p.done(Event{Op: OpClear, Err: err}, start)
+
+	return ok, err
+}
+
+// DeleteItem calls the wrapped pool method.
+func (p *observedPool) DeleteItem(key string) (bool, error) {
+	start := time.Now()
+	ok, err := p.CachePool.DeleteItem(key)
+	p.done(Event{Op: OpDeleteItem, Key: key, Err: err}, start)
+
+	return ok, err
+}
+
+// Put calls the wrapped pool method.
+func (p *observedPool) Put(key string, from io.Reader, expiresAt time.Time) (filecache.CacheItem, error) {
+	start := time.Now()
+	item, err := p.CachePool.Put(key, from, expiresAt)
+	p.done(Event{Op: OpPut, Key: key, Err: err}, start)
+
+	return p.wrap(item), err
+}
+
+// PutForever calls the wrapped pool method.
+func (p *observedPool) PutForever(key string, from io.Reader) (filecache.CacheItem, error) {
+	start := time.Now()
+	item, err := p.CachePool.PutForever(key, from)
+	p.done(Event{Op: OpPutForever, Key: key, Err: err}, start)
+
+	return p.wrap(item), err
+}
+
+// Close calls the wrapped pool method.
+func (p *observedPool) Close() error {
+	start := time.Now()
+	err := p.CachePool.Close()
+	p.done(Event{Op: OpClose, Err: err}, start)
+
+	return err
+}
+
+// wrap wraps the item (nil is not wrapped).
+func (p *observedPool) wrap(item filecache.CacheItem) filecache.CacheItem {
+	if item == nil {
+		return nil
+	}
+
+	return &observedItem{CacheItem: item, pool: p}
+}
+
+// Get calls the wrapped item method (misses are reported without an error, but the error is returned as is).
+func (i *observedItem) Get(to io.Writer) error {
+	start := time.Now()
+	err := i.CacheItem.Get(to)
+
+	e := Event{Op: OpGet, Key: i.GetKey(), Hit: err == nil}
+	if !isMiss(err) {
+		e.Err = err
+	}
+
+	i.pool.done(e, start)
+
+	return err
+}
+
+// IsHit calls the wrapped item method.
+func (i *observedItem) IsHit() bool {
+	start := time.Now()
+	hit := i.CacheItem.IsHit()
+	i.pool.done(Event{Op: OpIsHit, Key: i.GetKey(), Hit: hit}, start)
+
+	return hit
+}
+
+// Set calls the wrapped item method.
+func (i *observedItem) Set(from io.Reader) error {
+	start := time.Now()
+	err := i.CacheItem.Set(from)
+	i.pool.done(Event{Op: OpSet, Key: i.GetKey(), Err: err}, start)
+
+	return err
+}
+
+// SetExpiresAt calls the wrapped item method.
+func (i *observedItem) SetExpiresAt(when time.Time) error {
+	start := time.Now()
+	err := i.CacheItem.SetExpiresAt(when)
+	i.pool.done(Event{Op: OpSetExpiresAt, Key: i.GetKey(), Err: err}, start)
+
+	return err
+}
+
+// Delete calls the wrapped item method.
+func (i *observedItem) Delete() error {
+	start := time.Now()
+	err := i.CacheItem.Delete()
+	i.pool.done(Event{Op: OpDelete, Key: i.GetKey(), Err: err}, start)
+
+	return err
+}
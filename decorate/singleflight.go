@@ -0,0 +1,123 @@
+package decorate
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+type (
+	// flightPool wraps the cache pool and collapses concurrent reads of the same key.
+	flightPool struct {
+		filecache.CachePool
+
+		mu      sync.Mutex
+		flights map[string]*flight // in-flight reads by the item keys
+	}
+
+	// flight is the in-flight item value reading.
+	flight struct {
+		done chan struct{} // closed, when reading is finished
+		data []byte
+		err  error
+	}
+
+	// flightItem wraps the cache item and reads its value using the pool flights.
+	flightItem struct {
+		filecache.CacheItem
+
+		pool *flightPool
+	}
+)
+
+// Interfaces implementation checks.
+var (
+	_ filecache.CachePool = (*flightPool)(nil)
+	_ filecache.CacheItem = (*flightItem)(nil)
+)
+
+// WithSingleflight wraps the cache pool, so concurrent Get calls for the same key share a single value reading (e.g.
+// from the slow network pool): the first caller reads the value into memory, and other callers, that come before the
+// reading is finished, receive the copy of its result (including the error). Values are not streamed, so it should not
+// be used for very large values.
+func WithSingleflight(pool filecache.CachePool) filecache.CachePool {
+	return &flightPool{CachePool: pool, flights: make(map[string]*flight)}
+}
+
+// GetItem returns a Cache Item representing the specified key.
+func (p *flightPool) GetItem(key string) filecache.CacheItem { return p.wrap(p.CachePool.GetItem(key)) }
+
+// Put a cache item with expiring time.
+func (p *flightPool) Put(key string, from io.Reader, expiresAt time.Time) (filecache.CacheItem, error) {
+	item, err := p.CachePool.Put(key, from, expiresAt)
+
+	return p.wrap(item), err
+}
+
+// PutForever puts a cache item without expiring time.
+func (p *flightPool) PutForever(key string, from io.Reader) (filecache.CacheItem, error) {
+	item, err := p.CachePool.PutForever(key, from)
+
+	return p.wrap(item), err
+}
+
+// wrap wraps the item (nil is not wrapped).
+func (p *flightPool) wrap(item filecache.CacheItem) filecache.CacheItem {
+	if item == nil {
+		return nil
+	}
+
+	return &flightItem{CacheItem: item, pool: p}
+}
+
+// do calls the read function, if there is no in-flight reading for the key, or waits for the in-flight reading result.
+func (p *flightPool) do(key string, read func() ([]byte, error)) ([]byte, error) {
+	p.mu.Lock()
+
+	if f, ok := p.flights[key]; ok {
+		p.mu.Unlock()
+		<-f.done
+
+		return f.data, f.err
+	}
+
+	f := &flight{done: make(chan struct{})}
+	p.flights[key] = f
+	p.mu.Unlock()
+
+	defer func() { // waiters must be released even on panic
+		p.mu.Lock()
+		delete(p.flights, key)
+		p.mu.Unlock()
+
+		close(f.done)
+	}()
+
+	f.data, f.err = read()
+
+	return f.data, f.err
+}
+
+// Get retrieves the value of the item, sharing the reading with concurrent callers.
+func (i *flightItem) Get(to io.Writer) error {
+	data, err := i.pool.do(i.GetKey(), func() ([]byte, error) {
+		var buf bytes.Buffer
+
+		if err := i.CacheItem.Get(&buf); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = to.Write(data)
+
+	return err
+}
@@ -0,0 +1,119 @@
+package filecache
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Deduplicated values (see WithDeduplication) are stored once: raw data files of the items with identical values are
+// hard links to the same content file ("<data SHA1 hex>.blob" in the pool directory), so the content file link count
+// is the number of the items, that reference it (plus one for the content file itself). Content files without
+// references are removed on pruning and clearing.
+
+// blobFileExt is the extension of the content files with deduplicated data.
+const blobFileExt = ".blob"
+
+// blobPath returns path to the content file for the data hash sum.
+func (pool *Pool) blobPath(sum []byte) string {
+	return filepath.Join(pool.dirPath, hex.EncodeToString(sum)+blobFileExt)
+}
+
+// deduplicated reports whether the written raw data files are deduplicated.
+func (pool *Pool) deduplicated() bool {
+	return pool.dedup && pool.splitLayout && pool.onOS() && linkCountSupported
+}
+
+// dedupData replaces the written raw data file with the hard link to the content file with the same data, or links it
+// as the new content file. Errors are ignored (the data file is not deduplicated in this case).
+func (pool *Pool) dedupData(path string, size int64, sum []byte) {
+	blob := pool.blobPath(sum)
+
+	if !pool.blobMatched(blob, path, size) {
+		_ = os.Remove(blob) // changed (or broken) content file is replaced
+		_ = os.Link(path, blob)
+
+		return
+	}
+
+	_ = linkFile(blob, path)
+}
+
+// blobMatched reports whether the content file contains the data of the written raw data file. Content file name is
+// the data hash sum, so the content file with the same size is matched without reading; data is compared byte by byte
+// only if the verifying is enabled (see WithDeduplicationVerify).
+func (pool *Pool) blobMatched(blob, path string, size int64) bool {
+	info, err := os.Stat(blob)
+	if err != nil || info.Size() != size {
+		return false
+	}
+
+	if !pool.dedupVerify {
+		return true
+	}
+
+	return filesEqual(blob, path)
+}
+
+// filesEqual reports whether files contents are equal (errors mean "not equal").
+func filesEqual(a, b string) bool {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = fa.Close() }()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = fb.Close() }()
+
+	var bufA, bufB = make([]byte, 32*1024), make([]byte, 32*1024)
+
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false
+		}
+
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == errA
+		}
+
+		if errA != nil || errB != nil {
+			return false
+		}
+	}
+}
+
+// collectBlobs removes the content files without references (raw data files, linked to them).
+func (pool *Pool) collectBlobs() {
+	if !pool.deduplicated() {
+		return
+	}
+
+	names, err := pool.backend.List(pool.dirPath)
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		if !strings.HasSuffix(name, blobFileExt) {
+			continue
+		}
+
+		path := filepath.Join(pool.dirPath, name)
+
+		if info, statErr := os.Stat(path); statErr == nil {
+			if n, ok := linkCount(info); ok && n <= 1 {
+				_ = os.Remove(path)
+			}
+		}
+	}
+}
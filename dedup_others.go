@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package filecache
+
+import "os"
+
+// linkCountSupported reports whether the files link count can be read (see linkCount).
+const linkCountSupported = false
+
+// linkCount returns the number of hard links to the file (it is not supported on this platform).
+func linkCount(os.FileInfo) (uint64, bool) { return 0, false }
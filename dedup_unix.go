@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package filecache
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCountSupported reports whether the files link count can be read (see linkCount).
+const linkCountSupported = true
+
+// linkCount returns the number of hard links to the file.
+func linkCount(info os.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(st.Nlink), true //nolint:unconvert
+}
@@ -0,0 +1,199 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Suffixes for the files, used during deferred items committing.
+const (
+	stagedFileSuffix = ".staged"
+	backupFileSuffix = ".backup"
+)
+
+type (
+	// deferredItem is the cache item value, that will be written on Commit.
+	deferredItem struct {
+		item      *Item
+		data      []byte
+		expiresAt time.Time
+		flags     file.Flags
+	}
+
+	// deferred holds the queue of deferred items.
+	deferred struct {
+		mu    sync.Mutex
+		items []*deferredItem
+		index map[string]int // key to the items slice index
+	}
+)
+
+// SaveDeferred sets a cache item value to be persisted later (on Commit call). Value is read from the reader
+// immediately and held in memory. Zero expiresAt means "without expiring time". Deferred saving of the same key will
+// replace previous deferred value.
+func (pool *Pool) SaveDeferred(item CacheItem, from io.Reader, expiresAt time.Time) (bool, error) {
+	if !pool.acquire() {
+		return false, errPoolClosed()
+	}
+	defer pool.release()
+
+	if pool.maxValueSize > 0 {
+		from = io.LimitReader(from, pool.maxValueSize+1) // +1 byte for exceeding detection
+	}
+
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return false, newError(ErrUnknown, fmt.Sprintf("cannot read value for the key [%s]", item.GetKey()), err)
+	}
+
+	d := &deferredItem{item: newItem(pool, item.GetKey()), data: data, expiresAt: pool.jitter.expiresAt(pool.now(), expiresAt)}
+
+	if pool.maxValueSize > 0 && int64(len(data)) > pool.maxValueSize {
+		if pool.oversizePolicy != OversizeMarker {
+			return false, errTooLarge(item.GetKey(), pool.maxValueSize)
+		}
+
+		d.data, d.flags = nil, file.FlagTooLarge
+	}
+
+	pool.deferred.mu.Lock()
+	defer pool.deferred.mu.Unlock()
+
+	if pool.deferred.index == nil {
+		pool.deferred.index = make(map[string]int)
+	}
+
+	if i, exists := pool.deferred.index[d.item.GetKey()]; exists {
+		pool.deferred.items[i] = d
+	} else {
+		pool.deferred.index[d.item.GetKey()] = len(pool.deferred.items)
+		pool.deferred.items = append(pool.deferred.items, d)
+	}
+
+	return true, nil
+}
+
+// Commit persists all deferred cache items. Items are written into the staged files first (in parallel, see
+// WithCommitConcurrency option), and then staged files replace item files. If any error occurs - all changes are
+// rolled back. The deferred items queue is emptied in any case.
+func (pool *Pool) Commit() (bool, error) {
+	if !pool.acquire() {
+		return false, errPoolClosed()
+	}
+	defer pool.release()
+
+	pool.deferred.mu.Lock()
+	items := pool.deferred.items
+	pool.deferred.items, pool.deferred.index = nil, nil
+	pool.deferred.mu.Unlock()
+
+	if len(items) == 0 {
+		return true, nil
+	}
+
+	if err := pool.ensureFreeSpace(); err != nil {
+		return false, err
+	}
+
+	// stage all items
+	if err := parallel(context.Background(), pool.commitConcurrency, len(items), func(i int) error {
+		d := items[i]
+		return d.item.writeFile(d.item.GetFilePath()+stagedFileSuffix, bytes.NewReader(d.data), file.Header{
+			ExpiresAt: d.expiresAt,
+			Flags:     d.flags,
+		})
+	}); err != nil {
+		for _, d := range items {
+			_ = pool.removeFile(d.item.GetFilePath() + stagedFileSuffix)
+		}
+
+		return false, err
+	}
+
+	// replace item files with staged files (existing files are backed up)
+	for i, d := range items {
+		d.item.mutex.Lock()
+		err := pool.replaceWithStaged(d.item)
+		d.item.mutex.Unlock()
+
+		if err != nil {
+			pool.rollbackCommit(items[:i], items[i:])
+
+			return false, err
+		}
+	}
+
+	for _, d := range items {
+		_ = pool.removeFile(d.item.GetFilePath() + backupFileSuffix)
+
+		pool.metadata.changed(d.item.key, d.item.GetFilePath()) // renaming does not know the key
+	}
+
+	return true, nil
+}
+
+// replaceWithStaged backs up the existing item file (if it exists) and moves the staged file on its place (immutable
+// item files are not replaced).
+func (pool *Pool) replaceWithStaged(item *Item) error {
+	var filePath = item.GetFilePath()
+
+	if err := item.mutable(); err != nil {
+		return err
+	}
+
+	if err := pool.rename(filePath, filePath+backupFileSuffix); err != nil && !os.IsNotExist(err) {
+		return writeError(fmt.Sprintf("cannot backup file [%s]", filePath), err)
+	}
+
+	if err := pool.rename(filePath+stagedFileSuffix, filePath); err != nil {
+		_ = pool.rename(filePath+backupFileSuffix, filePath)
+
+		return writeError(fmt.Sprintf("cannot replace file [%s]", filePath), err)
+	}
+
+	return nil
+}
+
+// rollbackCommit restores backed up files for already replaced items and removes staged files for the rest.
+func (pool *Pool) rollbackCommit(replaced, staged []*deferredItem) {
+	for _, d := range replaced {
+		var filePath = d.item.GetFilePath()
+
+		if err := pool.rename(filePath+backupFileSuffix, filePath); os.IsNotExist(err) {
+			_ = pool.removeFile(filePath) // item did not exist before committing
+		}
+	}
+
+	for _, d := range staged {
+		_ = pool.removeFile(d.item.GetFilePath() + stagedFileSuffix)
+	}
+}
+
+// rename renames (moves) the file, retrying on "sharing violation" errors (index and metadata entries are updated too).
+func (pool *Pool) rename(from, to string) error {
+	pool.handles.forget(from) // opened files cannot be renamed (or replaced) on some platforms
+	pool.handles.forget(to)
+
+	if err := pool.retry(func() error { return pool.backend.Rename(from, to) }); err != nil {
+		return err
+	}
+
+	// detached value raw data file (it is shared by the item files of both layouts, see WithSplitLayout)
+	if isItemFileName(filepath.Base(from)) && isItemFileName(filepath.Base(to)) && dataPath(from) != dataPath(to) {
+		_ = pool.retry(func() error { return pool.backend.Rename(dataPath(from), dataPath(to)) })
+	}
+
+	pool.fileRemoved(from)
+	pool.fileChanged("", to)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package filecache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDirPerms is default permissions for the pool directory, created on the pool creation (see WithCreateDir).
+var DefaultDirPerms os.FileMode = 0755
+
+// NewTempPool creates new cache items pool in the new temporary directory (see ioutil.TempDir for the pattern
+// format), that is removed with all its content on the pool closing. It is useful for per-process scratch caches and
+// tests.
+func NewTempPool(pattern string, opts ...Option) (*Pool, error) {
+	dir, err := ioutil.TempDir("", pattern)
+	if err != nil {
+		return nil, writeError("cannot create temporary cache directory", err)
+	}
+
+	pool, err := NewPoolE(dir, opts...)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, err
+	}
+
+	pool.tempDir = true
+
+	return pool, nil
+}
+
+// userCacheDirPerms is the default permissions of the user cache pool directory (see NewUserCachePool).
+const userCacheDirPerms os.FileMode = 0700
+
+// NewUserCachePool creates new cache items pool in the application subdirectory of the user cache directory (see
+// os.UserCacheDir - e.g. "$XDG_CACHE_HOME/<appName>" or "~/.cache/<appName>" on Linux, "~/Library/Caches/<appName>" on
+// macOS and "%LocalAppData%\<appName>" on Windows). Application name must be a single path element. Directory is
+// created with 0700 permissions by default (see WithCreateDir).
+func NewUserCachePool(appName string, opts ...Option) (*Pool, error) {
+	if appName == "" || appName == "." || appName == ".." || strings.ContainsAny(appName, `/\`) {
+		return nil, newError(ErrUnknown, fmt.Sprintf("wrong application name [%s]", appName), nil)
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, newError(ErrFileOpening, "user cache directory is not available", err)
+	}
+
+	return NewPoolE(filepath.Join(base, appName), append([]Option{WithCreateDir(userCacheDirPerms)}, opts...)...)
+}
+
+// prepareDir creates the pool directory (if directory creation is enabled - see WithCreateDir) and verifies, that it
+// is the directory.
+func (pool *Pool) prepareDir() error {
+	if pool.dirPath == "" { // current directory is used
+		return nil
+	}
+
+	if pool.dirPerm != 0 {
+		if err := pool.backend.MkdirAll(pool.dirPath, pool.dirPerm); err != nil {
+			return writeError(fmt.Sprintf("cannot create cache directory [%s]", pool.dirPath), err)
+		}
+
+		if err := pool.chown(pool.dirPath); err != nil {
+			return writeError(fmt.Sprintf("cannot change cache directory [%s] owner", pool.dirPath), err)
+		}
+	}
+
+	info, err := pool.backend.Stat(pool.dirPath)
+	if err != nil {
+		return newError(ErrFileOpening, fmt.Sprintf("cache directory [%s] is not available", pool.dirPath), err)
+	}
+
+	if !info.IsDir() {
+		return newError(ErrFileOpening,
+			fmt.Sprintf("cache directory [%s] is not a directory", pool.dirPath), errors.New("not a directory"),
+		)
+	}
+
+	return nil
+}
+
+// dirError returns the pool directory preparing error, if directory is still not available (so directories, created
+// after the pool creation, can be used).
+func (pool *Pool) dirError() error {
+	if pool.dirErr == nil {
+		return nil
+	}
+
+	if info, err := pool.backend.Stat(pool.dirPath); err == nil && info.IsDir() {
+		return nil
+	}
+
+	return pool.dirErr
+}
+
+// checkDirWritable verifies, that files can be created in the pool directory (temporary file is created and removed).
+func (pool *Pool) checkDirWritable() error {
+	dir := pool.dirPath
+	if dir == "" {
+		dir = "."
+	}
+
+	path := filepath.Join(dir, ".filecache-"+pool.nodeID+".tmp")
+
+	f, err := pool.backend.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, pool.filePerm)
+	if err != nil {
+		return writeError(fmt.Sprintf("cache directory [%s] is not writable", dir), err)
+	}
+
+	_ = f.Close()
+
+	if err = pool.backend.Remove(path); err != nil {
+		return writeError(fmt.Sprintf("cache directory [%s] is not writable", dir), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// FreeSpacePolicy defines what to do when the filesystem free space drops below the threshold (see WithMinFreeSpace).
+type FreeSpacePolicy uint8
+
+const (
+	// FreeSpaceRefuse refuses writing with ErrNoSpace error (default policy).
+	FreeSpaceRefuse FreeSpacePolicy = iota
+
+	// FreeSpaceEvict evicts cache items (expired first, then in the eviction policy order - see WithEvictionPolicy)
+	// until the free space is above the threshold. Pinned items are never evicted. If the space still is not enough -
+	// writing is refused.
+	FreeSpaceEvict
+)
+
+// evictionCandidate is the cache item file, that can be evicted.
+type evictionCandidate struct {
+	path     string
+	usedAt   time.Time // modification time (or last access time, if access tracking is enabled)
+	hits     uint32    // reads counter (it is read for EvictLeastFrequentlyUsed policy only)
+	priority uint8     // items with lower priority are evicted first (see Pool.PutWithPriority)
+	expired  bool
+}
+
+// EvictionPolicy defines which cache items are evicted first, when the free space is not enough (see
+// WithEvictionPolicy). Expired items are always evicted before another ones, then items with lower priority are evicted
+// before items with higher priority (see Pool.PutWithPriority), and pinned items are never evicted.
+type EvictionPolicy uint8
+
+const (
+	// EvictLeastRecentlyUsed evicts least recently written items first (or least recently used, if access tracking
+	// is enabled - see WithAccessTracking). It is the default policy.
+	EvictLeastRecentlyUsed EvictionPolicy = iota
+
+	// EvictLeastFrequentlyUsed evicts items with the fewest reads first (see WithHitCounting), items with the same
+	// number of reads are evicted in the EvictLeastRecentlyUsed order.
+	EvictLeastFrequentlyUsed
+)
+
+// hasFreeSpace reports whether the filesystem free space is above the threshold. If the free space cannot be
+// determined (not supported by the operating system or pool backend, for example) - true will be returned.
+func (pool *Pool) hasFreeSpace() bool {
+	sr, ok := pool.backend.(spaceReporter)
+	if !ok {
+		return true
+	}
+
+	total, available, err := sr.DiskSpace(pool.dirPath)
+	if err != nil || total == 0 {
+		return true
+	}
+
+	return float64(available)/float64(total)*100 >= pool.minFreeSpace
+}
+
+// ensureFreeSpace checks the free space before writing and applies the free space policy.
+func (pool *Pool) ensureFreeSpace() error {
+	if pool.minFreeSpace <= 0 || pool.hasFreeSpace() {
+		return nil
+	}
+
+	if pool.freeSpacePolicy == FreeSpaceEvict {
+		pool.evictMu.Lock()
+		defer pool.evictMu.Unlock()
+
+		// space could be freed by another goroutine while we was waiting for the lock
+		if pool.hasFreeSpace() {
+			return nil
+		}
+
+		if err := pool.evict(pool.hasFreeSpace); err != nil {
+			return err
+		}
+
+		if pool.hasFreeSpace() {
+			return nil
+		}
+	}
+
+	return newError(ErrNoSpace, fmt.Sprintf("free space in [%s] is below %.2f%%", pool.dirPath, pool.minFreeSpace), nil)
+}
+
+// evict removes cache items (expired first, then with lower priority, then least recently written - or used, if access
+// tracking is enabled) until the enough function returns true. Pinned and currently locked items are skipped, as well
+// as staged and backup files.
+func (pool *Pool) evict(enough func() bool) error {
+	var (
+		candidates = make([]evictionCandidate, 0)
+		mu         sync.Mutex
+	)
+
+	if pool.index != nil {
+		if entries, err := pool.index.snapshot(); err == nil {
+			for name, e := range entries {
+				if !e.Flags.Has(file.FlagPinned) {
+					c := evictionCandidate{
+						path:    filepath.Join(pool.dirPath, name),
+						usedAt:  e.ModTime,
+						expired: !e.ExpiresAt.IsZero() && e.ExpiresAt.Before(pool.now()),
+					}
+
+					// priority, access time and reads counter are not indexed
+					if f, err := pool.openFile(c.path, os.O_RDONLY, 0); err == nil {
+						c.usedAt, c.hits = pool.lastUsed(f, c.usedAt), pool.evictionHits(f)
+						c.priority, _ = f.GetPriority()
+						_ = f.Close()
+					}
+
+					candidates = append(candidates, c)
+				}
+			}
+
+			return pool.evictCandidates(candidates, enough)
+		}
+	}
+
+	if err := pool.walkOverCacheFiles(context.Background(), func(path string) {
+		if !isItemFileName(filepath.Base(path)) { // staged and backup files are used by the committing
+			return
+		}
+
+		info, statErr := pool.backend.Stat(path)
+		if statErr != nil {
+			return
+		}
+
+		f, err := pool.inspectFile(path)
+		if err != nil {
+			return
+		}
+		defer func(f *file.File) { _ = f.Close() }(f)
+
+		if matched, _ := f.SignatureMatched(); !matched {
+			return
+		}
+
+		if flags, _ := f.GetFlags(); flags.Has(file.FlagPinned) {
+			return
+		}
+
+		exp, expErr := f.GetExpiresAt()
+		priority, _ := f.GetPriority()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		candidates = append(candidates, evictionCandidate{
+			path:     path,
+			usedAt:   pool.lastUsed(f, info.ModTime()),
+			hits:     pool.evictionHits(f),
+			priority: priority,
+			expired:  expErr == nil && exp.Before(pool.now()),
+		})
+	}); err != nil {
+		return err
+	}
+
+	return pool.evictCandidates(candidates, enough)
+}
+
+// evictionHits returns the reads counter of the eviction candidate (for EvictLeastFrequentlyUsed policy only).
+func (pool *Pool) evictionHits(f *file.File) uint32 {
+	if pool.evictionPolicy != EvictLeastFrequentlyUsed {
+		return 0
+	}
+
+	hits, _ := f.GetHits()
+
+	return hits
+}
+
+// evictCandidates removes candidates (expired first, then with lower priority, then in the eviction policy order) until
+// enough space is freed.
+func (pool *Pool) evictCandidates(candidates []evictionCandidate, enough func() bool) error {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].expired != candidates[j].expired {
+			return candidates[i].expired
+		}
+
+		if candidates[i].priority != candidates[j].priority {
+			return candidates[i].priority < candidates[j].priority
+		}
+
+		if pool.evictionPolicy == EvictLeastFrequentlyUsed && candidates[i].hits != candidates[j].hits {
+			return candidates[i].hits < candidates[j].hits
+		}
+
+		return candidates[i].usedAt.Before(candidates[j].usedAt)
+	})
+
+	for _, c := range candidates {
+		if enough() {
+			break
+		}
+
+		// locked items are skipped: they are written (e.g. by the caller, that evicts) or read right now
+		lock := pool.fileLock(c.path)
+		if !lock.TryLock() {
+			continue
+		}
+
+		err := pool.removeFile(c.path)
+
+		lock.Unlock()
+
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
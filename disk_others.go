@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package filecache
+
+import "errors"
+
+// diskSpace is not supported on this operating system.
+func diskSpace(string) (total, available uint64, err error) {
+	return 0, 0, errors.New("disk space information is not supported")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package filecache
+
+import "syscall"
+
+// diskSpace returns total and available (for unprivileged users) space of the filesystem, containing the path.
+func diskSpace(path string) (total, available uint64, err error) {
+	var st syscall.Statfs_t
+
+	if err = syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+
+	return uint64(st.Blocks) * uint64(st.Bsize), uint64(st.Bavail) * uint64(st.Bsize), nil //nolint:unconvert
+}
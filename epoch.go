@@ -0,0 +1,170 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// epochFileName is the name of the file (inside cache directory) for pool epoch storing.
+const epochFileName = "filecache.epoch"
+
+// epochFileTimeGranularity is the worst filesystem modification time granularity (FAT). Epoch file, changed within
+// this period before the reading, can be changed again without its modification time (and size) changing.
+const epochFileTimeGranularity = 2 * time.Second
+
+// epoch is the cached value of persisted pool epoch. Cache items, stamped with an older epoch, are treated as misses.
+type epoch struct {
+	mu       sync.Mutex
+	value    uint32
+	modTime  time.Time
+	size     int64
+	readAt   time.Time // when the epoch file was read (or written)
+	sweeping bool      // invalidated items are removed in the background
+	again    bool      // epoch was bumped during the sweeping, so it must be repeated
+}
+
+// epochFilePath returns path to the pool epoch file.
+func (pool *Pool) epochFilePath() string { return filepath.Join(pool.dirPath, epochFileName) }
+
+// currentEpoch returns the persisted pool epoch. Epoch file is re-read only if it was changed (so epoch changes, made
+// by another processes, are visible too). If epoch file does not exist - zero will be returned.
+func (pool *Pool) currentEpoch() uint32 {
+	pool.epoch.mu.Lock()
+	defer pool.epoch.mu.Unlock()
+
+	return pool.loadEpoch()
+}
+
+func (pool *Pool) loadEpoch() uint32 {
+	info, statErr := pool.backend.Stat(pool.epochFilePath())
+	if statErr != nil {
+		if os.IsNotExist(statErr) {
+			pool.epoch.value, pool.epoch.modTime, pool.epoch.size = 0, time.Time{}, 0
+		}
+
+		return pool.epoch.value
+	}
+
+	if pool.epoch.unchanged(info) {
+		return pool.epoch.value
+	}
+
+	readAt := time.Now() // file system clock is compared, not the pool clock (see WithClock)
+
+	data, readErr := pool.readFile(pool.epochFilePath())
+	if readErr != nil {
+		return pool.epoch.value // last known value will be used
+	}
+
+	if value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32); err == nil {
+		pool.epoch.store(uint32(value), info, readAt)
+	}
+
+	return pool.epoch.value
+}
+
+// unchanged reports whether the epoch file was not changed since the last reading. Epoch file content has the same
+// size usually, and its modification time can be too coarse, so the file, changed shortly before the reading, is
+// always treated as changed (it is re-read until its modification time becomes old enough).
+func (e *epoch) unchanged(info os.FileInfo) bool {
+	return info.ModTime().Equal(e.modTime) && info.Size() == e.size &&
+		e.readAt.Sub(e.modTime) >= epochFileTimeGranularity
+}
+
+// store caches the epoch value with the epoch file info (nil info means "unknown", so the file will be re-read).
+func (e *epoch) store(value uint32, info os.FileInfo, readAt time.Time) {
+	e.value, e.modTime, e.size, e.readAt = value, time.Time{}, -1, readAt
+
+	if info != nil {
+		e.modTime, e.size = info.ModTime(), info.Size()
+	}
+}
+
+// InvalidateAll makes all existing cache items misses in O(1) time by bumping the persisted pool epoch. Invalidated
+// items are not deleted immediately - they are deleted lazily in the background (and on access), unless
+// ExpiredCleanupManual policy is used (see WithExpiredCleanup and Pool.Prune).
+func (pool *Pool) InvalidateAll() error {
+	if !pool.acquire() {
+		return errPoolClosed()
+	}
+	defer pool.release()
+
+	pool.epoch.mu.Lock()
+	defer pool.epoch.mu.Unlock()
+
+	var (
+		next    = pool.loadEpoch() + 1
+		tmpPath = pool.epochFilePath() + ".tmp"
+		data    = []byte(strconv.FormatUint(uint64(next), 10) + "\n")
+		writeAt = time.Now()
+	)
+
+	// write into temporary file and rename it, so epoch file content is always consistent
+	if err := pool.writeFileAtomic(tmpPath, pool.epochFilePath(), data); err != nil {
+		return writeError(fmt.Sprintf("cannot write epoch file [%s]", pool.epochFilePath()), err)
+	}
+
+	// written epoch is cached immediately (the file may look unchanged, see epoch.unchanged)
+	info, _ := pool.backend.Stat(pool.epochFilePath())
+	pool.epoch.store(next, info, writeAt)
+
+	pool.sweepInvalidated()
+
+	return nil
+}
+
+// sweepInvalidated starts the background worker, removing invalidated (and expired) items, if it is not started yet
+// (pool.epoch.mu must be locked). Sweeping is canceled on pool closing.
+func (pool *Pool) sweepInvalidated() {
+	if pool.expiredCleanup == ExpiredCleanupManual {
+		return
+	}
+
+	if pool.epoch.sweeping {
+		pool.epoch.again = true
+
+		return
+	}
+
+	pool.epoch.sweeping = true
+
+	pool.workers.Add(1)
+
+	go func() {
+		defer pool.workers.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-pool.done: // in-flight pruning must be canceled on pool closing
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		for {
+			_, err := pool.PruneContext(ctx)
+			pool.reportError(err)
+
+			pool.epoch.mu.Lock()
+
+			if !pool.epoch.again || ctx.Err() != nil {
+				pool.epoch.sweeping, pool.epoch.again = false, false
+				pool.epoch.mu.Unlock()
+
+				return
+			}
+
+			pool.epoch.again = false
+			pool.epoch.mu.Unlock()
+		}
+	}()
+}
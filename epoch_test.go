@@ -0,0 +1,105 @@
+package filecache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeFileInfo is the os.FileInfo with the passed size and modification time.
+type fakeFileInfo struct {
+	os.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (i fakeFileInfo) Size() int64        { return i.size }
+func (i fakeFileInfo) ModTime() time.Time { return i.modTime }
+
+func TestEpochUnchanged(t *testing.T) {
+	var modTime = time.Unix(1600000000, 0)
+
+	for name, tt := range map[string]struct {
+		info   os.FileInfo
+		readAt time.Time
+		stored os.FileInfo
+		want   bool
+	}{
+		"same file, read long after the change": {
+			info:   fakeFileInfo{size: 2, modTime: modTime},
+			stored: fakeFileInfo{size: 2, modTime: modTime},
+			readAt: modTime.Add(epochFileTimeGranularity),
+			want:   true,
+		},
+		"same file, read shortly after the change": {
+			info:   fakeFileInfo{size: 2, modTime: modTime},
+			stored: fakeFileInfo{size: 2, modTime: modTime},
+			readAt: modTime.Add(epochFileTimeGranularity - time.Millisecond),
+		},
+		"modification time changed": {
+			info:   fakeFileInfo{size: 2, modTime: modTime.Add(time.Second)},
+			stored: fakeFileInfo{size: 2, modTime: modTime},
+			readAt: modTime.Add(time.Hour),
+		},
+		"size changed": {
+			info:   fakeFileInfo{size: 3, modTime: modTime},
+			stored: fakeFileInfo{size: 2, modTime: modTime},
+			readAt: modTime.Add(time.Hour),
+		},
+		"unknown file info stored": {
+			info:   fakeFileInfo{size: 2, modTime: modTime},
+			readAt: modTime.Add(time.Hour),
+		},
+	} {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			var e epoch
+
+			e.store(1, tt.stored, tt.readAt)
+
+			if got := e.unchanged(tt.info); got != tt.want {
+				t.Errorf("want %t, got %t", tt.want, got)
+			}
+
+			if e.value != 1 {
+				t.Errorf("want stored value 1, got %d", e.value)
+			}
+		})
+	}
+}
+
+func TestInvalidateAllVisibleForAnotherPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filecache-epoch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var first, second = NewPool(dir, WithExpiredCleanup(ExpiredCleanupManual)), NewPool(dir)
+	defer func() { _, _ = first.Close(), second.Close() }()
+
+	for i := uint32(1); i <= 3; i++ { // epoch file is changed faster, than its modification time granularity
+		if _, err = second.PutForever("key", bytes.NewReader([]byte("value"))); err != nil {
+			t.Fatal(err)
+		}
+
+		if !first.GetItem("key").IsHit() {
+			t.Fatalf("epoch %d: item must be a hit before the invalidation", i)
+		}
+
+		if err = first.InvalidateAll(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := second.currentEpoch(); got != i {
+			t.Errorf("want epoch %d, got %d", i, got)
+		}
+
+		if second.GetItem("key").IsHit() {
+			t.Errorf("epoch %d: item must be a miss after the invalidation", i)
+		}
+	}
+}
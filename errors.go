@@ -1,6 +1,12 @@
 package filecache
 
-// Cache error types
+import (
+	"errors"
+	"os"
+)
+
+// Cache error types. Error types implement error interface, so they can be used as sentinel errors for the errors.Is
+// function (e.g. `errors.Is(err, filecache.ErrNotFound)`). Underlying causes can be extracted using errors.As.
 type ErrorType uint8
 
 const (
@@ -9,6 +15,23 @@ const (
 	ErrFileReading
 	ErrFileWriting
 	ErrExpirationDataNotAvailable
+	ErrPoolClosed
+	ErrCorrupted // stored data hash sum mismatch
+	ErrInvalidated
+	ErrTooLarge // value is larger than the limit (see WithMaxValueSize)
+	ErrNoSpace
+	ErrContentTypeMismatch
+	ErrNotFound // cache item does not exist
+	ErrExpired  // cache item expiration time is exceeded
+	ErrReadOnly // write operation is not allowed (read-only filesystem or missing permissions)
+	ErrOutOfRange
+	ErrVersionMismatch // cache item version differs from the expected one (see Item.SetIfVersion)
+	ErrNotModified     // cache item was not modified (see Item.GetIfNoneMatch)
+	ErrCorruptedHeader // cache item file header fields do not match the header checksum
+	ErrWrongSignature  // cache item file signature does not match the pool signature (see WithStrictSignature)
+	ErrImmutable       // cache item must not be overwritten (see Pool.PutImmutable)
+	ErrConcurrentWrite // cache item file is written by another process (see WithWriteLocks)
+	ErrNotSupported    // operation is not supported for the cache item (see Item.LinkTo)
 )
 
 type Error struct {
@@ -35,11 +58,48 @@ func (e ErrorType) String() string {
 		return "cannot write file"
 	case ErrExpirationDataNotAvailable:
 		return "expiration data is not available"
+	case ErrPoolClosed:
+		return "pool is closed"
+	case ErrCorrupted:
+		return "data is corrupted"
+	case ErrInvalidated:
+		return "item was invalidated"
+	case ErrTooLarge:
+		return "value is too large"
+	case ErrNoSpace:
+		return "not enough free space"
+	case ErrContentTypeMismatch:
+		return "content type mismatch"
+	case ErrNotFound:
+		return "item not found"
+	case ErrExpired:
+		return "item is expired"
+	case ErrReadOnly:
+		return "write operation is not allowed"
+	case ErrOutOfRange:
+		return "range is out of bounds"
+	case ErrVersionMismatch:
+		return "version mismatch"
+	case ErrNotModified:
+		return "item was not modified"
+	case ErrCorruptedHeader:
+		return "file header is corrupted"
+	case ErrWrongSignature:
+		return "wrong file signature"
+	case ErrImmutable:
+		return "item is immutable"
+	case ErrConcurrentWrite:
+		return "item is written concurrently"
+	case ErrNotSupported:
+		return "operation is not supported"
 	}
 
 	return "unrecognized error type"
 }
 
+// Error allows error types usage as sentinel errors.
+func (e ErrorType) Error() string { return e.String() }
+
 // Error returns the error's message.
 func (e *Error) Error() string {
 	return e.Message
@@ -50,7 +110,29 @@ func (e *Error) Unwrap() error {
 	return e.previous
 }
 
+// Is reports whether the error type matches the target error type (it allows `errors.Is(err, ErrNotFound)` usage).
+func (e *Error) Is(target error) bool {
+	tp, ok := target.(ErrorType)
+
+	return ok && tp == e.Type
+}
+
 // Creates new error instance. Previous error can be nil.
 func newError(tp ErrorType, message string, prev error) *Error {
 	return &Error{Type: tp, Message: message, previous: prev}
 }
+
+// writeError creates the file writing error. Read-only filesystem and permission errors are reported as ErrReadOnly
+// (such errors match ErrFileWriting too).
+func writeError(message string, prev error) *Error {
+	err := newError(ErrFileWriting, message, prev)
+
+	if isReadOnlyError(prev) {
+		return newError(ErrReadOnly, message, err)
+	}
+
+	return err
+}
+
+// isReadOnlyError reports whether the error is caused by the read-only filesystem or missing permissions.
+func isReadOnlyError(err error) bool { return errors.Is(err, os.ErrPermission) || isReadOnlyFS(err) }
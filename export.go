@@ -0,0 +1,50 @@
+package filecache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ExportTo writes the item value (payload only, without the cache file header) into the plain file, e.g. for handing
+// cached artifacts to the external tools. Value is verified (and decoded, see WithTransformers) like Get does, and
+// written into the temporary file first, so the destination file is replaced atomically and never contains partial
+// values. Destination file is created with the pool file permissions (see WithFilePerms). Path is resolved on the
+// operating system filesystem (not on the pool backend, see WithBackend).
+func (item *Item) ExportTo(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return writeError(fmt.Sprintf("cannot create temporary file for [%s]", path), err)
+	}
+
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }() // removing fails after the successful renaming
+
+	if err = item.Get(tmp); err != nil {
+		_ = tmp.Close()
+
+		return err
+	}
+
+	if err = tmp.Sync(); err == nil {
+		err = tmp.Chmod(item.filePerm())
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", path), err)
+	}
+
+	return nil
+}
+
+// ExportItem writes the cache item value into the plain file (see Item.ExportTo).
+func (pool *Pool) ExportItem(key, path string) error { return newItem(pool, key).ExportTo(path) }
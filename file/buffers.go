@@ -0,0 +1,32 @@
+package file
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"hash"
+	"sync"
+)
+
+var (
+	// buffers is the pool of read/write buffers (rwBufferSize length), shared between all files.
+	buffers = sync.Pool{New: func() interface{} { b := make([]byte, rwBufferSize); return &b }}
+
+	// hashers is the pool of SHA1 "generators", shared between all files.
+	hashers = sync.Pool{New: func() interface{} { return sha1.New() }} //nolint:gosec
+)
+
+// getBuffer returns the read/write buffer from the pool. It must be returned using putBuffer.
+func getBuffer() *[]byte { return buffers.Get().(*[]byte) }
+
+// putBuffer returns the buffer into the pool.
+func putBuffer(b *[]byte) { buffers.Put(b) }
+
+// getHasher returns the reset SHA1 "generator" from the pool. It must be returned using putHasher.
+func getHasher() hash.Hash {
+	h := hashers.Get().(hash.Hash)
+	h.Reset()
+
+	return h
+}
+
+// putHasher returns the SHA1 "generator" into the pool.
+func putHasher(h hash.Hash) { hashers.Put(h) }
@@ -0,0 +1,418 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// DefaultChunkSize is the default data chunk size for chunked (v2) data format.
+const DefaultChunkSize = 64 * 1024
+
+// Chunked data section layout (FlagChunked is set, data hash sum is not used):
+// +-------------------+------------------+--------------+-----------------------+-----+
+// | Length 0..3 (LE)  | CRC32 4..7 (LE)  | Payload 8..n | Length (next chunk)   | ... |
+// +-------------------+------------------+--------------+-----------------------+-----+
+// CRC32 is calculated using Castagnoli polynomial.
+
+// chunkHeaderLength is the length of chunk header: payload length (uint32) and payload CRC32 (uint32).
+const chunkHeaderLength = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errIncompleteChunk is returned for torn (partially written) chunks.
+var errIncompleteChunk = errors.New("incomplete data chunk")
+
+// WriteChunkedFile creates or overwrites the named osFile and writes the whole cache entry using chunked (v2) data
+// format - data is stored in length-prefixed chunks, each with its own checksum. It allows partial reads, writing
+// resuming after crash (see RecoverChunks) and verification without reading of the whole data. Zero (or negative)
+// chunkSize means DefaultChunkSize.
+func WriteChunkedFile(
+	name string, perm os.FileMode, signature FSignature, h Header, in io.Reader, chunkSize int,
+) error {
+	return WriteChunkedFileFS(nil, name, perm, signature, h, in, chunkSize)
+}
+
+// WriteChunkedEntry writes the whole cache entry into the opened osFile using chunked (v2) data format, like
+// WriteChunkedFile does (osFile is not closed).
+func WriteChunkedEntry(f Handle, signature FSignature, h Header, in io.Reader, chunkSize int) error {
+	file := newFile(f, signature)
+	h.Flags = h.Flags.With(FlagChunked)
+
+	off, err := file.writeHeader(h)
+	if err != nil {
+		return err
+	}
+
+	end, n, err := file.writeChunks(off, in, chunkSize)
+	if err != nil {
+		return err
+	}
+
+	if err = f.Truncate(end); err != nil {
+		return err
+	}
+
+	return file.setDataLength(n)
+}
+
+// IsChunked reports whether the data is stored in chunked (v2) data format.
+func (file *File) IsChunked() bool {
+	flags, err := file.GetFlags()
+
+	return err == nil && flags.Has(FlagChunked)
+}
+
+// writeChunks writes the data chunks, starting from the osFile offset, and returns the offset of the data end and
+// wrote data length.
+func (file *File) writeChunks(off int64, in io.Reader, chunkSize int) (int64, int64, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	var (
+		buf   = make([]byte, chunkHeaderLength+chunkSize)
+		total int64
+	)
+
+	for {
+		n, readErr := io.ReadFull(in, buf[chunkHeaderLength:])
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return off, total, readErr
+		}
+
+		if n > 0 {
+			binary.LittleEndian.PutUint32(buf[0:], uint32(n))
+			binary.LittleEndian.PutUint32(buf[4:], crc32.Checksum(buf[chunkHeaderLength:chunkHeaderLength+n], crcTable))
+
+			if _, err := file.osFile.WriteAt(buf[:chunkHeaderLength+n], off); err != nil {
+				return off, total, err
+			}
+
+			off, total = off+int64(chunkHeaderLength+n), total+int64(n)
+		}
+
+		if readErr != nil { // io.EOF or io.ErrUnexpectedEOF - the data end
+			return off, total, nil
+		}
+	}
+}
+
+// chunk describes the data chunk position.
+type chunk struct {
+	offset int64  // payload offset in the osFile
+	length uint32 // payload length
+	crc    uint32 // payload checksum
+}
+
+// eachChunk calls fn for every complete chunk (chunks headers are read only) and returns the offset of the last
+// complete chunk end. errIncompleteChunk is returned for the torn tail.
+func (file *File) eachChunk(fn func(c chunk) error) (int64, error) {
+	info, err := file.osFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	off, err := file.dataOffset()
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, chunkHeaderLength)
+
+	for off < info.Size() {
+		if _, err := file.osFile.ReadAt(header, off); err != nil {
+			if err == io.EOF {
+				return off, errIncompleteChunk
+			}
+
+			return off, err
+		}
+
+		c := chunk{
+			offset: off + chunkHeaderLength,
+			length: binary.LittleEndian.Uint32(header[0:]),
+			crc:    binary.LittleEndian.Uint32(header[4:]),
+		}
+
+		if c.offset+int64(c.length) > info.Size() {
+			return off, errIncompleteChunk
+		}
+
+		if fn != nil {
+			if err := fn(c); err != nil {
+				return off, err
+			}
+		}
+
+		off = c.offset + int64(c.length)
+	}
+
+	return off, nil
+}
+
+// readChunk reads and verifies the chunk payload.
+func (file *File) readChunk(c chunk, buf []byte) ([]byte, error) {
+	if cap(buf) < int(c.length) {
+		buf = make([]byte, c.length)
+	}
+
+	buf = buf[:c.length]
+
+	if _, err := file.osFile.ReadAt(buf, c.offset); err != nil && err != io.EOF {
+		return buf, err
+	}
+
+	if sum := crc32.Checksum(buf, crcTable); sum != c.crc {
+		return buf, fmt.Errorf("%w. chunk offset: %d, required CRC: %d, current: %d",
+			ErrDataCorrupted, c.offset, c.crc, sum,
+		)
+	}
+
+	return buf, nil
+}
+
+// getChunkedData reads and verifies chunked data and writes it to the writer.
+func (file *File) getChunkedData(out io.Writer) error {
+	var buf []byte
+
+	_, err := file.eachChunk(func(c chunk) (err error) {
+		if buf, err = file.readChunk(c, buf); err != nil {
+			return err
+		}
+
+		_, err = out.Write(buf)
+
+		return err
+	})
+
+	if errors.Is(err, errIncompleteChunk) {
+		return fmt.Errorf("%w: %s", ErrDataCorrupted, err)
+	}
+
+	return err
+}
+
+// chunkedDataSize returns the data size of complete chunks (chunks payloads are not read).
+func (file *File) chunkedDataSize() (int64, error) {
+	var size int64
+
+	if _, err := file.eachChunk(func(c chunk) error { size += int64(c.length); return nil }); err != nil &&
+		!errors.Is(err, errIncompleteChunk) {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// chunksHash returns SHA1 hash sum of the chunks headers (payloads lengths and checksums), so it depends on the data
+// without the data reading.
+func (file *File) chunksHash() ([]byte, error) {
+	var (
+		h      = getHasher()
+		header = make([]byte, chunkHeaderLength)
+	)
+
+	defer putHasher(h)
+
+	if _, err := file.eachChunk(func(c chunk) error {
+		binary.LittleEndian.PutUint32(header[0:], c.length)
+		binary.LittleEndian.PutUint32(header[4:], c.crc)
+		_, _ = h.Write(header)
+
+		return nil
+	}); err != nil && !errors.Is(err, errIncompleteChunk) {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// VerifyChunks verifies checksums of all data chunks.
+func (file *File) VerifyChunks() error { return file.getChunkedData(io.MultiWriter()) }
+
+// RecoverChunks verifies the chunked data, truncates the osFile at the first incomplete (or corrupted) chunk and
+// returns the size of the valid data (so writing can be resumed from this position - see AppendChunks).
+func (file *File) RecoverChunks() (int64, error) {
+	var (
+		size int64
+		buf  []byte
+	)
+
+	end, err := file.eachChunk(func(c chunk) (err error) {
+		if buf, err = file.readChunk(c, buf); err != nil {
+			return err
+		}
+
+		size += int64(c.length)
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errIncompleteChunk) && !errors.Is(err, ErrDataCorrupted) {
+		return 0, err
+	}
+
+	if err := file.osFile.Truncate(end); err != nil {
+		return 0, err
+	}
+
+	return size, file.setDataLength(size)
+}
+
+// AppendChunks appends the data chunks after the last complete chunk (torn tail is overwritten) and returns appended
+// data length. Zero (or negative) chunkSize means DefaultChunkSize.
+func (file *File) AppendChunks(in io.Reader, chunkSize int) (int64, error) {
+	var size int64
+
+	end, err := file.eachChunk(func(c chunk) error { size += int64(c.length); return nil })
+	if err != nil && !errors.Is(err, errIncompleteChunk) {
+		return 0, err
+	}
+
+	newEnd, n, err := file.writeChunks(end, in, chunkSize)
+	if err != nil {
+		return n, err
+	}
+
+	if err = file.osFile.Truncate(newEnd); err != nil {
+		return n, err
+	}
+
+	return n, file.setDataLength(size + n)
+}
+
+// ChunkInfo describes the complete data chunk (see File.Chunks).
+type ChunkInfo struct {
+	Offset int64  // chunk payload position in the data (not in the osFile)
+	Length uint32 // payload length
+	CRC    uint32 // payload CRC32 (Castagnoli polynomial)
+}
+
+// Chunks returns the complete data chunks descriptions (chunks headers are read only, so payloads are not verified),
+// e.g. for the partially written data resuming - the torn tail is skipped.
+func (file *File) Chunks() ([]ChunkInfo, error) {
+	var (
+		chunks []ChunkInfo
+		pos    int64
+	)
+
+	if _, err := file.eachChunk(func(c chunk) error {
+		chunks = append(chunks, ChunkInfo{Offset: pos, Length: c.length, CRC: c.crc})
+		pos += int64(c.length)
+
+		return nil
+	}); err != nil && !errors.Is(err, errIncompleteChunk) {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// TruncateChunks truncates the chunked data to passed size (the chunk, that contains the new data end, is rewritten
+// with the shorter payload). ErrOutOfRange is returned (wrapped), if the size exceeds the size of complete chunks.
+func (file *File) TruncateChunks(size int64) error {
+	var (
+		pos  int64
+		cut  *chunk // chunk, that contains the new data end
+		stop = errors.New("stop")
+	)
+
+	end, err := file.eachChunk(func(c chunk) error {
+		if pos+int64(c.length) <= size {
+			pos += int64(c.length)
+
+			return nil
+		}
+
+		cut = &c
+
+		return stop
+	})
+
+	switch {
+	case cut != nil:
+		buf, readErr := file.readChunk(*cut, nil)
+		if readErr != nil {
+			return readErr
+		}
+
+		// the new data end can be the chunk start (empty payload is not written)
+		newEnd, _, writeErr := file.writeChunks(end, bytes.NewReader(buf[:size-pos]), len(buf))
+		if writeErr != nil {
+			return writeErr
+		}
+
+		if err = file.osFile.Truncate(newEnd); err != nil {
+			return err
+		}
+
+		return file.setDataLength(size)
+
+	case err != nil && !errors.Is(err, errIncompleteChunk):
+		return err
+
+	case pos < size:
+		return fmt.Errorf("%w: size %d exceeds the chunked data size %d", ErrOutOfRange, size, pos)
+	}
+
+	if err = file.osFile.Truncate(end); err != nil { // torn tail is removed
+		return err
+	}
+
+	return file.setDataLength(size)
+}
+
+// getChunkedDataRange reads and verifies the chunks, overlapping with the data range, and writes the range to the
+// writer.
+func (file *File) getChunkedDataRange(out io.Writer, off, length int64) error {
+	var (
+		pos int64 // current chunk data position
+		end = off + length
+		buf []byte
+	)
+
+	_, err := file.eachChunk(func(c chunk) (err error) {
+		chunkStart, chunkEnd := pos, pos+int64(c.length)
+		pos = chunkEnd
+
+		if chunkEnd <= off || chunkStart >= end {
+			return nil // chunk is out of range
+		}
+
+		if buf, err = file.readChunk(c, buf); err != nil {
+			return err
+		}
+
+		from, to := max64(off, chunkStart)-chunkStart, min64(end, chunkEnd)-chunkStart
+		_, err = out.Write(buf[from:to])
+
+		return err
+	})
+
+	if errors.Is(err, errIncompleteChunk) {
+		return fmt.Errorf("%w: %s", ErrDataCorrupted, err)
+	}
+
+	return err
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
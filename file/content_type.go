@@ -0,0 +1,27 @@
+package file
+
+// ContentType is the data content type marker, stored in the header. All the known content types must be declared here.
+type ContentType uint8
+
+const (
+	ContentTypeUnknown ContentType = iota // raw (or unknown) data
+	ContentTypeJSON                       // JSON encoded value
+	ContentTypeGob                        // gob encoded value
+	ContentTypeMsgpack                    // MessagePack encoded value
+)
+
+// String returns the content type name.
+func (ct ContentType) String() string {
+	switch ct {
+	case ContentTypeUnknown:
+		return "unknown"
+	case ContentTypeJSON:
+		return "json"
+	case ContentTypeGob:
+		return "gob"
+	case ContentTypeMsgpack:
+		return "msgpack"
+	}
+
+	return "unrecognized"
+}
@@ -0,0 +1,41 @@
+package file
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Entry creation time is stored in the metadata block with the reserved key (it is not returned with the custom
+// metadata) as unix timestamp in milliseconds (uint64, LE). It is set on the entry writing and kept by the header
+// rewrites, so (unlike the file modification time) it is not changed by the header fields updating.
+
+// createdAtMetaKey is the reserved metadata key for the entry creation time.
+const createdAtMetaKey = "\x00created-at"
+
+// encodeCreatedAt encodes the creation time metadata entry value.
+func encodeCreatedAt(t time.Time) string {
+	var buf [8]byte
+
+	binary.LittleEndian.PutUint64(buf[:], toUnixMs(t))
+
+	return string(buf[:])
+}
+
+// decodeCreatedAt decodes the creation time metadata entry value (zero time is returned for the malformed value).
+func decodeCreatedAt(v string) time.Time {
+	if len(v) != 8 {
+		return time.Time{}
+	}
+
+	return fromUnixMs(binary.LittleEndian.Uint64([]byte(v)))
+}
+
+// GetCreatedAt returns the entry creation time (zero time is returned for the entries, written without it).
+func (file *File) GetCreatedAt() (time.Time, error) {
+	h, err := file.GetHeader()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return h.CreatedAt, nil
+}
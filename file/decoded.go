@@ -0,0 +1,68 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Original length of the transformed data (see TransformedFlags) is stored in the metadata block with the reserved key
+// (it is not returned with the custom metadata) as int64 (LE). Entry writing functions store it for the entries with
+// transformed data, and it is updated in place after the data writing (see SetDecodedLength), because the original
+// length is known after the transformation only.
+
+// decodedLengthMetaKey is the reserved metadata key for the original length of the transformed data.
+const decodedLengthMetaKey = "\x00decoded-length"
+
+// TransformedFlags are the flags, that mark the transformed data (the original data length is stored for it).
+const TransformedFlags = FlagCompressed | FlagEncrypted | FlagCustom
+
+// errNoDecodedLength is returned, when the original data length is not stored in the entry.
+var errNoDecodedLength = errors.New("original data length is not stored")
+
+// encodeDecodedLength encodes the original data length metadata entry value (negative length means "unknown", e.g.
+// until it is updated after the data writing).
+func encodeDecodedLength(length int64) string {
+	var buf [8]byte
+
+	binary.LittleEndian.PutUint64(buf[:], uint64(length))
+
+	return string(buf[:])
+}
+
+// decodeDecodedLength decodes the original data length metadata entry value (-1 is returned for the malformed value).
+func decodeDecodedLength(v string) int64 {
+	if len(v) != 8 {
+		return -1
+	}
+
+	return int64(binary.LittleEndian.Uint64([]byte(v)))
+}
+
+// SetDecodedLength updates the stored original length of the transformed data (entries must be written with one of
+// TransformedFlags, so the length is stored).
+func (file *File) SetDecodedLength(length int64) error {
+	meta, _, err := file.blockLengths()
+	if err != nil {
+		return err
+	}
+
+	block := make([]byte, meta)
+
+	if n, readErr := file.osFile.ReadAt(block, HeaderSize); readErr != nil && n != len(block) {
+		return readErr
+	}
+
+	off, size, found := metaValueOffset(block, decodedLengthMetaKey)
+	if !found || size != 8 {
+		return errNoDecodedLength
+	}
+
+	if n, err := file.osFile.WriteAt([]byte(encodeDecodedLength(length)), HeaderSize+int64(off)); err != nil {
+		return err
+	} else if n != 8 {
+		return fmt.Errorf("wrong wrote bytes length: required length: %d, wrote: %d", 8, n)
+	}
+
+	return nil
+}
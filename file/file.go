@@ -3,98 +3,58 @@ package file
 
 import (
 	"bytes"
-	"crypto/sha1" //nolint:gosec
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"hash"
 	"io"
 	"os"
 	"time"
 )
 
 // Read/write buffer size in bytes
-const rwBufferSize byte = 32
+const rwBufferSize = 32 * 1024
 
 type (
 	// File signature
 	FSignature []byte
 
-	// File field offset and length
-	offset uint8
-	length uint8
-
-	// File field for storing "File signature" (special flag for osFile identification among other files)
-	ffSignature struct {
-		offset
-		length
-	}
-
-	// File field for storing "Expires At" label (in unix timestamp format with milliseconds)
-	ffExpiresAtUnixMs struct {
-		offset
-		length
-	}
-
-	// File field for storing data "hash sum" (in SHA1 format)
-	ffDataSha1 struct {
-		offset
-		length
+	// Handle is the opened osFile, used by the File. *os.File implements it, and another implementations (e.g. in-memory
+	// or remote files) can be used with FromHandle, WriteEntry and Shared. Memory mapping is used for *os.File only.
+	Handle interface {
+		io.ReaderAt
+		io.WriterAt
+		io.Closer
+		Name() string
+		Stat() (os.FileInfo, error)
+		Truncate(size int64) error
 	}
 
-	// Field for useful data
-	ffData struct {
-		offset
-	}
-
-	// Cache osFile representation (all offsets must be set manually on instance creation action)
+	// Cache osFile representation (see the header layout constants for the fields offsets)
 	File struct {
-		ffSignature
-		ffExpiresAtUnixMs
-		ffDataSha1
-		ffData
 		Signature FSignature
-		osFile    *os.File  // osFile on filesystem
-		hashing   hash.Hash // SHA1 "generator" (required for hash sum calculation)
+		osFile    Handle       // osFile on filesystem
+		release   func() error // nil, if osFile is owned (see Shared)
 	}
 )
 
 var DefaultSignature = FSignature("#/CACHE ") // 35, 47, 67, 65, 67, 72, 69, 32
 
+// ErrDataCorrupted is returned (wrapped) when stored data hash sum does not match the read data hash sum.
+var ErrDataCorrupted = errors.New("data hashes mismatched")
+
+// ErrOutOfRange is returned (wrapped) when requested data range exceeds the data bounds.
+var ErrOutOfRange = errors.New("data range is out of bounds")
+
 // newFile creates new osFile instance.
-func newFile(osFile *os.File, signature FSignature) *File {
+func newFile(osFile Handle, signature FSignature) *File {
 	// setup default osFile type bytes slice
 	if signature == nil {
 		signature = DefaultSignature
 	}
 
-	// File block offsets are below:
-	// +----------------+-----------------------+-----------------+------------+
-	// | Signature 0..7 |    Meta Data 8..63    | DataSHA1 64..83 | Data 84..n |
-	// +----------------+-----------------------+-----------------+------------+
-	// |                | ExpiresAtUnixMs 8..15 |                 |            |
-	// +----------------+-----------------------+-----------------+------------+
-	// |                |    RESERVED 16..63    |                 |            |
-	// +----------------+-----------------------+-----------------+------------+
 	return &File{
-		ffSignature: ffSignature{
-			offset: 0,
-			length: 8,
-		},
-		ffExpiresAtUnixMs: ffExpiresAtUnixMs{
-			offset: 8,
-			length: 8,
-		},
-		ffDataSha1: ffDataSha1{
-			offset: 64,
-			length: 20,
-		},
-		ffData: ffData{
-			offset: 84,
-		},
 		Signature: signature,
 		osFile:    osFile,
-		hashing:   sha1.New(), //nolint:gosec
 	}
 }
 
@@ -103,47 +63,67 @@ func newFile(osFile *os.File, signature FSignature) *File {
 // signature can be omitted (nil) - in this case will be used default osFile signature.
 // Important: osFile with signature and data hashsum will be created immediately.
 func Create(name string, perm os.FileMode, signature FSignature) (*File, error) {
-	f, openErr := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
-	if openErr != nil {
-		return nil, openErr
-	}
-
-	file := newFile(f, signature)
-
-	// write osFile signature
-	if err := file.setSignature(file.Signature); err != nil {
-		return nil, err
-	}
+	return CreateFS(nil, name, perm, signature)
+}
 
-	// requires for hashsum init
-	if err := file.SetData(bytes.NewBuffer([]byte{})); err != nil {
-		return nil, err
-	}
+// WriteFile creates or overwrites the named osFile and writes the whole cache entry into it - signature, header field
+// values, data and data hashsum. Header is written with a single call, and osFile is opened only once. Existing osFile
+// is truncated after writing (not on opening), because truncation to zero length with the following blocks allocation
+// is much slower.
+// signature can be omitted (nil) - in this case will be used default osFile signature.
+func WriteFile(name string, perm os.FileMode, signature FSignature, h Header, in io.Reader) error {
+	return WriteFileFS(nil, name, perm, signature, h, in)
+}
 
-	return file, nil
+// WriteEntry writes the whole cache entry into the opened osFile, like WriteFile does (osFile is not closed).
+// signature can be omitted (nil) - in this case will be used default osFile signature.
+func WriteEntry(f Handle, signature FSignature, h Header, in io.Reader) error {
+	return newFile(f, signature).writeEntry(h, in)
 }
 
 // Open the named osFile for reading and writing. If successful, methods on the returned osFile can be used for
-// reading and writing. If there is an error, it will be of type *os.PathError.
+// reading and writing. If there is an error, it will be of type *os.PathError (or ErrCorruptedHeader is returned
+// wrapped, if header checksum is mismatched - see VerifyHeader, and ErrIncompleteEntry - for partially written entries,
+// see VerifyComplete).
 // signature can be omitted (nil) - in this case will be used default osFile signature.
 func Open(name string, perm os.FileMode, signature FSignature) (*File, error) {
-	return open(name, os.O_RDWR, perm, signature)
+	return OpenFS(nil, name, perm, signature)
 }
 
 // OpenRead opens the named osFile for reading. If successful, methods on the returned osFile can be used for reading; the
-// associated osFile descriptor has mode O_RDONLY. If there is an error, it will be of type *os.PathError.
+// associated osFile descriptor has mode O_RDONLY. If there is an error, it will be of type *os.PathError (or
+// ErrCorruptedHeader is returned wrapped, if header checksum is mismatched - see VerifyHeader, and ErrIncompleteEntry -
+// for partially written entries, see VerifyComplete).
 // signature can be omitted (nil) - in this case will be used default osFile signature.
 func OpenRead(name string, signature FSignature) (*File, error) {
-	return open(name, os.O_RDONLY, 0, signature)
+	return OpenReadFS(nil, name, signature)
 }
 
-func open(name string, flag int, perm os.FileMode, signature FSignature) (*File, error) {
-	f, err := os.OpenFile(name, flag, perm)
-	if err != nil {
-		return nil, err
+// FromHandle returns the File over the opened osFile, like Open does: format, header and entry completeness are
+// verified (ErrUnknownFormat is returned wrapped for unknown layout versions), and osFile is closed on verification
+// errors. Closing of the returned File closes the osFile.
+// signature can be omitted (nil) - in this case will be used default osFile signature.
+func FromHandle(f Handle, signature FSignature) (*File, error) {
+	file := newFile(f, signature)
+
+	for _, verify := range [...]func() error{file.verifyFormat, file.VerifyHeader, file.VerifyComplete} {
+		if err := verify(); err != nil {
+			_ = f.Close()
+
+			return nil, err
+		}
 	}
 
-	return newFile(f, signature), nil
+	return file, nil
+}
+
+// Shared returns the File over the already opened (and shared with another users) osFile. Closing of the returned
+// File calls release instead of the osFile closing. Shared osFile must be used for positional reading only.
+func Shared(osFile Handle, signature FSignature, release func() error) *File {
+	file := newFile(osFile, signature)
+	file.release = release
+
+	return file
 }
 
 // Name returns the name of the osFile as presented to Open.
@@ -153,6 +133,10 @@ func (file *File) Name() string { return file.osFile.Name() }
 // will be canceled and return immediately with an error.
 // Close will return an error if it has already been called.
 func (file *File) Close() error {
+	if file.release != nil {
+		return file.release()
+	}
+
 	return file.osFile.Close()
 }
 
@@ -170,33 +154,37 @@ func (file *File) SignatureMatched() (bool, error) {
 // GetSignature of current osFile signature as a typed slice of a bytes.
 func (file *File) GetSignature() (*FSignature, error) { return file.getSignature() }
 
-// getSignature of current osFile signature as a typed slice of a bytes.
+// getSignature of current osFile signature as a typed slice of a bytes (signature length is read from the header, and
+// the whole header is read for the long signatures - see ValidateSignature).
 func (file *File) getSignature() (*FSignature, error) {
-	buf := make(FSignature, file.ffSignature.length)
+	buf := make([]byte, SignatureLengthOffset+1)
 
-	if n, err := file.osFile.ReadAt(buf, int64(file.ffSignature.offset)); err != nil && err != io.EOF {
+	n, err := file.osFile.ReadAt(buf, SignatureOffset)
+	if err != nil && err != io.EOF {
 		return nil, err
-	} else if l := len(buf); n != l {
-		// limit length for too small reading results
-		buf = buf[0:n]
 	}
 
-	return &buf, nil
-}
-
-// setSignature allows to use only bytes slice of signature with length defined in osFile structure.
-func (file *File) setSignature(signature FSignature) error {
-	if l := len(signature); l != int(file.ffSignature.length) {
-		return fmt.Errorf("wrong signature length: required length: %d, passed: %d", file.ffSignature.length, l)
+	length := n // limit length for too small reading results
+	if length > SignatureSize {
+		length = SignatureSize
 	}
 
-	if n, err := file.osFile.WriteAt(signature, int64(file.ffSignature.offset)); err != nil {
-		return err
-	} else if n != len(signature) {
-		return errors.New("wrong wrote bytes length")
+	if n > SignatureLengthOffset {
+		if l := int(buf[SignatureLengthOffset]); l > SignatureSize {
+			h, err := ReadHeader(file.osFile)
+			if err != nil {
+				return nil, err
+			}
+
+			return &h.Signature, nil
+		} else if l > 0 && l < length {
+			length = l
+		}
 	}
 
-	return nil
+	signature := append(FSignature(nil), buf[:length]...)
+
+	return &signature, nil
 }
 
 // GetExpiresAt for current osFile (with milliseconds).
@@ -213,9 +201,9 @@ func (file *File) GetExpiresAt() (time.Time, error) {
 
 // getExpiresAtUnixMs returns unsigned integer value with ExpiresAt in UNIX timestamp format in milliseconds.
 func (file *File) getExpiresAtUnixMs() (uint64, error) {
-	buf := make([]byte, file.ffExpiresAtUnixMs.length)
+	buf := make([]byte, ExpiresAtSize)
 
-	if _, err := file.osFile.ReadAt(buf, int64(file.ffExpiresAtUnixMs.offset)); err != nil && err != io.EOF {
+	if _, err := file.osFile.ReadAt(buf, ExpiresAtOffset); err != nil && err != io.EOF {
 		return 0, err
 	}
 
@@ -224,32 +212,172 @@ func (file *File) getExpiresAtUnixMs() (uint64, error) {
 
 // SetExpiresAt sets the expiring value.
 func (file *File) SetExpiresAt(t time.Time) error {
-	return file.setExpiresAtUnixMs(uint64(t.UnixNano() / int64(time.Millisecond)))
+	return file.setExpiresAtUnixMs(toUnixMs(t))
+}
+
+// toUnixMs converts time into UNIX timestamp in milliseconds. Zero time will be converted into zero.
+func toUnixMs(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.UnixNano() / int64(time.Millisecond))
 }
 
 // setExpiresAtUnixMs sets the expiring time in milliseconds in osFile content.
 func (file *File) setExpiresAtUnixMs(ts uint64) error {
-	buf := make([]byte, file.ffExpiresAtUnixMs.length)
+	buf := make([]byte, ExpiresAtSize)
 
 	// pack unsigned integer into slice of bytes
 	binary.LittleEndian.PutUint64(buf, ts)
 
-	if n, err := file.osFile.WriteAt(buf, int64(file.ffExpiresAtUnixMs.offset)); err != nil {
+	if n, err := file.osFile.WriteAt(buf, ExpiresAtOffset); err != nil {
 		return err
 	} else if n != len(buf) {
 		return errors.New("wrong wrote bytes length")
 	}
 
-	return nil
+	return file.updateHeaderCRC()
+}
+
+// GetEpoch returns the epoch, stamped on entry writing.
+func (file *File) GetEpoch() (uint32, error) {
+	buf := make([]byte, EpochSize)
+
+	if _, err := file.osFile.ReadAt(buf, EpochOffset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+// SetEpoch sets the entry epoch.
+func (file *File) SetEpoch(epoch uint32) error {
+	buf := make([]byte, EpochSize)
+
+	binary.LittleEndian.PutUint32(buf, epoch)
+
+	if n, err := file.osFile.WriteAt(buf, EpochOffset); err != nil {
+		return err
+	} else if n != len(buf) {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return file.updateHeaderCRC()
+}
+
+// GetFlags returns the entry flags.
+func (file *File) GetFlags() (Flags, error) {
+	buf := make([]byte, FlagsSize)
+
+	if _, err := file.osFile.ReadAt(buf, FlagsOffset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return Flags(binary.LittleEndian.Uint16(buf)), nil
+}
+
+// SetFlags sets (replaces) the entry flags.
+func (file *File) SetFlags(flags Flags) error {
+	buf := make([]byte, FlagsSize)
+
+	binary.LittleEndian.PutUint16(buf, uint16(flags))
+
+	if n, err := file.osFile.WriteAt(buf, FlagsOffset); err != nil {
+		return err
+	} else if n != len(buf) {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return file.updateHeaderCRC()
+}
+
+// GetFreshUntil returns the soft expiration time (with milliseconds).
+func (file *File) GetFreshUntil() (time.Time, error) {
+	buf := make([]byte, FreshUntilSize)
+
+	if _, err := file.osFile.ReadAt(buf, FreshUntilOffset); err != nil && err != io.EOF {
+		return time.Time{}, err
+	}
+
+	ms := binary.LittleEndian.Uint64(buf)
+	if ms == 0 {
+		return time.Time{}, errors.New("value was not set")
+	}
+
+	return time.Unix(0, int64(ms*uint64(time.Millisecond))), nil
+}
+
+// SetFreshUntil sets the soft expiration time (zero time means "not set").
+func (file *File) SetFreshUntil(t time.Time) error {
+	buf := make([]byte, FreshUntilSize)
+
+	binary.LittleEndian.PutUint64(buf, toUnixMs(t))
+
+	if n, err := file.osFile.WriteAt(buf, FreshUntilOffset); err != nil {
+		return err
+	} else if n != len(buf) {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return file.updateHeaderCRC()
+}
+
+// GetContentType returns the data content type.
+func (file *File) GetContentType() (ContentType, error) {
+	buf := make([]byte, ContentTypeSize)
+
+	if _, err := file.osFile.ReadAt(buf, ContentTypeOffset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return ContentType(buf[0]), nil
+}
+
+// SetContentType sets the data content type.
+func (file *File) SetContentType(ct ContentType) error {
+	if n, err := file.osFile.WriteAt([]byte{byte(ct)}, ContentTypeOffset); err != nil {
+		return err
+	} else if n != 1 {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return file.updateHeaderCRC()
+}
+
+// GetVersion returns the entry version (zero, if version was not set).
+func (file *File) GetVersion() (uint64, error) {
+	buf := make([]byte, VersionSize)
+
+	if _, err := file.osFile.ReadAt(buf, VersionOffset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+// SetVersion sets the entry version.
+func (file *File) SetVersion(version uint64) error {
+	buf := make([]byte, VersionSize)
+
+	binary.LittleEndian.PutUint64(buf, version)
+
+	if n, err := file.osFile.WriteAt(buf, VersionOffset); err != nil {
+		return err
+	} else if n != len(buf) {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return file.updateHeaderCRC()
 }
 
 // setDataSHA1 sets data hashsum as s slice ob bytes. Hash length must be correct.
 func (file *File) setDataSHA1(h []byte) error {
-	if l := len(h); l != int(file.ffDataSha1.length) {
-		return fmt.Errorf("wrong hash length: required length: %d, passed: %d", file.ffDataSha1.length, l)
+	if l := len(h); l != DataHashSize {
+		return fmt.Errorf("wrong hash length: required length: %d, passed: %d", DataHashSize, l)
 	}
 
-	if n, err := file.osFile.WriteAt(h, int64(file.ffDataSha1.offset)); err != nil {
+	if n, err := file.osFile.WriteAt(h, DataHashOffset); err != nil {
 		return err
 	} else if n != len(h) {
 		return errors.New("wrong wrote bytes length")
@@ -261,11 +389,21 @@ func (file *File) setDataSHA1(h []byte) error {
 // GetDataHash returns osFile data hash.
 func (file *File) GetDataHash() ([]byte, error) { return file.getDataSHA1() }
 
+// ContentHash returns the hash sum, that identifies the data without its reading: stored data hash (SHA1) for regular
+// data, and chunks checksums hash for chunked data.
+func (file *File) ContentHash() ([]byte, error) {
+	if file.IsChunked() {
+		return file.chunksHash()
+	}
+
+	return file.getDataSHA1()
+}
+
 // getDataSHA1 returns osFile data hash.
 func (file *File) getDataSHA1() ([]byte, error) {
-	buf := make([]byte, file.ffDataSha1.length)
+	buf := make([]byte, DataHashSize)
 
-	if _, err := file.osFile.ReadAt(buf, int64(file.ffDataSha1.offset)); err != nil && err != io.EOF {
+	if _, err := file.osFile.ReadAt(buf, DataHashOffset); err != nil && err != io.EOF {
 		return buf, err
 	}
 
@@ -275,58 +413,322 @@ func (file *File) getDataSHA1() ([]byte, error) {
 // SetData sets the osFile data (content will be read from the passed reader instance).
 func (file *File) SetData(in io.Reader) error { return file.setData(in) }
 
-// setData sets the osFile data (content will be read from the passed reader instance).
+// setData sets the osFile data (content will be read from the passed reader instance). Chunked data format is
+// replaced with the regular one.
 func (file *File) setData(in io.Reader) error {
-	buf := make([]byte, rwBufferSize)
-	off := int64(file.ffData.offset)
-	file.hashing.Reset()
-
-	for {
-		// read part of input data
-		n, err := in.Read(buf)
-		if err != nil {
-			if err != io.EOF {
-				return err
-			}
-			break
+	if flags, err := file.GetFlags(); err == nil && flags.Has(FlagChunked) {
+		if err := file.SetFlags(flags.Without(FlagChunked)); err != nil {
+			return err
 		}
+	}
 
-		// limit length for too small reading results
-		if l := len(buf); n != l {
-			buf = buf[0:n]
-		}
+	off, err := file.dataOffset()
+	if err != nil {
+		return err
+	}
 
-		// write content into required position
-		wroteBytes, writeErr := file.osFile.WriteAt(buf, off)
-		if writeErr != nil {
-			return writeErr
-		}
-		// write into "hashing" too for hash sum calculation
-		if _, err := file.hashing.Write(buf); err != nil {
-			return err
-		}
+	n, sum, err := file.writeData(off, in)
+	if err != nil {
+		return err
+	}
 
-		// move offset
-		off += int64(wroteBytes)
+	// previous data can be longer than new
+	if err := file.osFile.Truncate(off + n); err != nil {
+		return err
 	}
 
-	if err := file.setDataSHA1(file.hashing.Sum(nil)); err != nil {
+	if err := file.setDataLength(n); err != nil {
 		return err
 	}
 
-	return nil
+	return file.setDataSHA1(sum)
+}
+
+// AppendData appends the data (content will be read from the passed reader instance) to the end of the data section.
+// Existing data is read for the hash sum calculation (and verified - ErrDataCorrupted is returned on mismatch), but not
+// rewritten. Appended data length is returned.
+func (file *File) AppendData(in io.Reader) (int64, error) {
+	if file.IsChunked() {
+		return file.AppendChunks(in, DefaultChunkSize)
+	}
+
+	existing, err := file.DataReader()
+	if err != nil {
+		return 0, err
+	}
+
+	hashing, buf := getHasher(), getBuffer()
+	defer func() { putHasher(hashing); putBuffer(buf) }()
+
+	if _, err := io.CopyBuffer(hashing, existing, *buf); err != nil {
+		return 0, err
+	}
+
+	existsHash, hashErr := file.getDataSHA1()
+	if hashErr != nil {
+		return 0, hashErr
+	}
+
+	if dataHash := hashing.Sum(nil); !bytes.Equal(dataHash, existsHash) {
+		return 0, fmt.Errorf("%w. required: %v, current: %v", ErrDataCorrupted, existsHash, dataHash)
+	}
+
+	off, err := file.dataOffset()
+	if err != nil {
+		return 0, err
+	}
+
+	end := off + existing.Size()
+
+	n, err := io.CopyBuffer(io.MultiWriter(&offsetWriter{f: file.osFile, off: end}, hashing), in, *buf)
+	if err != nil {
+		return n, err
+	}
+
+	// file can contain garbage after the data end (e.g. after failed appending)
+	if err := file.osFile.Truncate(end + n); err != nil {
+		return n, err
+	}
+
+	if err := file.setDataLength(existing.Size() + n); err != nil {
+		return n, err
+	}
+
+	return n, file.setDataSHA1(hashing.Sum(nil))
+}
+
+// writeData streams the data from the reader into the data section, starting from the osFile offset (hash sum is
+// calculated at the same time), and returns wrote data length and data hash sum.
+func (file *File) writeData(off int64, in io.Reader) (int64, []byte, error) {
+	hashing, buf := getHasher(), getBuffer()
+	defer func() { putHasher(hashing); putBuffer(buf) }()
+
+	n, err := io.CopyBuffer(io.MultiWriter(&offsetWriter{f: file.osFile, off: off}, hashing), in, *buf)
+	if err != nil {
+		return n, nil, err
+	}
+
+	return n, hashing.Sum(nil), nil
+}
+
+// writeEntry writes the whole entry (header, data and data hash sum) and truncates the osFile to the data end. Header
+// is written using a single call.
+func (file *File) writeEntry(h Header, in io.Reader) error {
+	off, err := file.writeHeader(h)
+	if err != nil {
+		return err
+	}
+
+	n, sum, err := file.writeData(off, in)
+	if err != nil {
+		return err
+	}
+
+	if err := file.osFile.Truncate(off + n); err != nil {
+		return err
+	}
+
+	if err := file.setDataLength(n); err != nil {
+		return err
+	}
+
+	return file.setDataSHA1(sum)
+}
+
+// writeHeader writes signature, header field values and metadata block using a single call (data hash sum and data
+// length are zeroed) and returns the data offset.
+func (file *File) writeHeader(h Header) (int64, error) {
+	h.Signature, h.DataHash, h.DataLength = file.Signature, nil, 0
+
+	return writeHeader(file.osFile, h, true)
+}
+
+// offsetWriter writes into the osFile sequentially, starting from the offset.
+type offsetWriter struct {
+	f   io.WriterAt
+	off int64
+}
+
+// Write implements io.Writer interface.
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.off)
+	w.off += int64(n)
+
+	return n, err
+}
+
+// DataSize returns the data length in bytes (osFile size without the header, or chunks payloads size for chunked data).
+func (file *File) DataSize() (int64, error) {
+	if length, stored, err := file.readDataLength(); err != nil || stored {
+		return length, err
+	}
+
+	if file.IsChunked() {
+		return file.chunkedDataSize()
+	}
+
+	info, err := file.osFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	off, err := file.dataOffset()
+	if err != nil {
+		return 0, err
+	}
+
+	if size := info.Size() - off; size > 0 {
+		return size, nil
+	}
+
+	return 0, nil
+}
+
+// DataReader returns the reader of the data section (data hash sum is not verified). Reader is valid until the osFile
+// closing.
+func (file *File) DataReader() (*io.SectionReader, error) {
+	if file.IsChunked() {
+		return nil, errors.New("data section reader is not supported for chunked data")
+	}
+
+	size, err := file.DataSize()
+	if err != nil {
+		return nil, err
+	}
+
+	off, err := file.dataOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NewSectionReader(file.osFile, off, size), nil
 }
 
 // GetData read osFile data and write it to the writer.
 func (file *File) GetData(out io.Writer) error { return file.getData(out) }
 
+// GetDataRange writes length bytes of the data, starting from the data offset off, to the writer. Range must be within
+// the data bounds. Regular data hash sum can not be verified for the range (whole data reading is required), so it is
+// NOT verified; chunked data is verified for the chunks, overlapping with the range.
+func (file *File) GetDataRange(out io.Writer, off, length int64) error {
+	size, err := file.DataSize()
+	if err != nil {
+		return err
+	}
+
+	if off < 0 || length < 0 || off+length > size {
+		return fmt.Errorf("%w: offset %d, length %d, data size %d", ErrOutOfRange, off, length, size)
+	}
+
+	if file.IsChunked() {
+		return file.getChunkedDataRange(out, off, length)
+	}
+
+	dataOff, err := file.dataOffset()
+	if err != nil {
+		return err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	_, err = io.CopyBuffer(out, io.NewSectionReader(file.osFile, dataOff+off, length), *buf)
+
+	return err
+}
+
+// GetDataMapped maps osFile into memory, verifies data hash sum and writes the data to the writer using a single call
+// (data is not copied through the buffer). Regular reading is used, if memory mapping is not supported. Important
+// notice: osFile must not be truncated by another processes while it is mapped.
+func (file *File) GetDataMapped(out io.Writer) error {
+	info, err := file.osFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	off, err := file.dataOffset()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() <= off || file.IsChunked() {
+		return file.getData(out) // nothing to map (or chunks must be verified one by one)
+	}
+
+	osFile, ok := file.osFile.(*os.File)
+	if !ok {
+		return file.getData(out) // only filesystem files can be mapped
+	}
+
+	length, stored, err := file.readDataLength()
+	if err != nil {
+		return err
+	}
+
+	if !stored {
+		length = info.Size() - off
+	} else if size := info.Size() - off; size < length {
+		return fmt.Errorf("%w: data is truncated to %d bytes (stored length %d)", ErrDataCorrupted, size, length)
+	}
+
+	mapped, mapErr := mmap(osFile, int(info.Size()))
+	if mapErr != nil {
+		return file.getData(out)
+	}
+	defer func() { _ = munmap(mapped) }()
+
+	data := mapped[off : off+length]
+
+	hashing := getHasher()
+	defer putHasher(hashing)
+
+	_, _ = hashing.Write(data)
+	dataHash := hashing.Sum(nil)
+
+	existsHash, hashErr := file.getDataSHA1()
+	if hashErr != nil {
+		return hashErr
+	}
+
+	if !bytes.Equal(dataHash, existsHash) {
+		return fmt.Errorf("%w. required: %v, current: %v", ErrDataCorrupted, existsHash, dataHash)
+	}
+
+	_, err = out.Write(data)
+
+	return err
+}
+
 // getData read osFile data and write it to the writer.
 func (file *File) getData(out io.Writer) error {
-	buf := make([]byte, rwBufferSize)
-	off := uint64(file.ffData.offset)
-	file.hashing.Reset()
+	if file.IsChunked() {
+		return file.getChunkedData(out)
+	}
+
+	dataOff, err := file.dataOffset()
+	if err != nil {
+		return err
+	}
+
+	// data is read up to the stored length (or up to the end of file for the files without it)
+	length, stored, err := file.readDataLength()
+	if err != nil {
+		return err
+	}
+
+	pooled, hashing := getBuffer(), getHasher()
+	defer func() { putBuffer(pooled); putHasher(hashing) }()
+
+	buf := *pooled
+	off := uint64(dataOff)
+	end := uint64(dataOff + length)
 
 	for {
+		// do not read after the stored data length
+		if stored && off+uint64(len(buf)) > end {
+			buf = buf[0 : end-off]
+		}
+
 		// read part of useful data
 		n, readErr := file.osFile.ReadAt(buf, int64(off))
 
@@ -347,20 +749,25 @@ func (file *File) getData(out io.Writer) error {
 		}
 
 		// write into "hashing" too for hash sum calculation
-		if _, err := file.hashing.Write(buf); err != nil {
+		if _, err := hashing.Write(buf); err != nil {
 			return err
 		}
 
 		// move offset
 		off += uint64(wroteBytes)
 
-		if readErr == io.EOF {
+		if stored && readErr == io.EOF && off < end {
+			return fmt.Errorf("%w: data is truncated to %d bytes (stored length %d)",
+				ErrDataCorrupted, int64(off)-dataOff, length)
+		}
+
+		if readErr == io.EOF || (stored && off == end) {
 			break
 		}
 	}
 
 	// calculate just read data hash
-	dataHash := file.hashing.Sum(nil)
+	dataHash := hashing.Sum(nil)
 
 	// get existing hash
 	existsHash, hashErr := file.getDataSHA1()
@@ -370,7 +777,7 @@ func (file *File) getData(out io.Writer) error {
 
 	// if hashes mismatched - data was broken
 	if !bytes.Equal(dataHash, existsHash) {
-		return fmt.Errorf("data hashes mismatched. required: %v, current: %v", existsHash, dataHash)
+		return fmt.Errorf("%w. required: %v, current: %v", ErrDataCorrupted, existsHash, dataHash)
 	}
 
 	return nil
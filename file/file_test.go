@@ -0,0 +1,69 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetDataStoredLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var (
+		name = filepath.Join(dir, "entry")
+		data = bytes.Repeat([]byte("data"), 100)
+	)
+
+	for _, tt := range []struct {
+		name    string
+		resize  func(size int64) int64
+		wantErr bool
+	}{
+		{name: "trailing bytes", resize: func(size int64) int64 { return size + 10 }},
+		{name: "truncated data", resize: func(size int64) int64 { return size - 10 }, wantErr: true},
+	} {
+		if err := WriteFile(name, 0600, nil, Header{Key: "key"}, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := OpenFS(nil, name, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// file is changed after the opening (truncated files are rejected on opening)
+		if err = os.Truncate(name, tt.resize(info.Size())); err != nil {
+			t.Fatal(err)
+		}
+
+		for method, get := range map[string]func(*bytes.Buffer) error{
+			"GetData":       func(b *bytes.Buffer) error { return f.GetData(b) },
+			"GetDataMapped": func(b *bytes.Buffer) error { return f.GetDataMapped(b) },
+		} {
+			var buf bytes.Buffer
+
+			err := get(&buf)
+
+			switch {
+			case tt.wantErr && !errors.Is(err, ErrDataCorrupted):
+				t.Errorf("%s, %s: want ErrDataCorrupted, got %v", tt.name, method, err)
+			case !tt.wantErr && (err != nil || !bytes.Equal(buf.Bytes(), data)):
+				t.Errorf("%s, %s: want stored data, got %d bytes (%v)", tt.name, method, buf.Len(), err)
+			}
+		}
+
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,58 @@
+package file
+
+import "strings"
+
+// Flags is a set of entry flags, stored in the header. All the feature-specific bits must be declared here.
+type Flags uint16
+
+const (
+	FlagCompressed Flags = 1 << iota // data is compressed
+	FlagEncrypted                    // data is encrypted
+	FlagNegative                     // entry caches "not found" result (negative caching)
+	FlagPinned                       // entry must not be evicted
+	FlagImmutable                    // entry must not be overwritten
+	FlagTombstone                    // entry was deleted (file is kept as a marker)
+	FlagTooLarge                     // value was too large to be cached (entry is a "don't cache" marker)
+	FlagChunked                      // data is stored in chunks with checksums (v2 data format, see WriteChunkedFile)
+	FlagCustom                       // data is transformed by the custom transformer (e.g. custom encoding)
+	FlagDetached                     // data is stored in the separate raw data file (entry contains the header only)
+)
+
+// flagNames contains names of all known flags (in bits order).
+var flagNames = [...]struct {
+	flag Flags
+	name string
+}{
+	{FlagCompressed, "compressed"},
+	{FlagEncrypted, "encrypted"},
+	{FlagNegative, "negative"},
+	{FlagPinned, "pinned"},
+	{FlagImmutable, "immutable"},
+	{FlagTombstone, "tombstone"},
+	{FlagTooLarge, "too-large"},
+	{FlagChunked, "chunked"},
+	{FlagCustom, "custom"},
+	{FlagDetached, "detached"},
+}
+
+// Has reports whether all passed flags are set.
+func (f Flags) Has(flags Flags) bool { return f&flags == flags }
+
+// With returns the flags with passed flags set.
+func (f Flags) With(flags Flags) Flags { return f | flags }
+
+// Without returns the flags with passed flags cleared.
+func (f Flags) Without(flags Flags) Flags { return f &^ flags }
+
+// String returns names of set flags, separated with "|" (e.g. "compressed|pinned").
+func (f Flags) String() string {
+	names := make([]string, 0, len(flagNames))
+
+	for _, n := range flagNames {
+		if f.Has(n.flag) {
+			names = append(names, n.name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
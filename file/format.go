@@ -0,0 +1,98 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Format describes the cache file layout, so files of different layouts (e.g. with bigger checksum, lengths or tags
+// in the future versions) can coexist in the same directory: layout version is stored in the header (see
+// FormatVersionOffset), and files of unknown layouts are not opened (see DetectFormat).
+type Format struct {
+	Version      uint8            // layout version (stored in the header, zero for the files without it)
+	ByteOrder    binary.ByteOrder // byte order of the header fields and chunks headers
+	HeaderSize   int              // fixed header size (see HeaderSize)
+	Checksum     string           // data checksum algorithm ("sha1" - for the whole data, "crc32c" - for every chunk)
+	ChecksumSize int              // data checksum size in bytes
+	Chunked      bool             // data is stored in chunks (see WriteChunkedFile)
+}
+
+// Known file formats.
+var (
+	// FormatV1 is the regular data format: data is stored as is, data SHA1 hash sum is stored in the header.
+	FormatV1 = Format{ //nolint:gochecknoglobals
+		Version:      1,
+		ByteOrder:    binary.LittleEndian,
+		HeaderSize:   HeaderSize,
+		Checksum:     "sha1",
+		ChecksumSize: DataHashSize,
+	}
+
+	// FormatV2 is the chunked data format: data is stored in length-prefixed chunks, each with its own CRC32 checksum
+	// (Castagnoli polynomial).
+	FormatV2 = Format{ //nolint:gochecknoglobals
+		Version:      2,
+		ByteOrder:    binary.LittleEndian,
+		HeaderSize:   HeaderSize,
+		Checksum:     "crc32c",
+		ChecksumSize: 4,
+		Chunked:      true,
+	}
+)
+
+// ErrUnknownFormat is returned (wrapped) for the files of unknown layout versions (e.g. written by the newer versions).
+var ErrUnknownFormat = errors.New("unknown file format")
+
+// DetectFormat reads the file header and returns the file format. Files without stored layout version (zero value)
+// are FormatV1 or FormatV2 files (depending on FlagChunked flag). ErrUnknownFormat is returned for unknown layout
+// versions and files, shorter than the header.
+func DetectFormat(r io.ReaderAt) (Format, error) {
+	buf := make([]byte, HeaderSize)
+
+	if n, err := r.ReadAt(buf, 0); err != nil && (err != io.EOF || n < len(buf)) {
+		if err == io.EOF {
+			return Format{}, fmt.Errorf("%w: file is shorter than the header (%d bytes)", ErrUnknownFormat, n)
+		}
+
+		return Format{}, err
+	}
+
+	switch version := buf[FormatVersionOffset]; version {
+	case 0:
+		if Flags(binary.LittleEndian.Uint16(buf[FlagsOffset:])).Has(FlagChunked) {
+			return FormatV2, nil
+		}
+
+		return FormatV1, nil
+
+	case FormatV1.Version:
+		return FormatV1, nil
+
+	case FormatV2.Version:
+		return FormatV2, nil
+
+	default:
+		return Format{}, fmt.Errorf("%w: layout version %d", ErrUnknownFormat, version)
+	}
+}
+
+// verifyFormat checks that the layout version is known (files, shorter than the header, are not checked).
+func (file *File) verifyFormat() error {
+	buf := make([]byte, 1)
+
+	if _, err := file.osFile.ReadAt(buf, FormatVersionOffset); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+
+		return err
+	}
+
+	if v := buf[0]; v > FormatV2.Version {
+		return fmt.Errorf("%w: layout version %d", ErrUnknownFormat, v)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+type (
+	// FS is the filesystem, used for the osFile opening (see CreateFS, OpenFS, OpenReadFS, WriteFileFS and
+	// WriteChunkedFileFS). Interface is minimal, so in-memory, read-only overlay or afero.Fs (its OpenFile returns
+	// afero.File, that implements Handle) filesystems can be used with a thin adapter. Nil FS means the operating system
+	// filesystem.
+	FS interface {
+		// OpenFile opens the named osFile with specified flags (os.O_RDONLY, os.O_RDWR, os.O_CREATE, etc.) and
+		// permissions (used on creation), like os.OpenFile.
+		OpenFile(name string, flag int, perm os.FileMode) (Handle, error)
+	}
+
+	// osFS is FS implementation over the operating system filesystem.
+	osFS struct{}
+)
+
+// OpenFile opens the osFile using os.OpenFile.
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (Handle, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err // typed nil must not be returned
+	}
+
+	return f, nil
+}
+
+// openFile opens the osFile using passed filesystem (nil means the operating system filesystem).
+func openFile(fsys FS, name string, flag int, perm os.FileMode) (Handle, error) {
+	if fsys == nil {
+		fsys = osFS{}
+	}
+
+	return fsys.OpenFile(name, flag, perm)
+}
+
+// CreateFS creates or truncates the named osFile on passed filesystem, like Create does.
+func CreateFS(fsys FS, name string, perm os.FileMode, signature FSignature) (*File, error) {
+	f, openErr := openFile(fsys, name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	file := newFile(f, signature)
+
+	// write osFile signature and header with empty data (requires for hashsum init)
+	if err := file.writeEntry(Header{}, bytes.NewBuffer([]byte{})); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// WriteFileFS creates or overwrites the named osFile on passed filesystem and writes the whole cache entry into it,
+// like WriteFile does.
+func WriteFileFS(fsys FS, name string, perm os.FileMode, signature FSignature, h Header, in io.Reader) error {
+	f, openErr := openFile(fsys, name, os.O_RDWR|os.O_CREATE, perm)
+	if openErr != nil {
+		return openErr
+	}
+
+	if err := WriteEntry(f, signature, h, in); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WriteChunkedFileFS creates or overwrites the named osFile on passed filesystem and writes the whole cache entry using
+// chunked (v2) data format, like WriteChunkedFile does.
+func WriteChunkedFileFS(
+	fsys FS, name string, perm os.FileMode, signature FSignature, h Header, in io.Reader, chunkSize int,
+) error {
+	f, openErr := openFile(fsys, name, os.O_RDWR|os.O_CREATE, perm)
+	if openErr != nil {
+		return openErr
+	}
+
+	if err := WriteChunkedEntry(f, signature, h, in, chunkSize); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// OpenFS opens the named osFile on passed filesystem for reading and writing, like Open does.
+func OpenFS(fsys FS, name string, perm os.FileMode, signature FSignature) (*File, error) {
+	return open(fsys, name, os.O_RDWR, perm, signature)
+}
+
+// OpenReadFS opens the named osFile on passed filesystem for reading, like OpenRead does.
+func OpenReadFS(fsys FS, name string, signature FSignature) (*File, error) {
+	return open(fsys, name, os.O_RDONLY, 0, signature)
+}
+
+func open(fsys FS, name string, flag int, perm os.FileMode, signature FSignature) (*File, error) {
+	f, err := openFile(fsys, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromHandle(f, signature)
+}
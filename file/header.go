@@ -0,0 +1,545 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// File block offsets are below (metadata block length m and key block length k are stored in MetaLength and
+// KeyLength fields, zero without metadata and key). All numbers are stored in little-endian byte order, times are
+// stored in unix timestamp format with milliseconds (zero means "not set"):
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// | Signature 0..7 |    Meta Data 8..63    | DataSHA1 64..83 | Meta 84..84+m | Key 84+m..84+m+k  | Data ...n    |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                | ExpiresAtUnixMs 8..15 |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |     Epoch 16..19      |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |     Flags 20..21      |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |   ContentType 22..22  |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |    FreshUntil 23..30  |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |   MetaLength 31..34   |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |    Version 35..42     |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |   KeyLength 43..44    |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                | SignatureLength 45..45|                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |   HeaderCRC 46..49    |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |   AccessedAt 50..57   |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |      Hits 58..61      |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                |    Priority 62..62    |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+// |                | FormatVersion 63..63  |                 |               |                   |              |
+// +----------------+-----------------------+-----------------+---------------+-------------------+--------------+
+
+// Header fields offsets and sizes (in bytes).
+const (
+	SignatureOffset, SignatureSize     = 0, 8   // file signature (see DefaultSignature)
+	ExpiresAtOffset, ExpiresAtSize     = 8, 8   // expiration time
+	EpochOffset, EpochSize             = 16, 4  // pool epoch at the moment of entry writing
+	FlagsOffset, FlagsSize             = 20, 2  // entry flags (bit set)
+	ContentTypeOffset, ContentTypeSize = 22, 1  // data content type
+	FreshUntilOffset, FreshUntilSize   = 23, 8  // soft expiration time
+	MetaLengthOffset, MetaLengthSize   = 31, 4  // metadata block length
+	VersionOffset, VersionSize         = 35, 8  // entry version (changed on every data writing)
+	KeyLengthOffset, KeyLengthSize     = 43, 2  // key block length
+	SignatureLengthOffset              = 45     // signature length (1 byte, zero means SignatureSize)
+	MaxSignatureSize                   = 0xFF   // signature length limit (see ValidateSignature)
+	HeaderCRCOffset, HeaderCRCSize     = 46, 4  // CRC32 (Castagnoli) of the header fields 0..45 (zero means "not set")
+	AccessedAtOffset, AccessedAtSize   = 50, 8  // last access time (advisory, is not covered by the header checksum)
+	HitsOffset, HitsSize               = 58, 4  // reads counter (advisory, is not covered by the header checksum)
+	PriorityOffset, PrioritySize       = 62, 1  // eviction priority (advisory, is not covered by the header checksum)
+	FormatVersionOffset                = 63     // layout version (1 byte, zero means "not set" - see DetectFormat)
+	DataHashOffset, DataHashSize       = 64, 20 // data hash sum (SHA1, or zeroes for chunked data)
+
+	// HeaderSize is the fixed header size (metadata block starts right after the fixed header).
+	HeaderSize = 84
+)
+
+// Header is the entry header: field values, that can be written together with the data (see WriteFile).
+type Header struct {
+	ExpiresAt   time.Time // zero value means "not set"
+	Epoch       uint32
+	Flags       Flags
+	ContentType ContentType
+	FreshUntil  time.Time         // zero value means "not set"
+	Meta        map[string]string // custom metadata (see MaxMetaLength and ValidateMeta)
+	Version     uint64            // zero value means "not set"
+	Key         string            // original entry key (see MaxKeyLength), empty value means "not set"
+	AccessedAt  time.Time         // last access time (zero value means "not set")
+	Hits        uint32            // reads counter
+	Priority    uint8             // eviction priority (entries with lower priority are evicted first)
+	CreatedAt   time.Time         // entry creation time (zero value means "not set", see GetCreatedAt)
+
+	// DataLength is the stored data length (-1, if it is not stored, e.g. for the files, written by older versions).
+	// Entry writing functions store and update it, and WriteHeader writes positive length only.
+	DataLength int64
+
+	// DecodedLength is the original length of the transformed data (negative, if it is not stored or unknown). It is
+	// written for the entries with one of TransformedFlags only, and can be updated after writing (see
+	// SetDecodedLength).
+	DecodedLength int64
+
+	// Signature and DataHash are filled on header reading, and written by WriteHeader as is. Entry writing functions
+	// (WriteFile, etc.) ignore them - file signature is used, and data hash sum is calculated.
+	Signature FSignature
+	DataHash  []byte
+}
+
+// ReadHeader reads and decodes the entry header (including metadata and key blocks) from the reader. It can be used
+// by external tools for the cache files inspection (data section starts at HeaderSize+len(encoded meta)+len(key) - see
+// DataOffset). ErrDataCorrupted is returned (wrapped) for truncated or malformed headers.
+func ReadHeader(r io.ReaderAt) (Header, error) {
+	var (
+		h   = Header{DataLength: -1, DecodedLength: -1}
+		buf = make([]byte, HeaderSize)
+	)
+
+	if n, err := r.ReadAt(buf, 0); err != nil && n != len(buf) { // io.EOF can be returned for the full reading
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return h, fmt.Errorf("%w: header is truncated", ErrDataCorrupted)
+		}
+
+		return h, err
+	}
+
+	if err := verifyHeaderCRC(buf); err != nil {
+		return h, err
+	}
+
+	sigLength := int(buf[SignatureLengthOffset])
+	if sigLength == 0 { // files, written without signature length
+		sigLength = SignatureSize
+	}
+
+	h.Signature = append(FSignature(nil), buf[SignatureOffset:SignatureOffset+minInt(sigLength, SignatureSize)]...)
+	h.ExpiresAt = fromUnixMs(binary.LittleEndian.Uint64(buf[ExpiresAtOffset:]))
+	h.Epoch = binary.LittleEndian.Uint32(buf[EpochOffset:])
+	h.Flags = Flags(binary.LittleEndian.Uint16(buf[FlagsOffset:]))
+	h.ContentType = ContentType(buf[ContentTypeOffset])
+	h.FreshUntil = fromUnixMs(binary.LittleEndian.Uint64(buf[FreshUntilOffset:]))
+	h.Version = binary.LittleEndian.Uint64(buf[VersionOffset:])
+	h.AccessedAt = fromUnixMs(binary.LittleEndian.Uint64(buf[AccessedAtOffset:]))
+	h.Hits = binary.LittleEndian.Uint32(buf[HitsOffset:])
+	h.Priority = buf[PriorityOffset]
+	h.DataHash = append([]byte(nil), buf[DataHashOffset:DataHashOffset+DataHashSize]...)
+
+	var (
+		metaLength = int64(binary.LittleEndian.Uint32(buf[MetaLengthOffset:]))
+		keyLength  = int64(binary.LittleEndian.Uint16(buf[KeyLengthOffset:]))
+	)
+
+	if metaLength > MaxMetaLength {
+		return h, fmt.Errorf("%w: wrong metadata block length %d", ErrDataCorrupted, metaLength)
+	}
+
+	if metaLength+keyLength == 0 {
+		if sigLength > SignatureSize {
+			return h, fmt.Errorf("%w: signature tail is missing", ErrDataCorrupted)
+		}
+
+		return h, nil
+	}
+
+	blocks := make([]byte, metaLength+keyLength)
+
+	if n, err := r.ReadAt(blocks, HeaderSize); err != nil && n != len(blocks) {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return h, fmt.Errorf("%w: metadata or key block is truncated", ErrDataCorrupted)
+		}
+
+		return h, err
+	}
+
+	meta, err := decodeMetaBlock(blocks[:metaLength])
+	if err != nil {
+		return h, fmt.Errorf("%w: %s", ErrDataCorrupted, err)
+	}
+
+	if sigLength > SignatureSize { // long signature tail is stored in the metadata block
+		if tail := meta[signatureTailMetaKey]; len(tail) == sigLength-SignatureSize {
+			h.Signature = append(h.Signature, tail...)
+		} else {
+			return h, fmt.Errorf("%w: wrong signature tail length %d", ErrDataCorrupted, len(tail))
+		}
+	}
+
+	h.CreatedAt = decodeCreatedAt(meta[createdAtMetaKey])
+
+	if v, ok := meta[decodedLengthMetaKey]; ok {
+		h.DecodedLength = decodeDecodedLength(v)
+	}
+
+	h.Meta, h.Key = customMeta(meta), string(blocks[metaLength:])
+
+	if full := append(buf, blocks...); hasDataLength(full) {
+		h.DataLength = int64(binary.LittleEndian.Uint64(full[dataLengthValueOffset:]))
+	}
+
+	return h, nil
+}
+
+// WriteHeader encodes and writes the entry header (including metadata and key blocks) into the writer using a single
+// call, and returns the data section offset. Signature (DefaultSignature, if it is not set) and data hash sum (zeroes,
+// if it is not set) are written as is.
+func WriteHeader(w io.WriterAt, h Header) (int64, error) { return writeHeader(w, h, h.DataLength > 0) }
+
+// writeHeader writes the entry header, like WriteHeader does (data length is stored, if withLength is true).
+func writeHeader(w io.WriterAt, h Header, withLength bool) (int64, error) {
+	buf, err := encodeHeader(h, withLength)
+	if err != nil {
+		return 0, err
+	}
+
+	if n, err := w.WriteAt(buf, 0); err != nil {
+		return 0, err
+	} else if n != len(buf) {
+		return 0, errors.New("wrong wrote bytes length")
+	}
+
+	return int64(len(buf)), nil
+}
+
+// DataOffset returns the data section offset for the header.
+func (h Header) DataOffset() (int64, error) {
+	if err := ValidateMeta(h.Meta); err != nil {
+		return 0, err
+	}
+
+	meta, err := encodeMeta(h.blockMeta(h.DataLength > 0))
+	if err != nil {
+		return 0, err
+	}
+
+	return HeaderSize + int64(len(meta)) + int64(len(h.Key)), nil
+}
+
+// blockMeta returns the metadata block entries (custom metadata with the creation time, if it is set, the long
+// signature tail, the original length of the transformed data, and the data length, if withLength is true).
+func (h Header) blockMeta(withLength bool) map[string]string {
+	var transformed = h.Flags&TransformedFlags != 0
+
+	if !withLength && !transformed && h.CreatedAt.IsZero() && len(h.Signature) <= SignatureSize {
+		return h.Meta
+	}
+
+	m := make(map[string]string, len(h.Meta)+4)
+
+	for k, v := range h.Meta {
+		m[k] = v
+	}
+
+	if withLength {
+		m[dataLengthMetaKey] = encodeDataLength(h.DataLength)
+	}
+
+	if !h.CreatedAt.IsZero() {
+		m[createdAtMetaKey] = encodeCreatedAt(h.CreatedAt)
+	}
+
+	if transformed {
+		m[decodedLengthMetaKey] = encodeDecodedLength(h.DecodedLength)
+	}
+
+	if len(h.Signature) > SignatureSize {
+		m[signatureTailMetaKey] = string(h.Signature[SignatureSize:])
+	}
+
+	return m
+}
+
+// encodeHeader encodes the fixed header, metadata and key blocks (data length is stored, if withLength is true).
+func encodeHeader(h Header, withLength bool) ([]byte, error) {
+	if h.Signature == nil {
+		h.Signature = DefaultSignature
+	}
+
+	if err := ValidateSignature(h.Signature); err != nil {
+		return nil, err
+	}
+
+	if l := len(h.DataHash); l != 0 && l != DataHashSize {
+		return nil, fmt.Errorf("wrong hash length: required length: %d, passed: %d", DataHashSize, l)
+	}
+
+	if err := ValidateMeta(h.Meta); err != nil {
+		return nil, err
+	}
+
+	meta, err := encodeMeta(h.blockMeta(withLength))
+	if err != nil {
+		return nil, err
+	}
+
+	if l := len(h.Key); l > MaxKeyLength {
+		return nil, fmt.Errorf("key is too long: maximal length: %d, passed: %d", MaxKeyLength, l)
+	}
+
+	buf := make([]byte, HeaderSize+len(meta)+len(h.Key))
+	copy(buf[SignatureOffset:SignatureOffset+SignatureSize], h.Signature)
+	buf[SignatureLengthOffset] = byte(len(h.Signature))
+	binary.LittleEndian.PutUint64(buf[ExpiresAtOffset:], toUnixMs(h.ExpiresAt))
+	binary.LittleEndian.PutUint32(buf[EpochOffset:], h.Epoch)
+	binary.LittleEndian.PutUint16(buf[FlagsOffset:], uint16(h.Flags))
+	buf[ContentTypeOffset] = byte(h.ContentType)
+	binary.LittleEndian.PutUint64(buf[FreshUntilOffset:], toUnixMs(h.FreshUntil))
+	binary.LittleEndian.PutUint32(buf[MetaLengthOffset:], uint32(len(meta)))
+	binary.LittleEndian.PutUint64(buf[VersionOffset:], h.Version)
+	binary.LittleEndian.PutUint16(buf[KeyLengthOffset:], uint16(len(h.Key)))
+	binary.LittleEndian.PutUint32(buf[HeaderCRCOffset:], headerCRC(buf))
+	binary.LittleEndian.PutUint64(buf[AccessedAtOffset:], toUnixMs(h.AccessedAt))
+	binary.LittleEndian.PutUint32(buf[HitsOffset:], h.Hits)
+	buf[PriorityOffset] = h.Priority
+	copy(buf[DataHashOffset:], h.DataHash)
+	copy(buf[HeaderSize:], meta)
+	copy(buf[HeaderSize+len(meta):], h.Key)
+
+	return buf, nil
+}
+
+// ErrCorruptedHeader is returned (wrapped) when header fields do not match the header checksum (e.g. for torn header
+// writes).
+var ErrCorruptedHeader = errors.New("header checksum mismatched")
+
+// headerCRC calculates the checksum of the header fields (buf must contain the fields, protected by the checksum).
+func headerCRC(buf []byte) uint32 { return crc32.Checksum(buf[:HeaderCRCOffset], crcTable) }
+
+// verifyHeaderCRC verifies the header checksum (buf must contain the header fields and the checksum). Checksum is not
+// verified for the files, written without it (zero checksum).
+func verifyHeaderCRC(buf []byte) error {
+	stored := binary.LittleEndian.Uint32(buf[HeaderCRCOffset:])
+
+	if sum := headerCRC(buf); stored != 0 && stored != sum {
+		return fmt.Errorf("%w. required: %d, current: %d", ErrCorruptedHeader, stored, sum)
+	}
+
+	return nil
+}
+
+// VerifyHeader reads and verifies the header checksum. Files, shorter than the header (e.g. just created), and files,
+// written without checksum, are not verified.
+func (file *File) VerifyHeader() error {
+	buf := make([]byte, HeaderCRCOffset+HeaderCRCSize)
+
+	if n, err := file.osFile.ReadAt(buf, 0); err != nil {
+		if err == io.EOF && n < len(buf) {
+			return nil
+		}
+
+		if err != io.EOF {
+			return err
+		}
+	}
+
+	return verifyHeaderCRC(buf)
+}
+
+// ErrIncompleteEntry is returned (wrapped) on opening of the files, that are shorter than the header or have no data
+// hash sum (entry writing was interrupted or is still in progress).
+var ErrIncompleteEntry = errors.New("entry is incomplete")
+
+// VerifyComplete checks that the entry was completely written: file is not shorter than the header, data hash sum is
+// set, and data is not shorter than the stored data length (chunked data is not checked, because chunks are verified
+// on reading - see WriteChunkedFile).
+func (file *File) VerifyComplete() error {
+	buf := make([]byte, dataLengthValueOffset+8) // the data length is read together with the header
+
+	n, err := file.osFile.ReadAt(buf, 0)
+	if err != nil && (err != io.EOF || n < HeaderSize) {
+		if err == io.EOF {
+			return fmt.Errorf("%w: file is shorter than the header (%d bytes)", ErrIncompleteEntry, n)
+		}
+
+		return err
+	}
+
+	buf = buf[:n]
+
+	if Flags(binary.LittleEndian.Uint16(buf[FlagsOffset:])).Has(FlagChunked) {
+		return nil
+	}
+
+	var hashed bool
+
+	for _, b := range buf[DataHashOffset : DataHashOffset+DataHashSize] {
+		if b != 0 {
+			hashed = true
+
+			break
+		}
+	}
+
+	if !hashed {
+		return fmt.Errorf("%w: data hash sum is not set", ErrIncompleteEntry)
+	}
+
+	if !hasDataLength(buf) {
+		return nil
+	}
+
+	info, err := file.osFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	var (
+		length = int64(binary.LittleEndian.Uint64(buf[dataLengthValueOffset:]))
+		end    = HeaderSize + int64(binary.LittleEndian.Uint32(buf[MetaLengthOffset:])) +
+			int64(binary.LittleEndian.Uint16(buf[KeyLengthOffset:])) + length
+	)
+
+	if info.Size() < end {
+		return fmt.Errorf("%w: data is truncated (%d of %d bytes)", ErrIncompleteEntry, info.Size(), end)
+	}
+
+	return nil
+}
+
+// updateHeaderCRC recalculates and writes the header checksum (it must be called after every header field changing).
+func (file *File) updateHeaderCRC() error {
+	buf := make([]byte, HeaderCRCOffset+HeaderCRCSize)
+
+	if n, err := file.osFile.ReadAt(buf[:HeaderCRCOffset], 0); err != nil && (err != io.EOF || n < HeaderCRCOffset) {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(buf[HeaderCRCOffset:], headerCRC(buf))
+
+	if n, err := file.osFile.WriteAt(buf[HeaderCRCOffset:], HeaderCRCOffset); err != nil {
+		return err
+	} else if n != HeaderCRCSize {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return nil
+}
+
+// GetAccessedAt returns the last access time (zero time, if it was not set).
+func (file *File) GetAccessedAt() (time.Time, error) {
+	buf := make([]byte, AccessedAtSize)
+
+	if _, err := file.osFile.ReadAt(buf, AccessedAtOffset); err != nil && err != io.EOF {
+		return time.Time{}, err
+	}
+
+	return fromUnixMs(binary.LittleEndian.Uint64(buf)), nil
+}
+
+// SetAccessedAt sets the last access time. Header checksum is not changed (last access time is not covered by it),
+// so it is safe to update the access time concurrently with the header reading.
+func (file *File) SetAccessedAt(t time.Time) error {
+	buf := make([]byte, AccessedAtSize)
+
+	binary.LittleEndian.PutUint64(buf, toUnixMs(t))
+
+	if n, err := file.osFile.WriteAt(buf, AccessedAtOffset); err != nil {
+		return err
+	} else if n != len(buf) {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return nil
+}
+
+// GetHits returns the reads counter.
+func (file *File) GetHits() (uint32, error) {
+	buf := make([]byte, HitsSize)
+
+	if _, err := file.osFile.ReadAt(buf, HitsOffset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+// SetHits sets the reads counter. Header checksum is not changed (reads counter is not covered by it).
+func (file *File) SetHits(hits uint32) error {
+	buf := make([]byte, HitsSize)
+
+	binary.LittleEndian.PutUint32(buf, hits)
+
+	if n, err := file.osFile.WriteAt(buf, HitsOffset); err != nil {
+		return err
+	} else if n != len(buf) {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return nil
+}
+
+// GetPriority returns the eviction priority.
+func (file *File) GetPriority() (uint8, error) {
+	buf := make([]byte, PrioritySize)
+
+	if _, err := file.osFile.ReadAt(buf, PriorityOffset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+// SetPriority sets the eviction priority. Header checksum is not changed (priority is not covered by it).
+func (file *File) SetPriority(priority uint8) error {
+	if n, err := file.osFile.WriteAt([]byte{priority}, PriorityOffset); err != nil {
+		return err
+	} else if n != PrioritySize {
+		return errors.New("wrong wrote bytes length")
+	}
+
+	return nil
+}
+
+// ErrWrongSignature is returned (wrapped) for signatures of wrong length.
+var ErrWrongSignature = errors.New("wrong signature")
+
+// signatureTailMetaKey is the reserved metadata key for the long signature bytes after the first SignatureSize bytes.
+const signatureTailMetaKey = "\x00signature"
+
+// ValidateSignature checks the signature length: signature must be 1..MaxSignatureSize bytes long (shorter signatures
+// are stored with the signature length, and unused signature bytes are zeroed; bytes after the first SignatureSize
+// bytes of longer signatures are stored in the metadata block). Nil signature means DefaultSignature.
+func ValidateSignature(signature FSignature) error {
+	if signature == nil {
+		return nil
+	}
+
+	if l := len(signature); l == 0 || l > MaxSignatureSize {
+		return fmt.Errorf("%w: required length: 1..%d, passed: %d", ErrWrongSignature, MaxSignatureSize, l)
+	}
+
+	return nil
+}
+
+// minInt returns the minimal of passed values.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// fromUnixMs converts UNIX timestamp in milliseconds into time. Zero will be converted into zero time.
+func fromUnixMs(ms uint64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(ms*uint64(time.Millisecond)))
+}
+
+// GetHeader returns all header field values, including the metadata, key, signature and data hash sum (unset times are
+// returned as zero values).
+func (file *File) GetHeader() (Header, error) { return ReadHeader(file.osFile) }
@@ -0,0 +1,135 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// buffer is the in-memory io.WriterAt.
+type buffer []byte
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(*b) {
+		*b = append(*b, make([]byte, end-len(*b))...)
+	}
+
+	return copy((*b)[off:], p), nil
+}
+
+func writeTestHeader(t *testing.T) []byte {
+	t.Helper()
+
+	var b buffer
+
+	if _, err := WriteHeader(&b, Header{
+		ExpiresAt:  time.Unix(1600000000, 0),
+		Epoch:      7,
+		Flags:      FlagPinned,
+		Meta:       map[string]string{"foo": "bar"},
+		Version:    3,
+		Key:        "key",
+		Hits:       5,
+		Priority:   1,
+		DataLength: 10,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestHeaderCRCRoundTrip(t *testing.T) {
+	buf := writeTestHeader(t)
+
+	if binary.LittleEndian.Uint32(buf[HeaderCRCOffset:]) == 0 {
+		t.Fatal("header checksum is not written")
+	}
+
+	h, err := ReadHeader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !h.ExpiresAt.Equal(time.Unix(1600000000, 0)) || h.Epoch != 7 || h.Flags != FlagPinned || h.Version != 3 ||
+		h.Key != "key" || h.Meta["foo"] != "bar" || h.Hits != 5 || h.Priority != 1 || h.DataLength != 10 {
+		t.Errorf("wrong header read: %+v", h)
+	}
+}
+
+func TestHeaderCRCMismatch(t *testing.T) {
+	for offset := 0; offset < HeaderCRCOffset+HeaderCRCSize; offset++ {
+		buf := writeTestHeader(t)
+		buf[offset] ^= 0x01
+
+		if _, err := ReadHeader(bytes.NewReader(buf)); !errors.Is(err, ErrCorruptedHeader) {
+			t.Errorf("byte %d changing: want ErrCorruptedHeader, got %v", offset, err)
+		}
+	}
+}
+
+func TestHeaderCRCAdvisoryFields(t *testing.T) {
+	buf := writeTestHeader(t)
+
+	// advisory fields are updated in place, without the checksum updating
+	binary.LittleEndian.PutUint64(buf[AccessedAtOffset:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	binary.LittleEndian.PutUint32(buf[HitsOffset:], 100)
+	buf[PriorityOffset] = 9
+
+	h, err := ReadHeader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Hits != 100 || h.Priority != 9 {
+		t.Errorf("want hits 100 and priority 9, got %d and %d", h.Hits, h.Priority)
+	}
+}
+
+func TestHeaderCRCNotSet(t *testing.T) {
+	buf := writeTestHeader(t)
+
+	binary.LittleEndian.PutUint32(buf[HeaderCRCOffset:], 0) // files, written without checksum
+	buf[EpochOffset]++
+
+	h, err := ReadHeader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Epoch != 8 {
+		t.Errorf("want epoch 8, got %d", h.Epoch)
+	}
+}
+
+func TestReadHeaderTruncated(t *testing.T) {
+	buf := writeTestHeader(t)
+
+	for _, length := range []int{0, 1, HeaderCRCOffset, HeaderSize - 1} {
+		if _, err := ReadHeader(bytes.NewReader(buf[:length])); !errors.Is(err, ErrDataCorrupted) {
+			t.Errorf("header truncated to %d bytes: want ErrDataCorrupted, got %v", length, err)
+		}
+	}
+}
+
+func TestHeaderDecodedLength(t *testing.T) {
+	for flags, want := range map[Flags]int64{0: -1, FlagCompressed: 42, FlagEncrypted | FlagCustom: 42} {
+		var b buffer
+
+		if _, err := WriteHeader(&b, Header{Flags: flags, Key: "key", DecodedLength: 42}); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := ReadHeader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if h.DecodedLength != want || len(h.Meta) != 0 {
+			t.Errorf("flags %v: want decoded length %d without custom metadata, got %d and %v",
+				flags, want, h.DecodedLength, h.Meta)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// Info is the cache file inspection result (see Inspect).
+type Info struct {
+	Size           int64      // file size in bytes
+	Signature      FSignature // stored signature (nil, if file is shorter than the signature)
+	SignatureValid bool       // stored signature matches DefaultSignature
+	HeaderValid    bool       // header is decoded, and header checksum matches (or was not set)
+	HeaderError    error      // header reading error (nil for valid headers)
+	Complete       bool       // entry is completely written (see VerifyComplete)
+	Format         Format     // file format (zero value, if the format is unknown - see DetectFormat)
+	Header         Header     // header field values (can be partially filled for invalid headers)
+	DataOffset     int64      // data section offset
+	DataSize       int64      // data length in bytes (see File.DataSize)
+	Chunks         int        // number of complete chunks (for chunked data only)
+}
+
+// Inspect reads the named cache file header and describes the file without the data reading, e.g. for the debugging
+// tools. Broken files (with wrong signature, corrupted header or incomplete entry) are described too - only the file
+// reading errors are returned. Signature validity is checked against DefaultSignature (compare Info.Signature for
+// custom signatures).
+func Inspect(name string) (Info, error) { return InspectFS(nil, name) }
+
+// InspectFS inspects the named cache file on passed filesystem, like Inspect does.
+func InspectFS(fsys FS, name string) (Info, error) {
+	h, err := openFile(fsys, name, os.O_RDONLY, 0)
+	if err != nil {
+		return Info{}, err
+	}
+	defer func() { _ = h.Close() }()
+
+	stat, err := h.Stat()
+	if err != nil {
+		return Info{}, err
+	}
+
+	var (
+		f    = newFile(h, nil)
+		info = Info{Size: stat.Size()}
+	)
+
+	if sig, sigErr := f.getSignature(); sigErr == nil && info.Size >= SignatureSize {
+		info.Signature, info.SignatureValid = *sig, bytes.Equal(*sig, DefaultSignature)
+	}
+
+	info.Header, info.HeaderError = ReadHeader(h)
+	info.HeaderValid = info.HeaderError == nil
+	info.Complete = f.VerifyComplete() == nil
+
+	if flags, flagsErr := f.GetFlags(); flagsErr == nil {
+		info.Header.Flags = flags // flags are read even from the invalid header
+	}
+
+	if info.Format, err = DetectFormat(h); err != nil {
+		if errors.Is(err, ErrUnknownFormat) {
+			return info, nil // data layout is unknown
+		}
+
+		return info, err
+	}
+
+	if info.DataOffset, err = f.dataOffset(); err != nil {
+		return info, err
+	}
+
+	if info.Size <= info.DataOffset {
+		return info, nil
+	}
+
+	if info.Format.Chunked {
+		chunks, chunksErr := f.Chunks()
+		if chunksErr != nil {
+			return info, chunksErr
+		}
+
+		info.Chunks = len(chunks)
+	}
+
+	if info.DataSize, err = f.DataSize(); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
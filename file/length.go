@@ -0,0 +1,81 @@
+package file
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Data length is stored in the metadata block as the first entry with the reserved key (it is not returned with the
+// custom metadata), so the data size is read without the file size or chunks reading, truncated data is detected on
+// opening (see VerifyComplete), and files remain readable by the versions without the data length support:
+// +--------------------+-------------------------+--------------------------+---------------------------+---------+
+// | Count 0..1 (LE)    | Key length 2..3 (= 12)  | dataLengthMetaKey 4..15  | Value length 16..17 (= 8) | Length  |
+// +--------------------+-------------------------+--------------------------+---------------------------+---------+
+// Length (int64, LE) is stored at 18..25 of the metadata block.
+
+// dataLengthMetaKey is the reserved metadata key for the data length (keys, started with zero byte, are internal).
+const dataLengthMetaKey = "\x00data-length"
+
+const (
+	dataLengthEntrySize   = 2 + len(dataLengthMetaKey) + 2 + 8              // encoded metadata entry size
+	dataLengthValueOffset = HeaderSize + 2 + 2 + len(dataLengthMetaKey) + 2 // data length offset in the file
+)
+
+// encodeDataLength encodes the data length metadata entry value.
+func encodeDataLength(length int64) string {
+	var buf [8]byte
+
+	binary.LittleEndian.PutUint64(buf[:], uint64(length))
+
+	return string(buf[:])
+}
+
+// hasDataLength reports whether the header buffer (fixed header with the beginning of the metadata block) contains the
+// data length entry.
+func hasDataLength(buf []byte) bool {
+	if len(buf) < dataLengthValueOffset+8 ||
+		binary.LittleEndian.Uint32(buf[MetaLengthOffset:]) < uint32(2+dataLengthEntrySize) {
+		return false
+	}
+
+	entry := buf[HeaderSize+2:]
+
+	return int(binary.LittleEndian.Uint16(entry)) == len(dataLengthMetaKey) &&
+		string(entry[2:2+len(dataLengthMetaKey)]) == dataLengthMetaKey
+}
+
+// readDataLength reads the stored data length (false is returned for the files without it).
+func (file *File) readDataLength() (int64, bool, error) {
+	buf := make([]byte, dataLengthValueOffset+8)
+
+	if n, err := file.osFile.ReadAt(buf, 0); err != nil && (err != io.EOF || n < len(buf)) {
+		if err == io.EOF {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	if !hasDataLength(buf) {
+		return 0, false, nil
+	}
+
+	return int64(binary.LittleEndian.Uint64(buf[dataLengthValueOffset:])), true, nil
+}
+
+// setDataLength updates the stored data length (files without it are not changed).
+func (file *File) setDataLength(length int64) error {
+	_, stored, err := file.readDataLength()
+	if err != nil || !stored {
+		return err
+	}
+
+	if n, err := file.osFile.WriteAt([]byte(encodeDataLength(length)), int64(dataLengthValueOffset)); err != nil {
+		return err
+	} else if n != 8 {
+		return fmt.Errorf("wrong wrote bytes length: required length: %d, wrote: %d", 8, n)
+	}
+
+	return nil
+}
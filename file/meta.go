@@ -0,0 +1,298 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MaxMetaLength is the maximal encoded metadata block length in bytes.
+const MaxMetaLength = 64 * 1024
+
+// Metadata block layout (entries are sorted by keys, the data length entry is the first - see dataLengthMetaKey):
+// +--------------------+---------------------------+-----+-----------------------------+-------+-----+
+// | Count 0..1 (LE)    | Key length (uint16, LE)   | Key | Value length (uint16, LE)   | Value | ... |
+// +--------------------+---------------------------+-----+-----------------------------+-------+-----+
+
+// ErrMetaTooLarge is returned (wrapped) when encoded metadata exceeds MaxMetaLength.
+var ErrMetaTooLarge = errors.New("metadata is too large")
+
+// ErrReservedMetaKey is returned (wrapped) when custom metadata contains the reserved key (see ValidateMeta).
+var ErrReservedMetaKey = errors.New("metadata key is reserved")
+
+// ValidateMeta checks the custom metadata keys: keys, started with zero byte, are reserved for the internal entries
+// (e.g. data length), that are not covered by the header checksum, so they cannot be set by the callers.
+func ValidateMeta(m map[string]string) error {
+	for k := range m {
+		if strings.HasPrefix(k, "\x00") {
+			return fmt.Errorf("%w: %q", ErrReservedMetaKey, k)
+		}
+	}
+
+	return nil
+}
+
+// encodeMeta encodes the metadata into the block (empty metadata is encoded into the empty block).
+func encodeMeta(m map[string]string) ([]byte, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	var (
+		keys   = make([]string, 0, len(m))
+		length = 2
+	)
+
+	for k, v := range m {
+		keys = append(keys, k)
+		length += 2 + len(k) + 2 + len(v)
+	}
+
+	if length > MaxMetaLength {
+		return nil, fmt.Errorf("%w: %d bytes (maximal length is %d)", ErrMetaTooLarge, length, MaxMetaLength)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { // data length entry must be the first one (see dataLengthValueOffset)
+		if keys[i] == dataLengthMetaKey || keys[j] == dataLengthMetaKey {
+			return keys[i] == dataLengthMetaKey
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	var (
+		buf = make([]byte, length)
+		off = 2
+	)
+
+	binary.LittleEndian.PutUint16(buf, uint16(len(keys)))
+
+	for _, k := range keys {
+		for _, s := range [...]string{k, m[k]} {
+			binary.LittleEndian.PutUint16(buf[off:], uint16(len(s)))
+			off += 2 + copy(buf[off+2:], s)
+		}
+	}
+
+	return buf, nil
+}
+
+// decodeMeta decodes the metadata block custom entries (nil is returned for the empty block).
+func decodeMeta(buf []byte) (map[string]string, error) {
+	m, err := decodeMetaBlock(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return customMeta(m), nil
+}
+
+// decodeMetaBlock decodes all the metadata block entries, including internal ones (nil is returned for the empty
+// block).
+func decodeMetaBlock(buf []byte) (map[string]string, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	var malformed = errors.New("malformed metadata block")
+
+	if len(buf) < 2 {
+		return nil, malformed
+	}
+
+	var (
+		count = int(binary.LittleEndian.Uint16(buf))
+		m     = make(map[string]string, count)
+		off   = 2
+	)
+
+	next := func() (string, error) {
+		if off+2 > len(buf) {
+			return "", malformed
+		}
+
+		l := int(binary.LittleEndian.Uint16(buf[off:]))
+
+		if off+2+l > len(buf) {
+			return "", malformed
+		}
+
+		s := string(buf[off+2 : off+2+l])
+		off += 2 + l
+
+		return s, nil
+	}
+
+	for i := 0; i < count; i++ {
+		k, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+// metaValueOffset returns the offset and length of the entry value with passed key in the metadata block (false is
+// returned, if the entry is missing or the block is malformed).
+func metaValueOffset(buf []byte, key string) (int, int, bool) {
+	if len(buf) < 2 {
+		return 0, 0, false
+	}
+
+	var count, off = int(binary.LittleEndian.Uint16(buf)), 2
+
+	for i := 0; i < count; i++ {
+		var lengths [2]int
+
+		for j := range lengths {
+			if off+2 > len(buf) {
+				return 0, 0, false
+			}
+
+			lengths[j] = int(binary.LittleEndian.Uint16(buf[off:]))
+
+			if off+2+lengths[j] > len(buf) {
+				return 0, 0, false
+			}
+
+			if j == 1 && string(buf[off-lengths[0]:off]) == key {
+				return off + 2, lengths[1], true
+			}
+
+			off += 2 + lengths[j]
+		}
+	}
+
+	return 0, 0, false
+}
+
+// customMeta removes internal entries (keys, started with zero byte) from the decoded metadata (nil is returned, when
+// custom entries are missing).
+func customMeta(m map[string]string) map[string]string {
+	for k := range m {
+		if strings.HasPrefix(k, "\x00") {
+			delete(m, k)
+		}
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// MaxKeyLength is the maximal entry key length in bytes.
+const MaxKeyLength = 0xFFFF
+
+// blockLengths returns the metadata and key blocks lengths.
+func (file *File) blockLengths() (int64, int64, error) {
+	var (
+		from = MetaLengthOffset
+		buf  = make([]byte, KeyLengthOffset+KeyLengthSize-from)
+	)
+
+	if _, err := file.osFile.ReadAt(buf, int64(from)); err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+
+	var (
+		meta = int64(binary.LittleEndian.Uint32(buf[MetaLengthOffset-from:]))
+		key  = int64(binary.LittleEndian.Uint16(buf[KeyLengthOffset-from:]))
+	)
+
+	if meta > MaxMetaLength {
+		return 0, 0, fmt.Errorf("%w: wrong metadata block length %d", ErrDataCorrupted, meta)
+	}
+
+	return meta, key, nil
+}
+
+// dataOffset returns the data section offset (right after the metadata and key blocks).
+func (file *File) dataOffset() (int64, error) {
+	meta, key, err := file.blockLengths()
+	if err != nil {
+		return 0, err
+	}
+
+	return HeaderSize + meta + key, nil
+}
+
+// GetKey returns the original entry key (empty, if key was not set). Key can be set on the whole entry writing only
+// (see Header.Key).
+func (file *File) GetKey() (string, error) {
+	meta, key, err := file.blockLengths()
+	if err != nil || key == 0 {
+		return "", err
+	}
+
+	buf := make([]byte, key)
+
+	if _, err := file.osFile.ReadAt(buf, HeaderSize+meta); err != nil {
+		if err == io.EOF {
+			return "", fmt.Errorf("%w: key block is truncated", ErrDataCorrupted)
+		}
+
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// SetKey overwrites the original entry key in place. New key must be of the same length, as the stored one (data
+// follows the key block, so it cannot be resized without the data moving).
+func (file *File) SetKey(key string) error {
+	meta, length, err := file.blockLengths()
+	if err != nil {
+		return err
+	}
+
+	if int64(len(key)) != length {
+		return fmt.Errorf("key length %d does not match the stored key length %d", len(key), length)
+	}
+
+	if length == 0 {
+		return nil
+	}
+
+	_, err = file.osFile.WriteAt([]byte(key), HeaderSize+meta)
+
+	return err
+}
+
+// GetMeta returns the custom metadata (nil, if metadata was not set). Metadata can be set on the whole entry writing
+// only (see Header.Meta), because data follows the metadata block.
+func (file *File) GetMeta() (map[string]string, error) {
+	l, _, err := file.blockLengths()
+	if err != nil || l == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, l)
+
+	if _, err := file.osFile.ReadAt(buf, HeaderSize); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: metadata block is truncated", ErrDataCorrupted)
+		}
+
+		return nil, err
+	}
+
+	m, err := decodeMeta(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDataCorrupted, err)
+	}
+
+	return m, nil
+}
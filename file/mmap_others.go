@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+// mmap is not supported on this platform (regular reading must be used).
+func mmap(*os.File, int) ([]byte, error) { return nil, errors.New("memory mapping is not supported") }
+
+// munmap is not supported on this platform.
+func munmap([]byte) error { return nil }
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmap maps the first size bytes of the osFile into memory (read-only).
+func mmap(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmap unmaps the memory, mapped by mmap.
+func munmap(b []byte) error { return syscall.Munmap(b) }
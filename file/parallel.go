@@ -0,0 +1,129 @@
+package file
+
+import (
+	"io"
+	"sync"
+)
+
+const (
+	// parallelWriteWorkers is the number of concurrent data writers (see SetDataFrom).
+	parallelWriteWorkers = 4
+
+	// parallelPartSize is the data part length, written by a single writer call (see SetDataFrom).
+	parallelPartSize = 8 * 1024 * 1024
+)
+
+// SetDataFrom sets the osFile data of passed size, reading it from r in parallel: data parts are written by multiple
+// concurrent WriteAt workers, while the data hash sum is calculated by an extra worker, that reads r sequentially (so
+// the stored hash is a regular SHA1, and readers are not affected). It is much faster, than SetData, for large
+// payloads on fast (NVMe) disks, and r must be safe for concurrent ReadAt calls (e.g. *os.File or *bytes.Reader).
+// Payloads up to 8 MiB are written sequentially. Chunked data format is replaced with the regular one.
+// io.ErrUnexpectedEOF is returned, if r contains less than size bytes.
+func (file *File) SetDataFrom(r io.ReaderAt, size int64) error {
+	if size <= parallelPartSize {
+		return file.setData(io.NewSectionReader(r, 0, size))
+	}
+
+	if flags, err := file.GetFlags(); err == nil && flags.Has(FlagChunked) {
+		if err := file.SetFlags(flags.Without(FlagChunked)); err != nil {
+			return err
+		}
+	}
+
+	off, err := file.dataOffset()
+	if err != nil {
+		return err
+	}
+
+	// previous data can be longer (or shorter) than new, and parts can be written in any order
+	if err = file.osFile.Truncate(off + size); err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sum      []byte
+		parts    = make(chan int64)
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return firstErr != nil
+	}
+
+	wg.Add(parallelWriteWorkers + 1)
+
+	go func() { // hashing worker
+		defer wg.Done()
+
+		hashing, buf := getHasher(), getBuffer()
+		defer func() { putHasher(hashing); putBuffer(buf) }()
+
+		if n, hashErr := io.CopyBuffer(hashing, io.NewSectionReader(r, 0, size), *buf); hashErr != nil {
+			fail(hashErr)
+		} else if n < size {
+			fail(io.ErrUnexpectedEOF)
+		} else {
+			sum = hashing.Sum(nil)
+		}
+	}()
+
+	for i := 0; i < parallelWriteWorkers; i++ {
+		go func() {
+			defer wg.Done()
+
+			buf := getBuffer()
+			defer putBuffer(buf)
+
+			for partOff := range parts {
+				if failed() {
+					continue // parts channel must be drained
+				}
+
+				partLen := size - partOff
+				if partLen > parallelPartSize {
+					partLen = parallelPartSize
+				}
+
+				n, copyErr := io.CopyBuffer(
+					&offsetWriter{f: file.osFile, off: off + partOff}, io.NewSectionReader(r, partOff, partLen), *buf,
+				)
+
+				if copyErr != nil {
+					fail(copyErr)
+				} else if n < partLen {
+					fail(io.ErrUnexpectedEOF)
+				}
+			}
+		}()
+	}
+
+	for partOff := int64(0); partOff < size && !failed(); partOff += parallelPartSize {
+		parts <- partOff
+	}
+
+	close(parts)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if err = file.setDataLength(size); err != nil {
+		return err
+	}
+
+	return file.setDataSHA1(sum)
+}
@@ -0,0 +1,448 @@
+package filecachetest
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+	"github.com/tarampampam/go-filecache/file"
+)
+
+type (
+	// MemoryBackend is the in-memory pool files storage (see filecache.WithBackend), so pools can be tested without
+	// the real filesystem. Files, removed or renamed while they are opened, remain readable by the opened handles (as on
+	// POSIX filesystems).
+	MemoryBackend struct {
+		mu    sync.Mutex
+		files map[string]*memoryData // cleaned path -> file content
+		dirs  map[string]os.FileMode // cleaned path -> permissions
+	}
+
+	// memoryData is the in-memory file content.
+	memoryData struct {
+		mu      sync.RWMutex
+		data    []byte
+		perm    os.FileMode
+		modTime time.Time
+	}
+
+	// memoryHandle is the opened in-memory file.
+	memoryHandle struct {
+		name   string
+		d      *memoryData
+		flag   int
+		closed bool
+		mu     sync.Mutex
+	}
+
+	// memoryAppender is the in-memory file, opened with os.O_APPEND flag (it is written using io.Writer interface).
+	memoryAppender struct{ *memoryHandle }
+
+	// memoryInfo is the in-memory file (or directory) info.
+	memoryInfo struct {
+		name    string
+		size    int64
+		mode    os.FileMode
+		modTime time.Time
+	}
+)
+
+var _ filecache.Backend = (*MemoryBackend)(nil)
+
+// errReadOnly is returned on writing into the file, opened for reading only.
+var errReadOnly = errors.New("file is opened for reading only")
+
+// NewMemoryBackend creates new empty in-memory backend (the root directory exists).
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		files: make(map[string]*memoryData),
+		dirs:  map[string]os.FileMode{".": os.ModePerm, string(filepath.Separator): os.ModePerm},
+	}
+}
+
+// OpenFile opens the named file with specified flags and permissions, like os.OpenFile.
+func (b *MemoryBackend) OpenFile(name string, flag int, perm os.FileMode) (file.Handle, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var path = filepath.Clean(name)
+
+	if _, isDir := b.dirs[path]; isDir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	d, exists := b.files[path]
+
+	switch {
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+
+	case !exists:
+		if _, ok := b.dirs[filepath.Dir(path)]; !ok {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+
+		d = &memoryData{perm: perm.Perm(), modTime: time.Now()}
+		b.files[path] = d
+	}
+
+	h := &memoryHandle{name: name, d: d, flag: flag}
+
+	if flag&os.O_TRUNC != 0 && h.writable() {
+		_ = h.Truncate(0)
+	}
+
+	if flag&os.O_APPEND != 0 {
+		return memoryAppender{h}, nil
+	}
+
+	return h, nil
+}
+
+// Stat returns the named file (or directory) info.
+func (b *MemoryBackend) Stat(name string) (os.FileInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var path = filepath.Clean(name)
+
+	if perm, ok := b.dirs[path]; ok {
+		return memoryInfo{name: filepath.Base(path), mode: os.ModeDir | perm}, nil
+	}
+
+	if d, ok := b.files[path]; ok {
+		return d.info(filepath.Base(path)), nil
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// Remove removes the named file (or empty directory).
+func (b *MemoryBackend) Remove(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var path = filepath.Clean(name)
+
+	if _, ok := b.files[path]; ok {
+		delete(b.files, path)
+
+		return nil
+	}
+
+	if _, ok := b.dirs[path]; ok {
+		if len(b.list(path)) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+
+		delete(b.dirs, path)
+
+		return nil
+	}
+
+	return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+}
+
+// Rename renames (moves) the file, replacing the existing target file.
+func (b *MemoryBackend) Rename(oldPath, newPath string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var from, to = filepath.Clean(oldPath), filepath.Clean(newPath)
+
+	d, ok := b.files[from]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrNotExist}
+	}
+
+	if _, ok = b.dirs[filepath.Dir(to)]; !ok {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrNotExist}
+	}
+
+	delete(b.files, from)
+	b.files[to] = d
+
+	return nil
+}
+
+// List returns the sorted names of the directory entries.
+func (b *MemoryBackend) List(dirPath string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var path = filepath.Clean(dirPath)
+
+	if _, ok := b.dirs[path]; !ok {
+		return nil, &os.PathError{Op: "open", Path: dirPath, Err: os.ErrNotExist}
+	}
+
+	return b.list(path), nil
+}
+
+// list returns the sorted names of the directory entries (lock must be held).
+func (b *MemoryBackend) list(path string) []string {
+	var names = make([]string, 0)
+
+	for p := range b.files {
+		if filepath.Dir(p) == path {
+			names = append(names, filepath.Base(p))
+		}
+	}
+
+	for p := range b.dirs {
+		if p != path && filepath.Dir(p) == path {
+			names = append(names, filepath.Base(p))
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// MkdirAll creates the directory with all necessary parents.
+func (b *MemoryBackend) MkdirAll(path string, perm os.FileMode) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if _, isFile := b.files[p]; isFile {
+			return &os.PathError{Op: "mkdir", Path: path, Err: errors.New("not a directory")}
+		}
+
+		if _, exists := b.dirs[p]; exists {
+			return nil
+		}
+
+		b.dirs[p] = perm.Perm()
+	}
+}
+
+// Chtimes changes the file modification time (access time is not stored).
+func (b *MemoryBackend) Chtimes(name string, _, mtime time.Time) error {
+	b.mu.Lock()
+	d, ok := b.files[filepath.Clean(name)]
+	b.mu.Unlock()
+
+	if !ok {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrNotExist}
+	}
+
+	d.mu.Lock()
+	d.modTime = mtime
+	d.mu.Unlock()
+
+	return nil
+}
+
+// Chmod changes the file permissions.
+func (b *MemoryBackend) Chmod(name string, mode os.FileMode) error {
+	b.mu.Lock()
+	d, ok := b.files[filepath.Clean(name)]
+	b.mu.Unlock()
+
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+
+	d.mu.Lock()
+	d.perm = mode.Perm()
+	d.mu.Unlock()
+
+	return nil
+}
+
+// Size returns the total size of stored files in bytes.
+func (b *MemoryBackend) Size() (size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, d := range b.files {
+		d.mu.RLock()
+		size += int64(len(d.data))
+		d.mu.RUnlock()
+	}
+
+	return
+}
+
+// info returns the file info.
+func (d *memoryData) info(name string) memoryInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return memoryInfo{name: name, size: int64(len(d.data)), mode: d.perm, modTime: d.modTime}
+}
+
+// check returns an error, if the handle is closed (or it is not writable, for writing operations).
+func (h *memoryHandle) check(op string, write bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case h.closed:
+		return &os.PathError{Op: op, Path: h.name, Err: os.ErrClosed}
+
+	case write && !h.writable():
+		return &os.PathError{Op: op, Path: h.name, Err: errReadOnly}
+	}
+
+	return nil
+}
+
+// writable reports whether the file is opened for writing.
+func (h *memoryHandle) writable() bool { return h.flag&(os.O_WRONLY|os.O_RDWR) != 0 }
+
+// ReadAt implements io.ReaderAt interface.
+func (h *memoryHandle) ReadAt(p []byte, off int64) (int, error) {
+	if err := h.check("read", false); err != nil {
+		return 0, err
+	}
+
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: h.name, Err: errors.New("negative offset")}
+	}
+
+	h.d.mu.RLock()
+	defer h.d.mu.RUnlock()
+
+	if off >= int64(len(h.d.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, h.d.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt interface.
+func (h *memoryHandle) WriteAt(p []byte, off int64) (int, error) {
+	if err := h.check("write", true); err != nil {
+		return 0, err
+	}
+
+	if off < 0 {
+		return 0, &os.PathError{Op: "write", Path: h.name, Err: errors.New("negative offset")}
+	}
+
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+
+	h.d.writeAt(p, off)
+
+	return len(p), nil
+}
+
+// writeAt writes the data at the offset, growing the content (lock must be held).
+func (d *memoryData) writeAt(p []byte, off int64) {
+	if end := off + int64(len(p)); end > int64(len(d.data)) {
+		if end > int64(cap(d.data)) {
+			grown := make([]byte, len(d.data), end*2)
+			copy(grown, d.data)
+			d.data = grown
+		}
+
+		d.data = d.data[:end]
+	}
+
+	copy(d.data[off:], p)
+	d.modTime = time.Now()
+}
+
+// Close closes the handle (the file content is not affected).
+func (h *memoryHandle) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return &os.PathError{Op: "close", Path: h.name, Err: os.ErrClosed}
+	}
+
+	h.closed = true
+
+	return nil
+}
+
+// Name returns the name of the file as presented to OpenFile.
+func (h *memoryHandle) Name() string { return h.name }
+
+// Stat returns the file info.
+func (h *memoryHandle) Stat() (os.FileInfo, error) {
+	if err := h.check("stat", false); err != nil {
+		return nil, err
+	}
+
+	return h.d.info(filepath.Base(h.name)), nil
+}
+
+// Truncate changes the file size.
+func (h *memoryHandle) Truncate(size int64) error {
+	if err := h.check("truncate", true); err != nil {
+		return err
+	}
+
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: h.name, Err: errors.New("negative size")}
+	}
+
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+
+	if size <= int64(len(h.d.data)) {
+		h.d.data = h.d.data[:size]
+	} else {
+		h.d.writeAt(make([]byte, size-int64(len(h.d.data))), int64(len(h.d.data)))
+	}
+
+	h.d.modTime = time.Now()
+
+	return nil
+}
+
+// Write implements io.Writer interface (data is appended atomically).
+func (a memoryAppender) Write(p []byte) (int, error) {
+	if err := a.check("write", true); err != nil {
+		return 0, err
+	}
+
+	a.d.mu.Lock()
+	defer a.d.mu.Unlock()
+
+	a.d.writeAt(p, int64(len(a.d.data)))
+
+	return len(p), nil
+}
+
+// WriteAt returns an error, like os.File does for files, opened with os.O_APPEND flag.
+func (a memoryAppender) WriteAt([]byte, int64) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: a.name, Err: errors.New("invalid use of WriteAt on appending file")}
+}
+
+// Name returns the base name of the file.
+func (i memoryInfo) Name() string { return i.name }
+
+// Size returns the file length in bytes.
+func (i memoryInfo) Size() int64 { return i.size }
+
+// Mode returns the file mode bits.
+func (i memoryInfo) Mode() os.FileMode { return i.mode }
+
+// ModTime returns the file modification time.
+func (i memoryInfo) ModTime() time.Time { return i.modTime }
+
+// IsDir reports whether the info describes a directory.
+func (i memoryInfo) IsDir() bool { return i.mode.IsDir() }
+
+// Sys returns nil (underlying data source is not available).
+func (i memoryInfo) Sys() interface{} { return nil }
@@ -0,0 +1,312 @@
+package filecachetest_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+	"github.com/tarampampam/go-filecache/filecachetest"
+)
+
+func readAll(t *testing.T, b *filecachetest.MemoryBackend, name string) []byte {
+	t.Helper()
+
+	f, err := b.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("cannot open %s: %v", name, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	data, err := ioutil.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil {
+		t.Fatalf("cannot read %s: %v", name, err)
+	}
+
+	return data
+}
+
+func TestMemoryBackendOpenFile(t *testing.T) {
+	b := filecachetest.NewMemoryBackend()
+
+	if _, err := b.OpenFile("/missing", os.O_RDONLY, 0); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+
+	if _, err := b.OpenFile("/dir/file", os.O_RDWR|os.O_CREATE, 0600); !os.IsNotExist(err) {
+		t.Errorf("missing parent directory: want not exist error, got %v", err)
+	}
+
+	f, err := b.OpenFile("/file", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.WriteAt([]byte("world"), 6); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err == nil {
+		t.Error("closed handle closing must fail")
+	}
+
+	if got := readAll(t, b, "/file"); !bytes.Equal(got, []byte("hello\x00world")) {
+		t.Errorf("want %q, got %q", "hello\x00world", got)
+	}
+
+	if _, err = b.OpenFile("/file", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0640); !os.IsExist(err) {
+		t.Errorf("want exist error, got %v", err)
+	}
+
+	info, err := b.Stat("/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != 11 || info.Mode() != 0640 || info.IsDir() || info.Name() != "file" {
+		t.Errorf("wrong file info: size %d, mode %v, dir %t, name %s",
+			info.Size(), info.Mode(), info.IsDir(), info.Name())
+	}
+
+	if b.Size() != 11 {
+		t.Errorf("want total size 11, got %d", b.Size())
+	}
+}
+
+func TestMemoryBackendReadOnlyHandle(t *testing.T) {
+	b := filecachetest.NewMemoryBackend()
+
+	f, err := b.OpenFile("/file", os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = f.Close()
+
+	if f, err = b.OpenFile("/file", os.O_RDONLY, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err = f.WriteAt([]byte("data"), 0); err == nil {
+		t.Error("writing into the read-only handle must fail")
+	}
+
+	if err = f.Truncate(0); err == nil {
+		t.Error("truncating of the read-only handle must fail")
+	}
+}
+
+func TestMemoryBackendTruncateAndAppend(t *testing.T) {
+	b := filecachetest.NewMemoryBackend()
+
+	f, err := b.OpenFile("/file", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.WriteAt([]byte("hello world"), 0)
+
+	if err = f.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = f.Close()
+
+	a, err := b.OpenFile("/file", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, ok := a.(io.Writer)
+	if !ok {
+		t.Fatal("appending handle must implement io.Writer")
+	}
+
+	if _, err = w.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = a.WriteAt([]byte("x"), 0); err == nil {
+		t.Error("WriteAt must fail for the appending handle")
+	}
+
+	_ = a.Close()
+
+	if got := readAll(t, b, "/file"); string(got) != "hello!" {
+		t.Errorf("want %q, got %q", "hello!", got)
+	}
+
+	if f, err = b.OpenFile("/file", os.O_WRONLY|os.O_TRUNC, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = f.Close()
+
+	if got := readAll(t, b, "/file"); len(got) != 0 {
+		t.Errorf("want empty file after truncating, got %q", got)
+	}
+}
+
+func TestMemoryBackendRenameAndRemove(t *testing.T) {
+	b := filecachetest.NewMemoryBackend()
+
+	f, err := b.OpenFile("/old", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.WriteAt([]byte("data"), 0)
+
+	if err = b.Rename("/old", "/missing/new"); !os.IsNotExist(err) {
+		t.Errorf("missing target directory: want not exist error, got %v", err)
+	}
+
+	if err = b.Rename("/old", "/new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = b.Stat("/old"); !os.IsNotExist(err) {
+		t.Errorf("renamed file must not exist, got %v", err)
+	}
+
+	if err = b.Remove("/new"); err != nil {
+		t.Fatal(err)
+	}
+
+	// removed (and renamed) files remain readable by the opened handles
+	buf := make([]byte, 4)
+	if _, err = f.ReadAt(buf, 0); err != nil || string(buf) != "data" {
+		t.Errorf("opened handle: want %q, got %q (%v)", "data", buf, err)
+	}
+
+	_ = f.Close()
+
+	if err = b.Remove("/new"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
+
+func TestMemoryBackendDirectories(t *testing.T) {
+	b := filecachetest.NewMemoryBackend()
+
+	if err := b.MkdirAll("/a/b", 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"/a/b/2", "/a/b/1", "/a/file"} {
+		f, err := b.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = f.Close()
+	}
+
+	if names, err := b.List("/a"); err != nil || !reflect.DeepEqual(names, []string{"b", "file"}) {
+		t.Errorf("want [b file], got %v (%v)", names, err)
+	}
+
+	if names, err := b.List("/a/b"); err != nil || !reflect.DeepEqual(names, []string{"1", "2"}) {
+		t.Errorf("want [1 2], got %v (%v)", names, err)
+	}
+
+	if info, err := b.Stat("/a/b"); err != nil || !info.IsDir() || info.Mode().Perm() != 0750 {
+		t.Errorf("want directory with 0750 permissions, got %v (%v)", info, err)
+	}
+
+	if _, err := b.OpenFile("/a/b", os.O_RDONLY, 0); err == nil {
+		t.Error("directory opening must fail")
+	}
+
+	if err := b.Remove("/a/b"); err == nil {
+		t.Error("non-empty directory removing must fail")
+	}
+
+	if err := b.MkdirAll("/a/file/c", 0750); err == nil {
+		t.Error("directory creation inside the file must fail")
+	}
+}
+
+func TestMemoryBackendChtimesAndChmod(t *testing.T) {
+	b := filecachetest.NewMemoryBackend()
+
+	f, err := b.OpenFile("/file", os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = f.Close()
+
+	var mtime = time.Unix(1600000000, 0)
+
+	if err = b.Chtimes("/file", time.Now(), mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = b.Chmod("/file", 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, _ := b.Stat("/file"); !info.ModTime().Equal(mtime) || info.Mode() != 0644 {
+		t.Errorf("want mtime %v and mode 0644, got %v and %v", mtime, info.ModTime(), info.Mode())
+	}
+
+	if err = b.Chtimes("/missing", time.Now(), mtime); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+
+	if err = b.Chmod("/missing", 0644); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
+
+func TestMemoryBackendPool(t *testing.T) {
+	var (
+		b    = filecachetest.NewMemoryBackend()
+		pool = filecache.NewPool("/cache", filecache.WithBackend(b))
+	)
+	defer func() { _ = pool.Close() }()
+
+	if _, err := pool.Put("key", bytes.NewReader([]byte("value")), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := pool.GetItem("key").Get(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "value" {
+		t.Errorf("want %q, got %q", "value", buf.String())
+	}
+
+	if names, err := b.List("/cache"); err != nil || len(names) == 0 {
+		t.Errorf("item file must be stored in the backend, got %v (%v)", names, err)
+	}
+
+	if _, err := os.Stat("/cache"); !os.IsNotExist(err) {
+		t.Errorf("pool directory must not be created on the operating system filesystem, got %v", err)
+	}
+
+	if ok, err := pool.DeleteItem("key"); err != nil || !ok {
+		t.Fatalf("want deleted item, got %t (%v)", ok, err)
+	}
+
+	if b.Size() != 0 {
+		t.Errorf("want empty backend after the item deleting, got %d bytes", b.Size())
+	}
+}
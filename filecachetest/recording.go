@@ -0,0 +1,251 @@
+// Package filecachetest provides test doubles for the cache pool interactions assertions.
+package filecachetest
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+// Op is the recorded operation name.
+type Op string
+
+// Recorded operations.
+const (
+	OpHasItem       Op = "HasItem"
+	OpClear         Op = "Clear"
+	OpDeleteItem    Op = "DeleteItem"
+	OpPut           Op = "Put"
+	OpPutForever    Op = "PutForever"
+	OpClose         Op = "Close"
+	OpGet           Op = "Get" // item value reading
+	OpIsHit         Op = "IsHit"
+	OpSet           Op = "Set" // item value writing
+	OpSetExpiresAt  Op = "SetExpiresAt"
+	OpDelete        Op = "Delete" // item deleting
+	OpItemExpiresAt Op = "ExpiresAt"
+)
+
+type (
+	// Operation is the recorded cache operation.
+	Operation struct {
+		Op        Op
+		Key       string        // empty for pool-wide operations (Clear, Close)
+		Size      int64         // payload size in bytes (for Put, PutForever, Get and Set)
+		ExpiresAt time.Time     // for Put and SetExpiresAt (zero value means "without expiring time")
+		TTL       time.Duration // ExpiresAt minus operation time
+		Hit       bool          // for HasItem and IsHit
+		Err       error
+	}
+
+	// RecordingPool wraps the cache pool and records all operations (including operations on returned items). It
+	// implements filecache.CachePool, and it is safe for concurrent usage.
+	RecordingPool struct {
+		filecache.CachePool
+
+		mu  sync.Mutex
+		ops []Operation
+	}
+
+	// recordingItem wraps the cache item and records operations into the pool.
+	recordingItem struct {
+		filecache.CacheItem
+
+		pool *RecordingPool
+	}
+
+	// countingReader counts read bytes.
+	countingReader struct {
+		r io.Reader
+		n int64
+	}
+
+	// countingWriter counts wrote bytes.
+	countingWriter struct {
+		w io.Writer
+		n int64
+	}
+)
+
+// Interfaces implementation checks.
+var (
+	_ filecache.CachePool = (*RecordingPool)(nil)
+	_ filecache.CacheItem = (*recordingItem)(nil)
+)
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+
+	return n, err
+}
+
+// NewRecordingPool wraps the cache pool (e.g. real pool over the temporary directory).
+func NewRecordingPool(pool filecache.CachePool) *RecordingPool {
+	return &RecordingPool{CachePool: pool}
+}
+
+// record appends the operation (TTL is calculated for non-zero expiration time).
+func (p *RecordingPool) record(op Operation) {
+	if !op.ExpiresAt.IsZero() {
+		op.TTL = time.Until(op.ExpiresAt)
+	}
+
+	p.mu.Lock()
+	p.ops = append(p.ops, op)
+	p.mu.Unlock()
+}
+
+// Operations returns the copy of recorded operations (in the recording order).
+func (p *RecordingPool) Operations() []Operation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Operation(nil), p.ops...)
+}
+
+// Filter returns recorded operations of passed type (and key, empty key means "any key").
+func (p *RecordingPool) Filter(op Op, key string) []Operation {
+	var filtered = make([]Operation, 0)
+
+	for _, o := range p.Operations() {
+		if o.Op == op && (key == "" || o.Key == key) {
+			filtered = append(filtered, o)
+		}
+	}
+
+	return filtered
+}
+
+// Count returns the number of recorded operations of passed type (and key, empty key means "any key").
+func (p *RecordingPool) Count(op Op, key string) int { return len(p.Filter(op, key)) }
+
+// Reset removes all recorded operations.
+func (p *RecordingPool) Reset() {
+	p.mu.Lock()
+	p.ops = nil
+	p.mu.Unlock()
+}
+
+// GetItem returns the recording item wrapper (item getting is not recorded itself).
+func (p *RecordingPool) GetItem(key string) filecache.CacheItem {
+	return &recordingItem{CacheItem: p.CachePool.GetItem(key), pool: p}
+}
+
+// HasItem records and calls the wrapped pool method.
+func (p *RecordingPool) HasItem(key string) bool {
+	hit := p.CachePool.HasItem(key)
+	p.record(Operation{Op: OpHasItem, Key: key, Hit: hit})
+
+	return hit
+}
+
+// Clear records and calls the wrapped pool method.
+func (p *RecordingPool) Clear() (bool, error) {
+	ok, err := p.CachePool.Clear()
+	p.record(Operation{Op: OpClear, Err: err})
+
+	return ok, err
+}
+
+// DeleteItem records and calls the wrapped pool method.
+func (p *RecordingPool) DeleteItem(key string) (bool, error) {
+	ok, err := p.CachePool.DeleteItem(key)
+	p.record(Operation{Op: OpDeleteItem, Key: key, Err: err})
+
+	return ok, err
+}
+
+// Put records and calls the wrapped pool method.
+func (p *RecordingPool) Put(key string, from io.Reader, expiresAt time.Time) (filecache.CacheItem, error) {
+	r := &countingReader{r: from}
+	item, err := p.CachePool.Put(key, r, expiresAt)
+	p.record(Operation{Op: OpPut, Key: key, Size: r.n, ExpiresAt: expiresAt, Err: err})
+
+	return p.wrap(item), err
+}
+
+// PutForever records and calls the wrapped pool method.
+func (p *RecordingPool) PutForever(key string, from io.Reader) (filecache.CacheItem, error) {
+	r := &countingReader{r: from}
+	item, err := p.CachePool.PutForever(key, r)
+	p.record(Operation{Op: OpPutForever, Key: key, Size: r.n, Err: err})
+
+	return p.wrap(item), err
+}
+
+// Close records and calls the wrapped pool method.
+func (p *RecordingPool) Close() error {
+	err := p.CachePool.Close()
+	p.record(Operation{Op: OpClose, Err: err})
+
+	return err
+}
+
+// wrap wraps the item (nil is not wrapped).
+func (p *RecordingPool) wrap(item filecache.CacheItem) filecache.CacheItem {
+	if item == nil {
+		return nil
+	}
+
+	return &recordingItem{CacheItem: item, pool: p}
+}
+
+// Get records and calls the wrapped item method.
+func (i *recordingItem) Get(to io.Writer) error {
+	w := &countingWriter{w: to}
+	err := i.CacheItem.Get(w)
+	i.pool.record(Operation{Op: OpGet, Key: i.GetKey(), Size: w.n, Err: err})
+
+	return err
+}
+
+// IsHit records and calls the wrapped item method.
+func (i *recordingItem) IsHit() bool {
+	hit := i.CacheItem.IsHit()
+	i.pool.record(Operation{Op: OpIsHit, Key: i.GetKey(), Hit: hit})
+
+	return hit
+}
+
+// Set records and calls the wrapped item method.
+func (i *recordingItem) Set(from io.Reader) error {
+	r := &countingReader{r: from}
+	err := i.CacheItem.Set(r)
+	i.pool.record(Operation{Op: OpSet, Key: i.GetKey(), Size: r.n, Err: err})
+
+	return err
+}
+
+// ExpiresAt records and calls the wrapped item method.
+func (i *recordingItem) ExpiresAt() *time.Time {
+	exp := i.CacheItem.ExpiresAt()
+	i.pool.record(Operation{Op: OpItemExpiresAt, Key: i.GetKey()})
+
+	return exp
+}
+
+// SetExpiresAt records and calls the wrapped item method.
+func (i *recordingItem) SetExpiresAt(when time.Time) error {
+	err := i.CacheItem.SetExpiresAt(when)
+	i.pool.record(Operation{Op: OpSetExpiresAt, Key: i.GetKey(), ExpiresAt: when, Err: err})
+
+	return err
+}
+
+// Delete records and calls the wrapped item method.
+func (i *recordingItem) Delete() error {
+	err := i.CacheItem.Delete()
+	i.pool.record(Operation{Op: OpDelete, Key: i.GetKey(), Err: err})
+
+	return err
+}
@@ -0,0 +1,113 @@
+package filecache
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// PutWithGrace puts a cache item with soft (freshUntil) and hard (usableUntil) expiration times. Item is "fresh" until
+// the soft expiration time, and then "stale" (but still can be served, e.g. during origin outages) until the hard
+// expiration time. Zero usableUntil means "without hard expiring time".
+func (pool *Pool) PutWithGrace(key string, from io.Reader, freshUntil, usableUntil time.Time) (CacheItem, error) {
+	return pool.put(key, from, file.Header{ExpiresAt: usableUntil, FreshUntil: freshUntil})
+}
+
+// FreshUntil returns the soft expiration time for this cache item. If soft expiration time doesn't set - the hard
+// expiration time (see ExpiresAt) will be returned, and nil if both are not set.
+func (item *Item) FreshUntil() *time.Time {
+	if !item.pool.acquire() {
+		return nil
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	t, _ := item.freshUntil()
+
+	return t
+}
+
+// freshUntil returns the soft (or hard, if soft is not set) expiration time. Nil means "never expires".
+func (item *Item) freshUntil() (*time.Time, error) {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if t, err := f.GetFreshUntil(); err == nil {
+		return &t, nil
+	}
+
+	if t, err := f.GetExpiresAt(); err == nil {
+		return &t, nil
+	}
+
+	return nil, nil
+}
+
+// IsFresh reports whether the cache item exists and its soft expiration time is not exceeded.
+func (item *Item) IsFresh() bool {
+	if !item.pool.acquire() {
+		return false
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	t, err := item.freshUntil()
+
+	return err == nil && (t == nil || item.pool.now().Before(*t))
+}
+
+// IsStale reports whether the cache item soft expiration time is exceeded, but the hard expiration time is not (item
+// still can be served).
+func (item *Item) IsStale() bool {
+	if !item.pool.acquire() {
+		return false
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	t, err := item.freshUntil()
+	if err != nil || t == nil || item.pool.now().Before(*t) {
+		return false
+	}
+
+	exp, expErr := item.expiresAt()
+
+	return expErr != nil || item.pool.now().Before(*exp) // hard expiration time is not set or not exceeded
+}
+
+// SetFreshUntil sets the soft expiration time for this cache item (zero time means "not set").
+// Important notice: time will set WITHOUT nanoseconds (just milliseconds).
+func (item *Item) SetFreshUntil(when time.Time) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var filePath = item.GetFilePath()
+
+	f, openErr := item.open()
+	if openErr != nil {
+		return newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", filePath), openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if err := f.SetFreshUntil(when); err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package filecache
+
+import (
+	"container/list"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// defaultHandlesIdleTimeout is the default idle timeout of the cached read handles (see WithHandleCache).
+const defaultHandlesIdleTimeout = time.Second * 30
+
+type (
+	// handles is the bounded cache of read-only file descriptors (LRU), shared between the readers of hot items.
+	handles struct {
+		mu       sync.Mutex
+		capacity int
+		idle     time.Duration
+		entries  map[string]*list.Element // path -> *handle element
+		lru      *list.List               // most recently used handles are at the front
+	}
+
+	// handle is the cached read-only file descriptor.
+	handle struct {
+		path   string
+		f      file.Handle
+		info   os.FileInfo // used for the file replacement detection
+		refs   int         // number of handle users
+		usedAt time.Time
+		stale  bool // handle is removed from the cache and must be closed by the last user
+	}
+)
+
+// newHandles creates the read handles cache. Zero (or negative) idle timeout means defaultHandlesIdleTimeout.
+func newHandles(capacity int, idle time.Duration) *handles {
+	if idle <= 0 {
+		idle = defaultHandlesIdleTimeout
+	}
+
+	return &handles{capacity: capacity, idle: idle, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// get returns the shared read-only file descriptor and the function for its releasing (descriptor must not be closed
+// by the caller). Cached descriptor is reused only if the path still points to the same file (files, replaced by
+// renaming or by another process, are reopened). Descriptors are reused for the backends with os.SameFile support only.
+func (hs *handles) get(backend Backend, path string) (file.Handle, func() error, error) {
+	info, statErr := backend.Stat(path)
+	if statErr != nil {
+		hs.forget(path)
+
+		return nil, nil, statErr
+	}
+
+	hs.mu.Lock()
+
+	if el, ok := hs.entries[path]; ok {
+		h := el.Value.(*handle)
+
+		if os.SameFile(h.info, info) {
+			h.refs++
+			hs.lru.MoveToFront(el)
+			hs.mu.Unlock()
+
+			return h.f, hs.releaser(h), nil
+		}
+
+		hs.remove(el)
+	}
+
+	hs.mu.Unlock()
+
+	f, openErr := backend.OpenFile(path, os.O_RDONLY, 0)
+	if openErr != nil {
+		return nil, nil, openErr
+	}
+
+	if info, openErr = f.Stat(); openErr != nil {
+		_ = f.Close()
+
+		return nil, nil, openErr
+	}
+
+	h := &handle{path: path, f: f, info: info, refs: 1}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if el, ok := hs.entries[path]; ok { // opened concurrently
+		hs.remove(el)
+	}
+
+	hs.entries[path] = hs.lru.PushFront(h)
+
+	for el := hs.lru.Back(); hs.lru.Len() > hs.capacity && el != nil; {
+		prev := el.Prev()
+		hs.remove(el) // used handles are closed by their last users
+		el = prev
+	}
+
+	return h.f, hs.releaser(h), nil
+}
+
+// releaser returns the function for the handle releasing (it must be called once).
+func (hs *handles) releaser(h *handle) func() error {
+	var once sync.Once
+
+	return func() (err error) {
+		once.Do(func() {
+			hs.mu.Lock()
+			defer hs.mu.Unlock()
+
+			h.refs--
+			h.usedAt = time.Now()
+
+			if h.stale && h.refs == 0 {
+				err = h.f.Close()
+			}
+		})
+
+		return
+	}
+}
+
+// remove removes the handle element from the cache and closes unused descriptor (hs.mu must be locked).
+func (hs *handles) remove(el *list.Element) {
+	h := hs.lru.Remove(el).(*handle)
+	delete(hs.entries, h.path)
+
+	h.stale = true
+
+	if h.refs == 0 {
+		_ = h.f.Close()
+	}
+}
+
+// forget removes the cached descriptor for the path (it must be called before the file removing or renaming, because
+// opened files cannot be removed on some platforms).
+func (hs *handles) forget(path string) {
+	if hs == nil {
+		return
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if el, ok := hs.entries[path]; ok {
+		hs.remove(el)
+	}
+}
+
+// sweep closes the descriptors, unused during the idle timeout.
+func (hs *handles) sweep(now time.Time) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	for el := hs.lru.Back(); el != nil; {
+		prev := el.Prev()
+
+		if h := el.Value.(*handle); h.refs == 0 && now.Sub(h.usedAt) >= hs.idle {
+			hs.remove(el)
+		}
+
+		el = prev
+	}
+}
+
+// close removes all cached descriptors (used descriptors are closed by their last users).
+func (hs *handles) close() {
+	if hs == nil {
+		return
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	for el := hs.lru.Back(); el != nil; {
+		prev := el.Prev()
+		hs.remove(el)
+		el = prev
+	}
+}
+
+// startHandlesSweep starts the background worker, closing idle cached read handles (if handles cache is enabled).
+func (pool *Pool) startHandlesSweep() {
+	if pool.handles == nil {
+		return
+	}
+
+	pool.workers.Add(1)
+
+	go func() {
+		defer pool.workers.Done()
+
+		interval := pool.handles.idle / 2
+		if interval <= 0 {
+			interval = pool.handles.idle
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-pool.done:
+				return
+
+			case now := <-ticker.C:
+				pool.handles.sweep(now)
+			}
+		}
+	}()
+}
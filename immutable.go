@@ -0,0 +1,36 @@
+package filecache
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// PutImmutable puts a write-once cache item with expiring time (zero means "without expiring time"): subsequent
+// writes of the item value (Put, Set, Append, etc.) fail with ErrImmutable error, until the item expires or is
+// invalidated (deleting is allowed). It is useful for content-addressed caches, where keys are content hash sums.
+func (pool *Pool) PutImmutable(key string, from io.Reader, expiresAt time.Time) (CacheItem, error) {
+	return pool.put(key, from, file.Header{ExpiresAt: expiresAt, Flags: file.FlagImmutable})
+}
+
+// mutable returns ErrImmutable error, if the item file exists and is marked as immutable (expired and invalidated
+// items are mutable).
+func (item *Item) mutable() error {
+	f, err := item.openRead()
+	if err != nil {
+		return nil // missing (and unreadable) files are rewritten
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if flags, err := f.GetFlags(); err != nil || !flags.Has(file.FlagImmutable) || item.fileInvalidated(f) {
+		return nil
+	}
+
+	if exp, err := f.GetExpiresAt(); err == nil && exp.Before(item.pool.now()) {
+		return nil
+	}
+
+	return newError(ErrImmutable, fmt.Sprintf("file [%s] is immutable", item.GetFilePath()), nil)
+}
@@ -0,0 +1,555 @@
+package filecache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// indexFileName is the name of the file (inside cache directory) for the pool index storing.
+const indexFileName = "filecache.index"
+
+// indexSignature is the index file signature (and format version).
+var indexSignature = []byte("#/FCIDX2") //nolint:gochecknoglobals
+
+// Index records operations.
+const (
+	indexOpSet    byte = 'S'
+	indexOpDelete byte = 'D'
+)
+
+// indexCompactionThreshold is the minimal number of dead records in the index file for its compaction.
+const indexCompactionThreshold = 1024
+
+type (
+	// indexEntry contains cache item file metadata, stored in the pool index.
+	indexEntry struct {
+		Size      int64     // data size in bytes
+		ExpiresAt time.Time // zero value means "not set"
+		ModTime   time.Time // file modification time
+		CreatedAt time.Time // entry creation time (file modification time for the entries, written without it)
+		Epoch     uint32
+		Flags     file.Flags
+	}
+
+	// index is the append-only (and periodically compacted) log of cache item files metadata. In-memory state follows
+	// the index file changes, made by another processes (new records are read on every access, and the whole file is
+	// re-read after its compaction).
+	index struct {
+		mu      sync.Mutex
+		pool    *Pool
+		entries map[string]indexEntry // file name to the entry
+		records int                   // records in the index file
+		offset  int64                 // read offset in the index file
+		info    os.FileInfo           // index file info (for replacing detection)
+		out     file.Handle           // index file, opened for appending
+		loaded  bool
+
+		rebuilding *indexRebuild // running rebuild (nil, if the index is not being rebuilt)
+		persistErr error         // rebuilt index writing error (the directory is not scanned again after it)
+	}
+
+	// indexRebuild is the running index rebuild: concurrent rebuilds wait for it and share its result.
+	indexRebuild struct {
+		done chan struct{} // closed, when the rebuild is finished
+		err  error
+	}
+)
+
+// outdated reports whether the entry is expired or stamped with an epoch, older than passed.
+func (e indexEntry) outdated(now time.Time, epoch uint32) bool {
+	return e.Epoch < epoch || (!e.ExpiresAt.IsZero() && e.ExpiresAt.Before(now))
+}
+
+// RebuildIndex scans the pool directory and rewrites the pool index (it does nothing, if index is disabled).
+func (pool *Pool) RebuildIndex() error { return pool.RebuildIndexContext(context.Background()) }
+
+// RebuildIndexContext scans the pool directory and rewrites the pool index, respecting context cancellation.
+func (pool *Pool) RebuildIndexContext(ctx context.Context) error {
+	if !pool.acquire() {
+		return errPoolClosed()
+	}
+	defer pool.release()
+
+	if pool.index == nil {
+		return nil
+	}
+
+	return pool.index.rebuild(ctx)
+}
+
+// newIndex creates index for the pool (index file will be read on the first access).
+func newIndex(pool *Pool) *index { return &index{pool: pool} }
+
+func (idx *index) filePath() string { return filepath.Join(idx.pool.dirPath, indexFileName) }
+
+// isItemFileName reports whether the file name is an item file name (staged and backup files are not indexed).
+func isItemFileName(name string) bool {
+	_, ok := trimItemFileExt(name)
+
+	return ok && isCacheFileName(name)
+}
+
+// get returns the index entry for the file name.
+func (idx *index) get(name string) (indexEntry, bool, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.sync(); err != nil {
+		return indexEntry{}, false, err
+	}
+
+	e, ok := idx.entries[name]
+
+	return e, ok, nil
+}
+
+// snapshot returns the copy of all index entries.
+func (idx *index) snapshot() (map[string]indexEntry, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.sync(); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]indexEntry, len(idx.entries))
+	for name, e := range idx.entries {
+		entries[name] = e
+	}
+
+	return entries, nil
+}
+
+// refresh reads the cache item file header and updates the index entry. Index is nil-safe, and non-item files are
+// ignored.
+func (idx *index) refresh(path string) {
+	if idx == nil || !isItemFileName(filepath.Base(path)) {
+		return
+	}
+
+	e, err := idx.pool.readIndexEntry(path)
+	if err != nil {
+		idx.forget(path)
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.sync(); err == nil {
+		idx.entries[filepath.Base(path)] = e
+		_ = idx.append(indexOpSet, filepath.Base(path), e)
+	}
+}
+
+// forget removes the index entry for the file. Index is nil-safe, and non-item files are ignored.
+func (idx *index) forget(path string) {
+	if idx == nil || !isItemFileName(filepath.Base(path)) {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.sync(); err == nil {
+		if _, exists := idx.entries[filepath.Base(path)]; exists {
+			delete(idx.entries, filepath.Base(path))
+			_ = idx.append(indexOpDelete, filepath.Base(path), indexEntry{})
+		}
+	}
+}
+
+// rebuild scans the pool directory and rewrites the index file.
+func (idx *index) rebuild(ctx context.Context) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.persistErr = nil // explicit rebuild retries the index file writing
+
+	return idx.rebuildLocked(ctx)
+}
+
+// rebuildLocked rebuilds the index, when the lock is held (lock is released during the directory scanning). Only one
+// rebuild is running at a time - concurrent callers wait for the running one and get its result. Index file writing
+// error is remembered, so the index is not rebuilt on every access (e.g. in the read-only directories) - sync returns
+// it, and the callers fall back to the directory walking.
+func (idx *index) rebuildLocked(ctx context.Context) error {
+	if running := idx.rebuilding; running != nil {
+		idx.mu.Unlock()
+		<-running.done
+		idx.mu.Lock()
+
+		return running.err
+	}
+
+	running := &indexRebuild{done: make(chan struct{})}
+	idx.rebuilding = running
+
+	idx.mu.Unlock()
+	entries, err := idx.scan(ctx)
+	idx.mu.Lock()
+
+	if err == nil {
+		idx.entries = entries
+
+		if err = idx.compact(); err != nil {
+			idx.persistErr = err
+		} else {
+			idx.loaded = true
+		}
+	}
+
+	running.err, idx.rebuilding = err, nil
+	close(running.done)
+
+	return err
+}
+
+// scan reads the index entries of all the cache item files in the pool directory.
+func (idx *index) scan(ctx context.Context) (map[string]indexEntry, error) {
+	var (
+		entries = make(map[string]indexEntry)
+		mu      sync.Mutex
+	)
+
+	if err := idx.pool.walkOverCacheFiles(ctx, func(path string) {
+		if !isItemFileName(filepath.Base(path)) {
+			return
+		}
+
+		if e, err := idx.pool.readIndexEntry(path); err == nil {
+			mu.Lock()
+			entries[filepath.Base(path)] = e
+			mu.Unlock()
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// close closes the index file.
+func (idx *index) close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.out != nil {
+		err := idx.out.Close()
+		idx.out = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// sync loads the index (rebuilds it, if the index file does not exist or it is corrupted, see rebuildLocked) and reads
+// the changes, made by another processes. Lock must be held.
+func (idx *index) sync() error {
+	info, statErr := idx.pool.backend.Stat(idx.filePath())
+
+	if statErr != nil {
+		if !os.IsNotExist(statErr) {
+			return statErr
+		}
+
+		if idx.persistErr != nil { // index cannot be written - directory is not scanned again
+			return idx.persistErr
+		}
+
+		return idx.rebuildLocked(context.Background())
+	}
+
+	// index file was replaced (compacted by another process) - it must be re-read
+	if !idx.loaded || idx.info == nil || !idx.sameFile(info) {
+		idx.entries, idx.records, idx.offset = make(map[string]indexEntry), 0, 0
+
+		if idx.out != nil {
+			_ = idx.out.Close()
+			idx.out = nil
+		}
+	}
+
+	if info.Size() > idx.offset {
+		if err := idx.read(); err != nil {
+			if errors.Is(err, ErrCorrupted) && idx.persistErr == nil {
+				return idx.rebuildLocked(context.Background()) // corrupted index can be restored from the directory
+			}
+
+			return err
+		}
+	}
+
+	idx.info, idx.loaded, idx.persistErr = info, true, nil // index file can be written by another process
+
+	return nil
+}
+
+// sameFile reports whether the index file was not replaced since the last reading. Files of the backends without
+// os.SameFile support are considered replaced, when they are shorter than the read part.
+func (idx *index) sameFile(info os.FileInfo) bool {
+	if idx.pool.onOS() {
+		return os.SameFile(info, idx.info)
+	}
+
+	return info.Size() >= idx.offset
+}
+
+// read reads the index file records, starting from the current offset. Lock must be held.
+func (idx *index) read() error {
+	f, err := idx.pool.backend.OpenFile(idx.filePath(), os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if idx.offset == 0 {
+		sign := make([]byte, len(indexSignature))
+		if _, err := f.ReadAt(sign, 0); err != nil || !bytes.Equal(sign, indexSignature) {
+			return newError(ErrCorrupted, fmt.Sprintf("wrong index file [%s] signature", idx.filePath()), err)
+		}
+
+		idx.offset = int64(len(indexSignature))
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f, idx.offset, math.MaxInt64-idx.offset))
+
+	for {
+		op, name, e, n, err := readIndexRecord(r)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil // the last record can be incomplete (it is being written right now)
+			}
+
+			return newError(ErrCorrupted, fmt.Sprintf("index file [%s] is corrupted", idx.filePath()), err)
+		}
+
+		switch op {
+		case indexOpSet:
+			idx.entries[name] = e
+
+		case indexOpDelete:
+			delete(idx.entries, name)
+		}
+
+		idx.offset += int64(n)
+		idx.records++
+	}
+}
+
+// append appends the record into the index file and compacts the file, when it contains too many dead records. Lock
+// must be held.
+func (idx *index) append(op byte, name string, e indexEntry) error {
+	if dead := idx.records - len(idx.entries); dead > indexCompactionThreshold && dead > len(idx.entries) {
+		return idx.compact()
+	}
+
+	if idx.out == nil {
+		f, err := idx.pool.backend.OpenFile(idx.filePath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, idx.pool.filePerm)
+		if err != nil {
+			return err
+		}
+
+		if err = idx.pool.chown(idx.filePath()); err != nil {
+			_ = f.Close()
+
+			return err
+		}
+
+		idx.out = f
+	}
+
+	rec := encodeIndexRecord(op, name, e)
+
+	if err := appendTo(idx.out, rec); err != nil {
+		return err
+	}
+
+	idx.offset += int64(len(rec))
+	idx.records++
+
+	if info, err := idx.out.Stat(); err == nil {
+		idx.info = info
+	}
+
+	return nil
+}
+
+// compact writes all the entries into the temporary file and replaces the index file with it. Lock must be held.
+func (idx *index) compact() error {
+	var (
+		buf     = bytes.NewBuffer(append([]byte{}, indexSignature...))
+		tmpPath = idx.filePath() + ".tmp"
+	)
+
+	for name, e := range idx.entries {
+		buf.Write(encodeIndexRecord(indexOpSet, name, e))
+	}
+
+	if err := idx.pool.writeFileAtomic(tmpPath, idx.filePath(), buf.Bytes()); err != nil {
+		return writeError(fmt.Sprintf("cannot write index file [%s]", idx.filePath()), err)
+	}
+
+	if idx.out != nil {
+		_ = idx.out.Close()
+		idx.out = nil
+	}
+
+	info, err := idx.pool.backend.Stat(idx.filePath())
+	if err != nil {
+		return err
+	}
+
+	idx.info, idx.offset, idx.records = info, int64(buf.Len()), len(idx.entries)
+
+	return nil
+}
+
+// readIndexEntry reads the index entry values from the cache item file.
+func (pool *Pool) readIndexEntry(path string) (indexEntry, error) {
+	f, err := pool.openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	return pool.fileIndexEntry(f, path)
+}
+
+// fileIndexEntry reads the index entry for the opened cache item file (file signature is verified).
+func (pool *Pool) fileIndexEntry(f *file.File, path string) (indexEntry, error) {
+	if matched, _ := f.SignatureMatched(); !matched {
+		return indexEntry{}, errors.New("wrong file signature")
+	}
+
+	info, err := pool.backend.Stat(path)
+	if err != nil {
+		return indexEntry{}, err
+	}
+
+	e := indexEntry{ModTime: info.ModTime(), CreatedAt: info.ModTime()}
+
+	if created, createdErr := f.GetCreatedAt(); createdErr == nil && !created.IsZero() {
+		e.CreatedAt = created
+	}
+
+	if e.Size, err = dataSize(f); err != nil {
+		return indexEntry{}, err
+	}
+
+	if exp, expErr := f.GetExpiresAt(); expErr == nil {
+		e.ExpiresAt = exp
+	}
+
+	if e.Epoch, err = f.GetEpoch(); err != nil {
+		return indexEntry{}, err
+	}
+
+	if e.Flags, err = f.GetFlags(); err != nil {
+		return indexEntry{}, err
+	}
+
+	return e, nil
+}
+
+// Index record layout: payload length (uint16), payload, payload CRC32 (uint32). Payload layout: operation (byte),
+// name length (byte), name, and for "set" operation - size (int64), expires at (unix ms, int64), modification time
+// (unix ns, int64), epoch (uint32), flags (uint16) and creation time (unix ms, int64).
+const indexEntryLength = 8 + 8 + 8 + 4 + 2 + 8
+
+// encodeIndexRecord encodes the index record.
+func encodeIndexRecord(op byte, name string, e indexEntry) []byte {
+	payload := make([]byte, 0, 2+len(name)+indexEntryLength)
+	payload = append(payload, op, byte(len(name)))
+	payload = append(payload, name...)
+
+	if op == indexOpSet {
+		var exp int64
+		if !e.ExpiresAt.IsZero() {
+			exp = e.ExpiresAt.UnixNano() / int64(time.Millisecond)
+		}
+
+		buf := make([]byte, indexEntryLength)
+		binary.LittleEndian.PutUint64(buf[0:], uint64(e.Size))
+		binary.LittleEndian.PutUint64(buf[8:], uint64(exp))
+		binary.LittleEndian.PutUint64(buf[16:], uint64(e.ModTime.UnixNano()))
+		binary.LittleEndian.PutUint32(buf[24:], e.Epoch)
+		binary.LittleEndian.PutUint16(buf[28:], uint16(e.Flags))
+		binary.LittleEndian.PutUint64(buf[30:], uint64(e.CreatedAt.UnixNano()/int64(time.Millisecond)))
+		payload = append(payload, buf...)
+	}
+
+	rec := make([]byte, 2, 2+len(payload)+4)
+	binary.LittleEndian.PutUint16(rec, uint16(len(payload)))
+	rec = append(rec, payload...)
+
+	sum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sum, crc32.ChecksumIEEE(payload))
+
+	return append(rec, sum...)
+}
+
+// readIndexRecord reads and decodes the index record. Returned n is the record length in bytes.
+func readIndexRecord(r io.Reader) (op byte, name string, e indexEntry, n int, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return
+	}
+
+	payload := make([]byte, int(binary.LittleEndian.Uint16(head[:]))+4)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return
+	}
+
+	sum := binary.LittleEndian.Uint32(payload[len(payload)-4:])
+	if payload = payload[:len(payload)-4]; crc32.ChecksumIEEE(payload) != sum {
+		err = errors.New("index record checksum mismatch")
+		return
+	}
+
+	if len(payload) < 2 || len(payload) < 2+int(payload[1]) {
+		err = errors.New("wrong index record length")
+		return
+	}
+
+	op, name, n = payload[0], string(payload[2:2+int(payload[1])]), 2+len(payload)+4
+
+	if op == indexOpSet {
+		buf := payload[2+len(name):]
+		if len(buf) < indexEntryLength {
+			err = errors.New("wrong index record length")
+			return
+		}
+
+		e.Size = int64(binary.LittleEndian.Uint64(buf[0:]))
+
+		if exp := int64(binary.LittleEndian.Uint64(buf[8:])); exp != 0 {
+			e.ExpiresAt = time.Unix(0, exp*int64(time.Millisecond))
+		}
+
+		e.ModTime = time.Unix(0, int64(binary.LittleEndian.Uint64(buf[16:])))
+		e.Epoch = binary.LittleEndian.Uint32(buf[24:])
+		e.Flags = file.Flags(binary.LittleEndian.Uint16(buf[28:]))
+		e.CreatedAt = time.Unix(0, int64(binary.LittleEndian.Uint64(buf[30:]))*int64(time.Millisecond))
+	}
+
+	return
+}
@@ -0,0 +1,134 @@
+package filecache
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+func TestIndexRecordRoundTrip(t *testing.T) {
+	var now = time.Unix(1600000000, 123456789)
+
+	for name, tt := range map[string]struct {
+		op    byte
+		name  string
+		entry indexEntry
+	}{
+		"set with all fields": {
+			op:   indexOpSet,
+			name: "0123456789abcdef0123456789abcdef.cache",
+			entry: indexEntry{
+				Size:      1 << 40,
+				ExpiresAt: now.Add(time.Hour).Truncate(time.Millisecond),
+				ModTime:   now,
+				CreatedAt: now.Add(-time.Hour).Truncate(time.Millisecond),
+				Epoch:     42,
+				Flags:     file.FlagPinned | file.FlagDetached,
+			},
+		},
+		"set without expiration": {
+			op:    indexOpSet,
+			name:  "0123456789abcdef0123456789abcdef.meta",
+			entry: indexEntry{Size: 1, ModTime: now, CreatedAt: now.Truncate(time.Millisecond)},
+		},
+		"set with empty name": {
+			op:    indexOpSet,
+			entry: indexEntry{ModTime: now, CreatedAt: now.Truncate(time.Millisecond)},
+		},
+		"delete": {
+			op:   indexOpDelete,
+			name: "0123456789abcdef0123456789abcdef.cache",
+		},
+	} {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			rec := encodeIndexRecord(tt.op, tt.name, tt.entry)
+
+			op, gotName, e, n, err := readIndexRecord(bytes.NewReader(rec))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if op != tt.op || gotName != tt.name || n != len(rec) {
+				t.Errorf("want op %q, name %q, length %d; got %q, %q, %d", tt.op, tt.name, len(rec), op, gotName, n)
+			}
+
+			if tt.op == indexOpDelete {
+				return
+			}
+
+			if e.Size != tt.entry.Size || e.Epoch != tt.entry.Epoch || e.Flags != tt.entry.Flags {
+				t.Errorf("want entry %+v, got %+v", tt.entry, e)
+			}
+
+			for field, times := range map[string][2]time.Time{
+				"ExpiresAt": {tt.entry.ExpiresAt, e.ExpiresAt},
+				"ModTime":   {tt.entry.ModTime, e.ModTime},
+				"CreatedAt": {tt.entry.CreatedAt, e.CreatedAt},
+			} {
+				if !times[0].Equal(times[1]) {
+					t.Errorf("want %s %v, got %v", field, times[0], times[1])
+				}
+			}
+		})
+	}
+}
+
+func TestIndexRecordSequence(t *testing.T) {
+	var buf bytes.Buffer
+
+	buf.Write(encodeIndexRecord(indexOpSet, "a.cache", indexEntry{Size: 1, ModTime: time.Unix(1, 0)}))
+	buf.Write(encodeIndexRecord(indexOpDelete, "a.cache", indexEntry{}))
+	buf.Write(encodeIndexRecord(indexOpSet, "b.cache", indexEntry{Size: 2, ModTime: time.Unix(2, 0)}))
+
+	for _, want := range []struct {
+		op   byte
+		name string
+		size int64
+	}{{indexOpSet, "a.cache", 1}, {indexOpDelete, "a.cache", 0}, {indexOpSet, "b.cache", 2}} {
+		op, name, e, _, err := readIndexRecord(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if op != want.op || name != want.name || e.Size != want.size {
+			t.Errorf("want %q %q %d, got %q %q %d", want.op, want.name, want.size, op, name, e.Size)
+		}
+	}
+
+	if _, _, _, _, err := readIndexRecord(&buf); err != io.EOF {
+		t.Errorf("want io.EOF after the last record, got %v", err)
+	}
+}
+
+func TestIndexRecordTruncated(t *testing.T) {
+	rec := encodeIndexRecord(indexOpSet, "a.cache", indexEntry{Size: 1, ModTime: time.Unix(1, 0)})
+
+	for length := 0; length < len(rec); length++ {
+		want := io.ErrUnexpectedEOF
+		if length == 0 {
+			want = io.EOF
+		}
+
+		if _, _, _, _, err := readIndexRecord(bytes.NewReader(rec[:length])); err != want {
+			t.Errorf("record truncated to %d bytes: want %v, got %v", length, want, err)
+		}
+	}
+}
+
+func TestIndexRecordCorrupted(t *testing.T) {
+	rec := encodeIndexRecord(indexOpSet, "a.cache", indexEntry{Size: 1, ModTime: time.Unix(1, 0)})
+
+	for i := range rec {
+		corrupted := append([]byte(nil), rec...)
+		corrupted[i] ^= 0xFF
+
+		if _, _, _, _, err := readIndexRecord(bytes.NewReader(corrupted)); err == nil {
+			t.Errorf("byte %d corruption is not detected", i)
+		}
+	}
+}
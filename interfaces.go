@@ -27,6 +27,12 @@ type CacheItem interface {
 
 	// Sets the expiration time for this cache item.
 	SetExpiresAt(when time.Time) error
+
+	// Removes the associated file.
+	Delete() error
+
+	// Returns the stored value size in bytes.
+	Size() (int64, error)
 }
 
 // Pool generates CacheItemInterface objects
@@ -51,4 +57,65 @@ type CachePool interface {
 
 	// Put a cache item without expiring time.
 	PutForever(key string, from io.Reader) (CacheItem, error)
+
+	// Stops background workers and marks the pool unusable.
+	Close() error
+}
+
+// CacheItemV2 is the CacheItem, that reports cache misses separately from the errors (see Pool.V2).
+type CacheItemV2 interface {
+	// Returns path to the associated file.
+	GetFilePath() string
+
+	// Returns the key for the current cache item.
+	GetKey() string
+
+	// Retrieves the value of the item from the cache associated with this object's key. False (without an error) is
+	// returned for missing, expired and invalidated items.
+	Get(to io.Writer) (found bool, err error)
+
+	// Confirms if the cache item lookup resulted in a cache hit.
+	IsHit() bool
+
+	// Sets the value represented by this cache item.
+	Set(from io.Reader) error
+
+	// Returns the expiration time for this cache item. If expiration doesn't set - nil will be returned.
+	ExpiresAt() *time.Time
+
+	// Sets the expiration time for this cache item.
+	SetExpiresAt(when time.Time) error
+
+	// Removes the associated file.
+	Delete() error
+
+	// Returns the stored value size in bytes.
+	Size() (int64, error)
+}
+
+// CachePoolV2 is the CachePool, that generates CacheItemV2 objects.
+type CachePoolV2 interface {
+	// Returns cache directory path.
+	GetDirPath() string
+
+	// Returns a Cache Item representing the specified key.
+	GetItem(key string) CacheItemV2
+
+	// Confirms if the cache contains specified cache item.
+	HasItem(key string) bool
+
+	// Deletes all items in the pool.
+	Clear() (bool, error)
+
+	// Removes the item from the pool.
+	DeleteItem(key string) (bool, error)
+
+	// Put a cache item with expiring time.
+	Put(key string, from io.Reader, expiresAt time.Time) (CacheItemV2, error)
+
+	// Put a cache item without expiring time.
+	PutForever(key string, from io.Reader) (CacheItemV2, error)
+
+	// Stops background workers and marks the pool unusable.
+	Close() error
 }
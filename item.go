@@ -1,14 +1,14 @@
 package filecache
 
 import (
+	"bytes"
 	"crypto/md5" //nolint:gosec
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"hash"
 	"io"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
 	"github.com/tarampampam/go-filecache/file"
@@ -16,48 +16,126 @@ import (
 
 type Item struct {
 	Pool     CachePool
-	hashing  hash.Hash
+	pool     *Pool
 	fileName string
 	key      string
-	mutex    *sync.Mutex
+	mutex    stripeLock
+	perm     os.FileMode // item file permissions (zero means the pool file permissions, see Pool.PutWithPerms)
 }
 
-// DefaultItemFilePerms is default permissions for file, associated with cache item
+// CorruptionPolicy defines what to do with cache items with corrupted data (data hash sum mismatch).
+type CorruptionPolicy uint8
+
+const (
+	// CorruptionReturnError returns an error to the caller, corrupted item stays in the pool (default policy).
+	CorruptionReturnError CorruptionPolicy = iota
+
+	// CorruptionDelete removes the corrupted item from the pool and returns an error to the caller, so all the following
+	// lookups will be treated as a miss.
+	CorruptionDelete
+
+	// CorruptionCallback passes the corruption error into the user-defined callback (see WithCorruptionCallback).
+	CorruptionCallback
+)
+
+// ExpiredReadPolicy defines what to do on reading of expired cache items (see WithExpiredReadPolicy).
+type ExpiredReadPolicy uint8
+
+const (
+	// ExpiredReadError returns ErrExpired error, expired item stays in the pool (default policy).
+	ExpiredReadError ExpiredReadPolicy = iota
+
+	// ExpiredReadDelete removes the expired item from the pool and returns ErrExpired error.
+	ExpiredReadDelete
+
+	// ExpiredReadAllow returns expired item data (expiration time is checked by Pool.GetItem only).
+	ExpiredReadAllow
+)
+
+const (
+	// cacheFileExt is the cache item files extension.
+	cacheFileExt = ".cache"
+
+	// metaFileExt is the cache item files extension for the split layout (see WithSplitLayout).
+	metaFileExt = ".meta"
+)
+
+// DefaultItemFilePerms is default permissions for file, associated with cache item (it is read on the pool creation,
+// see WithFilePerms).
 var DefaultItemFilePerms os.FileMode = 0664
 
 // DefaultItemFileSignature is default signature for cache files
 var DefaultItemFileSignature file.FSignature = nil
 
 // newItem creates cache item.
-func newItem(pool CachePool, key string) *Item {
+func newItem(pool *Pool, key string) *Item {
+	fileName := pool.itemFileName(key) // generate file name based on hashed key value
+
 	item := &Item{
-		Pool:    pool,
-		hashing: md5.New(), //nolint:gosec
-		key:     key,
-		mutex:   &sync.Mutex{},
+		Pool:     pool,
+		pool:     pool,
+		fileName: fileName,
+		key:      key,
+		mutex:    pool.itemLock(fileName),
 	}
 
-	// generate file name based on hashed key value
-	item.fileName = item.keyToFileName(key)
-
 	return item
 }
 
-// keyToFileName returns file name, based on key name.
-func (item *Item) keyToFileName(key string) string {
-	item.hashing.Reset()
-	_, _ = item.hashing.Write([]byte(key))
-	return hex.EncodeToString(item.hashing.Sum(nil)) + ".cache"
+// keyToFileName returns file name, based on key name, with passed extension.
+func keyToFileName(key, ext string) string {
+	sum := md5.Sum([]byte(key)) //nolint:gosec
+	return hex.EncodeToString(sum[:]) + ext
+}
+
+// itemFileName returns the item file name for the key. Item files are named by the pool layout ("<hash>.meta" for the
+// split layout, see WithSplitLayout), but the existing item file of another layout is used, so values, written before
+// the layout changing, are still readable (such file is renamed on the value writing, see Item.relayout).
+func (pool *Pool) itemFileName(key string) string {
+	var name, other = keyToFileName(key, pool.itemFileExt()), keyToFileName(key, pool.otherItemFileExt())
+
+	if pool.itemFileExists(other) && !pool.itemFileExists(name) {
+		return other
+	}
+
+	return name
+}
+
+// itemFileExists reports whether the item file exists in the pool directory (pool index is used, if it is enabled).
+func (pool *Pool) itemFileExists(name string) bool {
+	if pool.index != nil {
+		if _, exists, err := pool.index.get(name); err == nil {
+			return exists
+		}
+	}
+
+	_, err := pool.backend.Stat(filepath.Join(pool.dirPath, name))
+
+	return err == nil
 }
 
 // GetKey returns the key for the current cache item.
 func (item *Item) GetKey() string { return item.key }
 
+// filePerm returns the item file permissions.
+func (item *Item) filePerm() os.FileMode {
+	if item.perm != 0 {
+		return item.perm
+	}
+
+	return item.pool.filePerm
+}
+
 // GetFilePath returns path to the associated file.
 func (item *Item) GetFilePath() string { return filepath.Join(item.Pool.GetDirPath(), item.fileName) }
 
 // IsHit confirms if the cache item lookup resulted in a cache hit.
 func (item *Item) IsHit() bool {
+	if !item.pool.acquire() {
+		return false
+	}
+	defer item.pool.release()
+
 	item.mutex.Lock() // @todo: blocking is required here?
 	defer item.mutex.Unlock()
 
@@ -65,80 +143,533 @@ func (item *Item) IsHit() bool {
 }
 
 func (item *Item) isHit() bool {
-	// check for file exists
-	if info, err := os.Stat(item.GetFilePath()); err == nil && info.Mode().IsRegular() {
-		return true
+	// check for file exists (files, shorter than the header, are incomplete)
+	if info, err := item.pool.backend.Stat(item.GetFilePath()); err == nil && info.Mode().IsRegular() {
+		return info.Size() >= file.HeaderSize && !item.isInvalidated()
 	}
 
 	return false
 }
 
-// Get retrieves the value of the item from the cache associated with this object's key.
+// IsInvalidated reports whether the item was written before the last pool invalidation (see Pool.InvalidateAll).
+func (item *Item) IsInvalidated() bool {
+	if !item.pool.acquire() {
+		return false
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	return item.isInvalidated()
+}
+
+func (item *Item) isInvalidated() bool {
+	// opening can be skipped, if pool was never invalidated
+	if item.pool.currentEpoch() == 0 {
+		return false
+	}
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return false
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	return item.fileInvalidated(f)
+}
+
+// fileInvalidated reports whether the opened item file is stamped with an older pool epoch.
+func (item *Item) fileInvalidated(f *file.File) bool {
+	current := item.pool.currentEpoch()
+	if current == 0 {
+		return false
+	}
+
+	e, err := f.GetEpoch()
+
+	return err == nil && e < current
+}
+
+// Get retrieves the value of the item from the cache associated with this object's key. ErrNotFound error is returned,
+// if the item does not exist, and ErrExpired - if the item is expired (see WithExpiredReadPolicy).
 func (item *Item) Get(to io.Writer) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
 	item.mutex.Lock()
 	defer item.mutex.Unlock()
 
 	return item.get(to)
 }
 
+// GetMulti retrieves the value of the item (like Get does) into all passed writers at the same time (e.g. HTTP
+// response, hash sum calculator and bytes counter), so the file is read only once. Writing is stopped on the first
+// writer error (see io.MultiWriter).
+func (item *Item) GetMulti(writers ...io.Writer) error { return item.Get(io.MultiWriter(writers...)) }
+
 func (item *Item) get(to io.Writer) error {
 	// try to open file for reading
-	f, openErr := file.OpenRead(item.GetFilePath(), DefaultItemFileSignature)
+	f, openErr := item.openRead()
 	if openErr != nil {
-		return newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", item.GetFilePath()), openErr)
+		return item.openError(openErr)
 	}
 	defer func(f *file.File) { _ = f.Close() }(f)
 
-	if err := f.GetData(to); err != nil {
+	if err := item.readable(f); err != nil {
+		return err
+	}
+
+	var read = f.GetData
+
+	if limit := item.pool.mmapThreshold; limit > 0 {
+		if size, err := f.DataSize(); err == nil && size >= limit {
+			read = f.GetDataMapped
+		}
+	}
+
+	read = item.pool.detached(f, read)
+
+	if flags, err := f.GetFlags(); err == nil && flags&transformFlags != 0 {
+		read = item.pool.decoding(read, flags)
+	}
+
+	if err := read(to); err != nil {
+		if errors.Is(err, file.ErrDataCorrupted) {
+			_ = f.Close() // file must be closed before removing
+
+			return item.onCorruption(
+				newError(ErrCorrupted, fmt.Sprintf("file [%s] data is corrupted", item.GetFilePath()), err),
+			)
+		}
+
 		return newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
 	}
 
+	item.pool.metadata.hit(item.fileName)
+
+	return nil
+}
+
+// readable checks that the opened item file data can be read (signature matches in the strict mode, item is not
+// invalidated, expired or marked as "too large"). File can be closed on error. Access is recorded on success (see
+// WithAccessTracking).
+func (item *Item) readable(f *file.File) error {
+	if item.pool.strictSignature {
+		if matched, err := f.SignatureMatched(); err != nil || !matched {
+			return newError(ErrWrongSignature, fmt.Sprintf("file [%s] has wrong signature", item.GetFilePath()), err)
+		}
+	}
+
+	if item.fileInvalidated(f) {
+		return newError(ErrInvalidated, fmt.Sprintf("file [%s] was invalidated", item.GetFilePath()), nil)
+	}
+
+	if item.pool.expiredReadPolicy != ExpiredReadAllow {
+		if exp, err := f.GetExpiresAt(); err == nil && exp.Before(item.pool.now()) {
+			_ = f.Close() // file must be closed before removing
+
+			return item.onExpiredRead()
+		}
+	}
+
+	if flags, err := f.GetFlags(); err == nil && flags.Has(file.FlagTooLarge) {
+		return newError(ErrTooLarge, fmt.Sprintf("file [%s] contains \"too large\" marker", item.GetFilePath()), nil)
+	}
+
+	item.accessed(f)
+
 	return nil
 }
 
+// getAs retrieves the value, if it is stored with the wanted (or unknown) content type.
+func (item *Item) getAs(want file.ContentType, to io.Writer) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if ct, err := item.contentType(); err == nil && ct != want && ct != file.ContentTypeUnknown {
+		return newError(ErrContentTypeMismatch,
+			fmt.Sprintf("file [%s] contains %s data (%s expected)", item.GetFilePath(), ct, want), nil,
+		)
+	}
+
+	return item.get(to)
+}
+
+// contentType returns the stored data content type.
+func (item *Item) contentType() (file.ContentType, error) {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return file.ContentTypeUnknown, openErr
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	return f.GetContentType()
+}
+
+// onExpiredRead applies the pool expired reads policy and returns an error for the caller.
+func (item *Item) onExpiredRead() error {
+	var filePath = item.GetFilePath()
+
+	if item.pool.expiredReadPolicy == ExpiredReadDelete {
+		if err := item.pool.removeFile(filePath); err != nil && !os.IsNotExist(err) {
+			return newError(ErrExpired,
+				fmt.Sprintf("file [%s] is expired (and cannot be removed: %s)", filePath, err), err,
+			)
+		}
+	}
+
+	return newError(ErrExpired, fmt.Sprintf("file [%s] is expired", filePath), nil)
+}
+
+// openError creates an error for the item file opening error (ErrNotFound type is used for missing and incomplete
+// files, and files with corrupted header are handled according to the corruption policy).
+func (item *Item) openError(err error) error {
+	if os.IsNotExist(err) {
+		return newError(ErrNotFound, fmt.Sprintf("file [%s] does not exist", item.GetFilePath()), err)
+	}
+
+	if errors.Is(err, file.ErrIncompleteEntry) { // partially written items are missed
+		return newError(ErrNotFound, fmt.Sprintf("file [%s] is incomplete", item.GetFilePath()), err)
+	}
+
+	if errors.Is(err, file.ErrCorruptedHeader) { // corrupted items are handled according to the corruption policy
+		return item.onCorruption(
+			newError(ErrCorruptedHeader, fmt.Sprintf("file [%s] header is corrupted", item.GetFilePath()), err),
+		)
+	}
+
+	return newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", item.GetFilePath()), err)
+}
+
+// onCorruption applies the pool corruption policy and returns an error for the caller.
+func (item *Item) onCorruption(err *Error) error {
+	switch item.pool.corruptionPolicy {
+	case CorruptionDelete:
+		if rmErr := item.pool.removeFile(item.GetFilePath()); rmErr != nil && !os.IsNotExist(rmErr) {
+			return newError(ErrCorrupted, fmt.Sprintf("%s (and cannot be removed: %s)", err.Message, rmErr), err.previous)
+		}
+
+	case CorruptionCallback:
+		if item.pool.corruptionCallback != nil {
+			return item.pool.corruptionCallback(item.key, err)
+		}
+	}
+
+	return err
+}
+
+// Delete removes the cache item file. EventDelete is published on success (see WithBroadcaster option).
+func (item *Item) Delete() error {
+	if err := item.lockedDelete(); err != nil {
+		return err
+	}
+
+	return item.pool.publish(EventDelete, item.key)
+}
+
+func (item *Item) lockedDelete() error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	return item.delete()
+}
+
+func (item *Item) delete() error { return item.pool.removeFile(item.GetFilePath()) }
+
 // Set the value represented by this cache item.
 func (item *Item) Set(from io.Reader) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
 	item.mutex.Lock()
 	defer item.mutex.Unlock()
 
 	return item.set(from)
 }
 
-// openOrCreateFile opens OR create file for item
-func (item *Item) openOrCreateFile(filePath string, perm os.FileMode, signature file.FSignature) (*file.File, error) {
-	if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() {
-		opened, openErr := file.Open(filePath, perm, signature)
+// openRead opens item file for reading (retrying on "sharing violation" errors), or takes the cached read handle
+// (see WithHandleCache).
+func (item *Item) openRead() (f *file.File, err error) {
+	if hs := item.pool.handles; hs != nil {
+		h, release, openErr := hs.get(item.pool.backend, item.GetFilePath())
 		if openErr != nil {
-			return nil, newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", filePath), openErr)
+			return nil, openErr
+		}
+
+		f = file.Shared(h, item.pool.signature, release)
+
+		for _, verify := range [...]func() error{f.VerifyHeader, f.VerifyComplete} { // file can be rewritten in place
+			if err = verify(); err != nil {
+				_ = f.Close()
+
+				return nil, err
+			}
 		}
-		return opened, nil
+
+		return f, nil
 	}
 
-	created, createErr := file.Create(filePath, perm, signature)
-	if createErr != nil {
-		return nil, newError(ErrFileWriting, fmt.Sprintf("cannot create file [%s]", filePath), createErr)
+	err = item.pool.retry(func() (openErr error) {
+		f, openErr = item.pool.openFile(item.GetFilePath(), os.O_RDONLY, 0)
+		return
+	})
+
+	return
+}
+
+// open opens item file for reading and writing (retrying on "sharing violation" errors). ErrReadOnly error is returned,
+// when the file cannot be opened for writing.
+func (item *Item) open() (f *file.File, err error) {
+	err = item.pool.retry(func() (openErr error) {
+		f, openErr = item.pool.openFile(item.GetFilePath(), os.O_RDWR, item.filePerm())
+		return
+	})
+
+	if isReadOnlyError(err) {
+		err = newError(ErrReadOnly, fmt.Sprintf("file [%s] cannot be opened for writing", item.GetFilePath()), err)
 	}
-	return created, nil
+
+	return
+}
+
+// openOrCreateFile opens OR create file for item (new file is created with the item key - see Pool.Keys). Files with
+// corrupted header and incomplete files are recreated.
+func (item *Item) openOrCreateFile(filePath string, perm os.FileMode) (*file.File, error) {
+	if err := item.pool.dirError(); err != nil {
+		return nil, err
+	}
+
+	create := func() error {
+		h := file.Header{Key: item.key}
+
+		if createErr := item.pool.writeEntry(filePath, perm, h, bytes.NewReader(nil), 0); createErr != nil {
+			return writeError(fmt.Sprintf("cannot create file [%s]", filePath), createErr)
+		}
+
+		return nil
+	}
+
+	if info, err := item.pool.backend.Stat(filePath); err != nil || !info.Mode().IsRegular() {
+		if err := create(); err != nil {
+			return nil, err
+		}
+	}
+
+	var opened *file.File
+
+	open := func() error {
+		return item.pool.retry(func() (err error) {
+			opened, err = item.pool.openFile(filePath, os.O_RDWR, perm)
+			return
+		})
+	}
+
+	openErr := open()
+	if errors.Is(openErr, file.ErrCorruptedHeader) || errors.Is(openErr, file.ErrIncompleteEntry) {
+		if err := create(); err != nil {
+			return nil, err
+		}
+
+		openErr = open()
+	}
+
+	if openErr != nil {
+		err := newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", filePath), openErr)
+
+		if isReadOnlyError(openErr) {
+			return nil, newError(ErrReadOnly, fmt.Sprintf("file [%s] cannot be opened for writing", filePath), err)
+		}
+
+		return nil, err
+	}
+
+	return opened, nil
 }
 
 func (item *Item) set(from io.Reader) error {
+	return item.writeLimited(from, nil, item.setData)
+}
+
+// setData writes the value (existing item file header values are kept). Values of unknown length (see
+// Item.writeLimited) and transformed values (the original value length is stored, see Item.writeValue) are written
+// into the staged file, and another values are written in place.
+func (item *Item) setData(from io.Reader, fits func() error) error {
+	renamed, err := item.relayout()
+	if err != nil {
+		return err
+	}
+
+	if item.pool.splitLayout {
+		return item.setDetached(from, fits)
+	}
+
+	if fits != nil || len(item.pool.transformers) > 0 {
+		if err = item.setStaged(from, fits); err == nil && renamed {
+			item.removeDataFile()
+		}
+
+		return err
+	}
+
+	err = item.update(func(f *file.File) error {
+		if err := f.SetData(from); err != nil {
+			return writeError(fmt.Sprintf("cannot write into file [%s]", f.Name()), err)
+		}
+
+		if err := f.SetEpoch(item.pool.currentEpoch()); err != nil {
+			return writeError(fmt.Sprintf("cannot write into file [%s]", f.Name()), err)
+		}
+
+		if flags, err := f.GetFlags(); err == nil { // expired immutable value is replaced
+			if newFlags := flags.Without(file.FlagImmutable | dataFlagsMask); newFlags != flags {
+				if err = f.SetFlags(newFlags); err != nil {
+					return writeError(fmt.Sprintf("cannot write into file [%s]", f.Name()), err)
+				}
+			}
+		}
+
+		return item.setVersion(f)
+	})
+
+	if err == nil && renamed {
+		item.removeDataFile()
+	}
+
+	return err
+}
+
+// put writes the value and header values (zero expiration time means "not set") using a single file opening.
+func (item *Item) put(from io.Reader, h file.Header) error {
+	if err := file.ValidateMeta(h.Meta); err != nil {
+		return writeError(fmt.Sprintf("wrong file [%s] metadata", item.GetFilePath()), err)
+	}
+
+	return item.writeLimited(from, &h.ExpiresAt, func(r io.Reader, fits func() error) error {
+		renamed, err := item.relayout()
+		if err != nil {
+			return err
+		}
+
+		if item.pool.splitLayout {
+			return item.writeDetached(r, h, fits)
+		}
+
+		write := func(filePath string) error { return item.writeValue(filePath, r, h) }
+
+		if fits != nil {
+			err = item.writeStaged(write, fits)
+		} else {
+			err = write(item.GetFilePath())
+		}
+
+		if err == nil && renamed {
+			item.removeDataFile()
+		}
+
+		return err
+	})
+}
+
+// setStaged writes the value into the staged file with existing item file header values (like Item.setData does).
+func (item *Item) setStaged(from io.Reader, fits func() error) error {
+	h, err := item.currentHeader()
+	if err != nil {
+		return err
+	}
+
+	h.Flags = h.Flags.Without(file.FlagImmutable | file.FlagChunked) // expired immutable value is replaced
+
+	return item.writeStaged(func(filePath string) error { return item.writeValue(filePath, from, h) }, fits)
+}
+
+// writeStaged writes the whole item file into the staged file using the write function, and replaces the item file
+// with it, if the value fits the limit (nil fits means "value fits", see Item.writeLimited). Staged file is removed
+// otherwise, so the existing value is kept.
+func (item *Item) writeStaged(write func(filePath string) error, fits func() error) error {
 	var filePath = item.GetFilePath()
 
-	f, err := item.openOrCreateFile(filePath, DefaultItemFilePerms, DefaultItemFileSignature)
+	err := write(filePath + stagedFileSuffix)
+	if err == nil && fits != nil {
+		err = fits()
+	}
+
+	if err == nil {
+		err = item.pool.replaceWithStaged(item)
+	}
+
 	if err != nil {
+		_ = item.pool.removeFile(filePath + stagedFileSuffix)
+
 		return err
 	}
+
+	_ = item.pool.removeFile(filePath + backupFileSuffix)
+	item.pool.fileChanged(item.key, filePath)
+
+	return nil
+}
+
+// currentHeader reads the item file header values (zero header is returned for missing or unreadable item files,
+// because they are replaced).
+func (item *Item) currentHeader() (file.Header, error) {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return file.Header{}, nil
+	}
 	defer func(f *file.File) { _ = f.Close() }(f)
 
-	if err := f.SetData(from); err != nil {
-		return newError(ErrFileWriting, fmt.Sprintf("cannot write into file [%s]", filePath), err)
+	h, err := f.GetHeader()
+	if err != nil {
+		return h, newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] header", item.GetFilePath()), err)
+	}
+
+	return h, nil
+}
+
+// writeFile writes the whole item file into the passed path (epoch, version and key header field values will be set
+// automatically, version is based on the current item file version).
+func (item *Item) writeFile(filePath string, from io.Reader, h file.Header) error {
+	if err := item.pool.dirError(); err != nil {
+		return err
 	}
 
+	h.Epoch, h.Key = item.pool.currentEpoch(), item.key
+
+	current, _ := item.version() // zero on error
+	h.Version = uint64(item.pool.nextVersion(current))
+
+	// file opening is retried only (see poolFS), because data may be partially read from the reader on writing errors
+	if err := item.pool.writeEntry(filePath, item.filePerm(), h, from, item.pool.chunkSize); err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
+	}
+
+	item.pool.fileChanged(item.key, filePath)
+
 	return nil
 }
 
 // Indicates if cache item expiration time is exceeded. If expiration data was not set - error will be returned.
 func (item *Item) IsExpired() (bool, error) {
+	if !item.pool.acquire() {
+		return false, errPoolClosed()
+	}
+	defer item.pool.release()
+
 	item.mutex.Lock()
 	defer item.mutex.Unlock()
 
@@ -149,15 +680,49 @@ func (item *Item) isExpired() (bool, error) {
 	exp, expErr := item.expiresAt()
 
 	if exp != nil {
-		return exp.UnixNano() < time.Now().UnixNano(), nil
+		return exp.UnixNano() < item.pool.now().UnixNano(), nil
 	}
 
 	return false, newError(ErrExpirationDataNotAvailable, "expiration data reading error", expErr)
 }
 
+// hasExpired reports whether the item expiration time is exceeded. Unlike IsExpired, items without expiration time
+// (and missing items) are not expired, so only the item file reading errors are returned.
+func (item *Item) hasExpired() (bool, error) {
+	if !item.pool.acquire() {
+		return false, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		if err := item.openError(openErr); !errors.Is(err, ErrNotFound) {
+			return false, err // corrupted items are handled according to the corruption policy
+		}
+
+		return false, nil
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	h, err := f.GetHeader()
+	if err != nil {
+		return false, newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] header", item.GetFilePath()), err)
+	}
+
+	return !h.ExpiresAt.IsZero() && h.ExpiresAt.UnixNano() < item.pool.now().UnixNano(), nil
+}
+
 // ExpiresAt returns the expiration time for this cache item. If expiration doesn't set - nil will be returned.
 // Important notice: returned time will be WITHOUT nanoseconds (just milliseconds).
 func (item *Item) ExpiresAt() *time.Time {
+	if !item.pool.acquire() {
+		return nil
+	}
+	defer item.pool.release()
+
 	item.mutex.Lock()
 	defer item.mutex.Unlock()
 
@@ -166,41 +731,88 @@ func (item *Item) ExpiresAt() *time.Time {
 	return exp
 }
 
-func (item *Item) expiresAt() (*time.Time, error) {
-	f, openErr := file.Open(item.GetFilePath(), DefaultItemFilePerms, DefaultItemFileSignature)
-	if openErr != nil {
-		return nil, openErr
-	}
-	defer func(f *file.File) { _ = f.Close() }(f)
+func (item *Item) expiresAt() (exp *time.Time, err error) {
+	err = item.view(func(f *file.File) error {
+		t, expErr := f.GetExpiresAt()
+		if expErr == nil {
+			exp = &t
+		}
 
-	exp, expErr := f.GetExpiresAt()
+		return expErr
+	})
 
-	if expErr != nil {
-		return nil, expErr
+	return
+}
+
+// Flags returns the flags of this cache item. If flags cannot be read - zero value will be returned.
+func (item *Item) Flags() file.Flags {
+	if !item.pool.acquire() {
+		return 0
 	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	flags, _ := item.flags()
 
-	return &exp, nil
+	return flags
 }
 
-// SetExpiresAt sets the expiration time for this cache item.
-// Important notice: time will set WITHOUT nanoseconds (just milliseconds).
-func (item *Item) SetExpiresAt(when time.Time) error {
+func (item *Item) flags() (flags file.Flags, err error) {
+	err = item.view(func(f *file.File) (flagsErr error) {
+		flags, flagsErr = f.GetFlags()
+		return
+	})
+
+	return
+}
+
+// SetFlags sets (replaces) the flags of this cache item. Item must exist.
+func (item *Item) SetFlags(flags file.Flags) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
 	item.mutex.Lock()
 	defer item.mutex.Unlock()
 
-	return item.setExpiresAt(when)
+	return item.setFlags(flags)
 }
 
-func (item *Item) setExpiresAt(when time.Time) error {
-	f, err := item.openOrCreateFile(item.GetFilePath(), DefaultItemFilePerms, DefaultItemFileSignature)
-	if err != nil {
-		return err
+func (item *Item) setFlags(flags file.Flags) error {
+	var filePath = item.GetFilePath()
+
+	f, openErr := item.open()
+	if openErr != nil {
+		return newError(ErrFileOpening, fmt.Sprintf("file [%s] cannot be opened", filePath), openErr)
 	}
 	defer func(f *file.File) { _ = f.Close() }(f)
 
-	if err := f.SetExpiresAt(when); err != nil {
-		return err
+	if err := f.SetFlags(flags); err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
 	}
 
+	item.pool.fileChanged(item.key, filePath)
+
 	return nil
 }
+
+// SetExpiresAt sets the expiration time for this cache item.
+// Important notice: time will set WITHOUT nanoseconds (just milliseconds).
+func (item *Item) SetExpiresAt(when time.Time) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	return item.setExpiresAt(item.pool.jitter.expiresAt(item.pool.now(), when))
+}
+
+func (item *Item) setExpiresAt(when time.Time) error {
+	return item.update(func(f *file.File) error { return f.SetExpiresAt(when) })
+}
@@ -0,0 +1,64 @@
+package filecache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// jitter randomizes expiration times (see WithTTLJitter).
+type jitter struct {
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	fraction float64
+}
+
+// newJitter creates expiration times randomizer. Fraction must be in (0, 1] range.
+func newJitter(fraction float64) *jitter {
+	return &jitter{rnd: rand.New(rand.NewSource(time.Now().UnixNano())), fraction: fraction} //nolint:gosec
+}
+
+// factor returns random TTL multiplier in [1 - fraction, 1] range (so expiration times can only become earlier).
+func (j *jitter) factor() float64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return 1 - j.fraction*j.rnd.Float64()
+}
+
+// apply shortens the TTL (remaining time since now until t) using passed factor. Zero and past times are not changed.
+func (j *jitter) apply(now, t time.Time, factor float64) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	if ttl := t.Sub(now); ttl > 0 {
+		return now.Add(time.Duration(float64(ttl) * factor))
+	}
+
+	return t
+}
+
+// expiresAt randomizes passed expiration time. Jitter is nil-safe.
+func (j *jitter) expiresAt(now, t time.Time) time.Time {
+	if j == nil {
+		return t
+	}
+
+	return j.apply(now, t, j.factor())
+}
+
+// header randomizes header expiration times (using the same factor, so soft expiration time remains before the hard
+// one). Jitter is nil-safe.
+func (j *jitter) header(now time.Time, h file.Header) file.Header {
+	if j == nil {
+		return h
+	}
+
+	f := j.factor()
+	h.ExpiresAt, h.FreshUntil = j.apply(now, h.ExpiresAt, f), j.apply(now, h.FreshUntil, f)
+
+	return h
+}
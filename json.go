@@ -0,0 +1,15 @@
+package filecache
+
+import "time"
+
+// PutJSON puts the cache item with JSON encoded value (content type marker is stored in the header). Zero (or negative)
+// ttl means "without expiring time".
+func (pool *Pool) PutJSON(key string, v interface{}, ttl time.Duration) error {
+	return pool.PutValueWith(JSONCodec{}, key, v, ttl)
+}
+
+// GetJSON decodes JSON encoded cache item value into dst (pointer is expected). Missing (or expired) item is not an
+// error - false will be returned. Items, stored with another content type, cause ErrContentTypeMismatch error.
+func (pool *Pool) GetJSON(key string, dst interface{}) (bool, error) {
+	return pool.GetValueWith(JSONCodec{}, key, dst)
+}
@@ -0,0 +1,121 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// keysMatcher returns the keys matching function for the pattern: empty pattern matches all keys, pattern with the
+// only trailing "*" matches keys with the prefix (including keys with "/"), and another patterns are matched using
+// path.Match syntax.
+func keysMatcher(pattern string) (func(key string) bool, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern && !strings.ContainsAny(prefix, `*?[\`) {
+		return func(key string) bool { return strings.HasPrefix(key, prefix) }, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	return func(key string) bool {
+		matched, _ := path.Match(pattern, key)
+
+		return matched
+	}, nil
+}
+
+// Keys returns sorted keys of the cache items (expired and invalidated items are skipped), matched with the pattern
+// (e.g. "user:123:*" or "user:*:profile", empty pattern means "all keys"). Item files are opened for the keys reading.
+// Items, written before the keys persisting, have no stored keys and are skipped.
+func (pool *Pool) Keys(pattern string) ([]string, error) {
+	match, err := keysMatcher(pattern)
+	if err != nil {
+		return nil, newError(ErrUnknown, "wrong keys pattern", err)
+	}
+
+	return pool.keys(context.Background(), match)
+}
+
+// keys returns sorted keys of the cache items, matched with the function.
+func (pool *Pool) keys(ctx context.Context, match func(key string) bool) ([]string, error) {
+	if !pool.acquire() {
+		return nil, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		keys  = make([]string, 0)
+		mu    sync.Mutex
+		epoch = pool.currentEpoch()
+	)
+
+	if err := pool.walkOverCacheFiles(ctx, func(filePath string) {
+		if !isItemFileName(filepath.Base(filePath)) {
+			return
+		}
+
+		if key := pool.readFileKey(filePath); key != "" && match(key) && !pool.fileOutdated(filePath, pool.now(), epoch) {
+			mu.Lock()
+			keys = append(keys, key)
+			mu.Unlock()
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// readFileKey returns the key, stored in the cache item file (empty on any error).
+func (pool *Pool) readFileKey(filePath string) string {
+	f, err := pool.openFile(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return ""
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if matched, _ := f.SignatureMatched(); !matched {
+		return ""
+	}
+
+	key, _ := f.GetKey()
+
+	return key
+}
+
+// DeleteByPrefix removes the cache items with keys, started with the prefix (see Keys), and returns the number of
+// removed items. Removing is continued on errors, and the last error is returned.
+func (pool *Pool) DeleteByPrefix(prefix string) (int, error) {
+	keys, err := pool.keys(context.Background(), func(key string) bool { return strings.HasPrefix(key, prefix) })
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		removed int
+		lastErr error
+	)
+
+	for _, key := range keys {
+		if _, rmErr := pool.DeleteItem(key); rmErr != nil {
+			lastErr = rmErr
+		} else {
+			removed++
+		}
+	}
+
+	return removed, lastErr
+}
@@ -0,0 +1,84 @@
+package filecache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// LinkTo publishes the item value into the passed path (e.g. build artifact into the workspace) as a hard link to
+// the item raw data file, so large values are not copied. Only the values, stored in the separate raw data file
+// (see WithSplitLayout), can be linked - ErrNotSupported error is returned for another items (use ExportTo for them)
+// and for the pools with custom backends (see WithBackend). Destination file is replaced atomically, linked
+// data is not verified, and it must not be modified in place (because it is shared with the cache).
+func (item *Item) LinkTo(path string) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if !item.pool.onOS() {
+		return newError(ErrNotSupported, "hard links are not supported by the pool backend", nil)
+	}
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return item.openError(openErr)
+	}
+
+	err := item.readable(f)
+	if err == nil {
+		if flags, flagsErr := f.GetFlags(); flagsErr != nil {
+			err = newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] flags", item.GetFilePath()), flagsErr)
+		} else if !flags.Has(file.FlagDetached) {
+			err = newError(ErrNotSupported, fmt.Sprintf(
+				"file [%s] data is not detached (it must be written with the split layout)", item.GetFilePath(),
+			), nil)
+		}
+	}
+
+	_ = f.Close()
+
+	if err != nil {
+		return err
+	}
+
+	if err = linkFile(item.dataFilePath(), path); err != nil {
+		return writeError(fmt.Sprintf("cannot link file [%s] into [%s]", item.dataFilePath(), path), err)
+	}
+
+	return nil
+}
+
+// linkFile creates a hard link to the src file (existing dst file is replaced atomically).
+func linkFile(src, dst string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmp.Name()
+
+	// temporary file name is reserved only, because hard links cannot replace existing files
+	if err = tmp.Close(); err == nil {
+		err = os.Remove(tmpPath)
+	}
+
+	if err == nil {
+		if err = os.Link(src, tmpPath); err == nil {
+			err = os.Rename(tmpPath, dst)
+		}
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+
+	return err
+}
@@ -0,0 +1,62 @@
+package filecache
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Lookup returns the cache item state using a single file opening (or without opening, if the pool index is enabled).
+// Expired and invalidated items are reported as not existing, but expiration time and value size are returned for
+// expired items. Missing item is not an error.
+func (pool *Pool) Lookup(key string) (exists bool, expiresAt *time.Time, size int64, err error) {
+	if !pool.acquire() {
+		return false, nil, 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	item := newItem(pool, key)
+
+	if pool.index != nil {
+		if e, indexed, indexErr := pool.index.get(item.fileName); indexErr == nil {
+			if !indexed || e.Epoch < pool.currentEpoch() {
+				return false, nil, 0, nil
+			}
+
+			if !e.ExpiresAt.IsZero() {
+				expiresAt = &e.ExpiresAt
+			}
+
+			return expiresAt == nil || pool.now().Before(*expiresAt), expiresAt, e.Size, nil
+		}
+	}
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		if os.IsNotExist(openErr) {
+			return false, nil, 0, nil
+		}
+
+		return false, nil, 0, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if item.fileInvalidated(f) {
+		return false, nil, 0, nil
+	}
+
+	if size, err = dataSize(f); err != nil {
+		return false, nil, 0, newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
+	}
+
+	if exp, expErr := f.GetExpiresAt(); expErr == nil {
+		expiresAt = &exp
+	}
+
+	return expiresAt == nil || pool.now().Before(*expiresAt), expiresAt, size, nil
+}
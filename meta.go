@@ -0,0 +1,230 @@
+package filecache
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// MetaContentType is the custom metadata key for the value content type (MIME type, e.g. "application/json").
+const MetaContentType = "content-type"
+
+// PutWithMeta puts a cache item with expiring time (zero means "without expiring time") and custom metadata (e.g.
+// content type, encoding or origin URL), stored in the item file header. Keys, started with zero byte, are reserved
+// (see file.ValidateMeta).
+func (pool *Pool) PutWithMeta(
+	key string, from io.Reader, expiresAt time.Time, meta map[string]string,
+) (CacheItem, error) {
+	return pool.put(key, from, file.Header{ExpiresAt: expiresAt, Meta: meta})
+}
+
+// PutWithContentType puts a cache item with expiring time (zero means "without expiring time") and the value content
+// type (MIME type), stored in the custom metadata (see MetaContentType).
+func (pool *Pool) PutWithContentType(
+	key string, from io.Reader, expiresAt time.Time, contentType string,
+) (CacheItem, error) {
+	return pool.PutWithMeta(key, from, expiresAt, map[string]string{MetaContentType: contentType})
+}
+
+// dataReader streams the cache item file data (data hash sum is verified at the end of reading).
+type dataReader struct {
+	*io.PipeReader
+	f      *file.File
+	copied chan struct{}
+}
+
+// Close stops the data streaming and closes the cache item file.
+func (r *dataReader) Close() error {
+	_ = r.PipeReader.Close()
+	<-r.copied
+
+	return r.f.Close()
+}
+
+// GetReader opens the cache item and returns the value reader and stored content type (empty, if it is unknown - see
+// PutWithContentType). Data hash sum is verified at the end of reading (corruption error is returned by the reader
+// instead of io.EOF). Returned reader must be closed.
+func (item *Item) GetReader() (io.ReadCloser, string, error) {
+	if !item.pool.acquire() {
+		return nil, "", errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	r, h, err := item.openReader()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return r, h.Meta[MetaContentType], nil
+}
+
+// openReader opens the cache item file and returns the value reader with the header values (see GetReader).
+func (item *Item) openReader() (io.ReadCloser, file.Header, error) {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return nil, file.Header{}, item.openError(openErr)
+	}
+
+	if err := item.readable(f); err != nil {
+		_ = f.Close()
+
+		return nil, file.Header{}, err
+	}
+
+	h, err := f.GetHeader()
+	if err != nil {
+		_ = f.Close()
+
+		return nil, h, newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] header", item.GetFilePath()), err)
+	}
+
+	var (
+		pr, pw = io.Pipe()
+		r      = &dataReader{PipeReader: pr, f: f, copied: make(chan struct{})}
+	)
+
+	go func() {
+		defer close(r.copied)
+
+		_ = pw.CloseWithError(item.pool.detached(f, f.GetData)(pw))
+	}()
+
+	var rc io.ReadCloser = r
+
+	if h.Flags&transformFlags != 0 {
+		decoded, err := item.pool.decode(r, h.Flags)
+		if err != nil {
+			_ = r.Close()
+
+			return nil, h, newError(ErrFileReading, fmt.Sprintf("file [%s] decoding error", item.GetFilePath()), err)
+		}
+
+		rc = &decodedReader{Reader: decoded, Closer: r}
+	}
+
+	item.pool.metadata.hit(item.fileName)
+
+	return rc, h, nil
+}
+
+// GetMeta returns the cache item custom metadata (nil, if metadata was not set).
+func (item *Item) GetMeta() (map[string]string, error) {
+	if !item.pool.acquire() {
+		return nil, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return nil, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	meta, err := f.GetMeta()
+	if err != nil {
+		return nil, newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] metadata", item.GetFilePath()), err)
+	}
+
+	return meta, nil
+}
+
+// SetMeta sets (replaces) the cache item custom metadata (nil means "remove metadata"). Metadata is stored before the
+// data, so the item file is rewritten (data is verified and copied into the staged file, that replaces the item file).
+// Keys, started with zero byte, are reserved (see file.ValidateMeta).
+func (item *Item) SetMeta(meta map[string]string) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	return item.setMeta(meta)
+}
+
+func (item *Item) setMeta(meta map[string]string) error {
+	var filePath = item.GetFilePath()
+
+	if err := file.ValidateMeta(meta); err != nil {
+		return writeError(fmt.Sprintf("wrong file [%s] metadata", filePath), err)
+	}
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return item.openError(openErr)
+	}
+
+	if item.fileInvalidated(f) { // rewriting must not "revive" invalidated item
+		_ = f.Close()
+
+		return newError(ErrInvalidated, fmt.Sprintf("file [%s] was invalidated", filePath), nil)
+	}
+
+	h, err := f.GetHeader()
+	if err != nil {
+		_ = f.Close()
+
+		return newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] header", filePath), err)
+	}
+
+	h.Meta = meta
+
+	return item.rewrite(f, h)
+}
+
+// rewrite copies the opened file data (it is verified) into the staged file with passed header values, and replaces
+// the item file with it (epoch, version and key header field values are set automatically). Opened file is closed.
+func (item *Item) rewrite(f *file.File, h file.Header) error {
+	var filePath = item.GetFilePath()
+
+	h.Flags = h.Flags.Without(file.FlagChunked) // data format is defined by the pool settings
+
+	var read = f.GetData
+
+	if isDetached(f) && dataPath(f.Name()) != item.dataFilePath() { // raw data file of another item cannot be shared
+		read, h.Flags = item.pool.detached(f, f.GetData), h.Flags.Without(file.FlagDetached)
+	}
+
+	var (
+		pr, pw = io.Pipe()
+		copied = make(chan struct{})
+	)
+
+	go func() {
+		defer close(copied)
+
+		_ = pw.CloseWithError(read(pw))
+	}()
+
+	err := item.writeFile(filePath+stagedFileSuffix, pr, h)
+
+	_ = pr.CloseWithError(io.ErrClosedPipe) // data copying must be stopped on writing error
+	<-copied
+	_ = f.Close() // file must be closed before replacing
+
+	if err != nil {
+		_ = item.pool.removeFile(filePath + stagedFileSuffix)
+
+		return err
+	}
+
+	if err := item.pool.replaceWithStaged(item); err != nil {
+		_ = item.pool.removeFile(filePath + stagedFileSuffix)
+
+		return err
+	}
+
+	_ = item.pool.removeFile(filePath + backupFileSuffix)
+	item.pool.fileChanged(item.key, filePath)
+
+	return nil
+}
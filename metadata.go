@@ -0,0 +1,268 @@
+package filecache
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Metadata contains cache item statistics, stored in the metadata store (payloads remain in the item files).
+	Metadata struct {
+		Key        string    // empty, if unknown (e.g. item was written by another process)
+		Size       int64     // value size in bytes
+		ExpiresAt  time.Time // zero value means "without expiring time"
+		ModTime    time.Time // item file modification time
+		AccessedAt time.Time // last successful read time
+		Hits       uint64    // successful reads count
+		Tags       []string
+	}
+
+	// MetadataStore stores cache items metadata by item file names (hashed keys). Implementations must be safe for
+	// concurrent usage. It allows to keep metadata for a large number of items outside the cache directory scanning
+	// (see NewFileMetadataStore; bbolt or SQLite database adapters should implement this interface too).
+	MetadataStore interface {
+		// Get returns metadata for the item file name.
+		Get(name string) (Metadata, bool, error)
+
+		// Set stores metadata for the item file name.
+		Set(name string, m Metadata) error
+
+		// Delete removes metadata for the item file name (missing metadata is not an error).
+		Delete(name string) error
+
+		// Range calls fn for every stored metadata, until fn returns false.
+		Range(fn func(name string, m Metadata) bool) error
+
+		// Len returns the number of stored metadata entries.
+		Len() (int, error)
+	}
+
+	// memoryMetadataStore is in-memory MetadataStore implementation.
+	memoryMetadataStore struct {
+		mu      sync.RWMutex
+		entries map[string]Metadata
+	}
+
+	// metadata wraps the metadata store and records pool changes into it.
+	metadata struct {
+		mu    sync.Mutex // serializes read-modify-write operations
+		store MetadataStore
+		pool  *Pool
+	}
+)
+
+// NewMemoryMetadataStore creates in-memory metadata store (metadata will be lost on the process exit, see
+// NewFileMetadataStore for the persistent one).
+func NewMemoryMetadataStore() MetadataStore {
+	return &memoryMetadataStore{entries: make(map[string]Metadata)}
+}
+
+// Get returns metadata for the item file name.
+func (s *memoryMetadataStore) Get(name string) (Metadata, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, exists := s.entries[name]
+
+	return m, exists, nil
+}
+
+// Set stores metadata for the item file name.
+func (s *memoryMetadataStore) Set(name string, m Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[name] = m
+
+	return nil
+}
+
+// Delete removes metadata for the item file name.
+func (s *memoryMetadataStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, name)
+
+	return nil
+}
+
+// Range calls fn for every stored metadata, until fn returns false.
+func (s *memoryMetadataStore) Range(fn func(name string, m Metadata) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for name, m := range s.entries {
+		if !fn(name, m) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of stored metadata entries.
+func (s *memoryMetadataStore) Len() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.entries), nil
+}
+
+// changed reads the cache item file header and updates metadata (tags and access statistics are preserved). Metadata
+// is nil-safe, and non-item files are ignored. Empty key means "unknown" - metadata is updated only if it exists.
+func (md *metadata) changed(key, path string) {
+	if md == nil || !isItemFileName(filepath.Base(path)) {
+		return
+	}
+
+	e, err := md.pool.readIndexEntry(path)
+	if err != nil {
+		md.forget(path)
+		return
+	}
+
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	m, exists, err := md.store.Get(filepath.Base(path))
+	if err != nil || (!exists && key == "") {
+		return
+	}
+
+	if key != "" {
+		m.Key = key
+	}
+
+	m.Size, m.ExpiresAt, m.ModTime = e.Size, e.ExpiresAt, e.ModTime
+
+	_ = md.store.Set(filepath.Base(path), m)
+}
+
+// hit records successful item reading. Metadata is nil-safe.
+func (md *metadata) hit(name string) {
+	if md == nil {
+		return
+	}
+
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	if m, exists, err := md.store.Get(name); err == nil && exists {
+		m.Hits, m.AccessedAt = m.Hits+1, md.pool.now()
+
+		_ = md.store.Set(name, m)
+	}
+}
+
+// forget removes metadata for the file. Metadata is nil-safe, and non-item files are ignored.
+func (md *metadata) forget(path string) {
+	if md == nil || !isItemFileName(filepath.Base(path)) {
+		return
+	}
+
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	_ = md.store.Delete(filepath.Base(path))
+}
+
+// errMetadataDisabled returns an error for metadata operations without metadata store.
+func errMetadataDisabled() error {
+	return newError(ErrUnknown, "metadata store is not configured (see WithMetadataStore option)", nil)
+}
+
+// Metadata returns the cache item metadata (metadata store must be configured with WithMetadataStore option).
+func (pool *Pool) Metadata(key string) (Metadata, bool, error) {
+	if !pool.acquire() {
+		return Metadata{}, false, errPoolClosed()
+	}
+	defer pool.release()
+
+	if pool.metadata == nil {
+		return Metadata{}, false, errMetadataDisabled()
+	}
+
+	return pool.metadata.store.Get(pool.itemFileName(key))
+}
+
+// SetTags replaces the cache item tags. Metadata for the item must exist (item must be written by the pool with
+// configured metadata store).
+func (pool *Pool) SetTags(key string, tags ...string) error {
+	if !pool.acquire() {
+		return errPoolClosed()
+	}
+	defer pool.release()
+
+	if pool.metadata == nil {
+		return errMetadataDisabled()
+	}
+
+	pool.metadata.mu.Lock()
+	defer pool.metadata.mu.Unlock()
+
+	var name = pool.itemFileName(key)
+
+	m, exists, err := pool.metadata.store.Get(name)
+	if err != nil {
+		return newError(ErrUnknown, fmt.Sprintf("cannot read metadata for the key [%s]", key), err)
+	} else if !exists {
+		return newError(ErrUnknown, fmt.Sprintf("metadata for the key [%s] was not found", key), nil)
+	}
+
+	m.Tags = append([]string(nil), tags...)
+
+	if err := pool.metadata.store.Set(name, m); err != nil {
+		return newError(ErrUnknown, fmt.Sprintf("cannot write metadata for the key [%s]", key), err)
+	}
+
+	return nil
+}
+
+// KeysByTag returns sorted keys of the cache items, tagged with passed tag.
+func (pool *Pool) KeysByTag(tag string) ([]string, error) {
+	if !pool.acquire() {
+		return nil, errPoolClosed()
+	}
+	defer pool.release()
+
+	if pool.metadata == nil {
+		return nil, errMetadataDisabled()
+	}
+
+	var keys = make([]string, 0)
+
+	if err := pool.metadata.store.Range(func(_ string, m Metadata) bool {
+		for _, t := range m.Tags {
+			if t == tag && m.Key != "" {
+				keys = append(keys, m.Key)
+				break
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, newError(ErrUnknown, "cannot read metadata", err)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// MetadataCount returns the number of cache items in the metadata store (without cache directory scanning).
+func (pool *Pool) MetadataCount() (int, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	if pool.metadata == nil {
+		return 0, errMetadataDisabled()
+	}
+
+	return pool.metadata.store.Len()
+}
@@ -0,0 +1,223 @@
+package filecache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Metadata log records operations.
+const (
+	metadataOpSet    = "S"
+	metadataOpDelete = "D"
+)
+
+// metadataCompactionThreshold is the minimal number of dead records in the metadata log file for its compaction.
+const metadataCompactionThreshold = 1024
+
+type (
+	// FileMetadataStore is the persistent MetadataStore implementation: metadata is held in memory and every change is
+	// appended into the log file (JSON lines), that is replayed on opening and periodically compacted, so tags, access
+	// statistics and sizes survive the process restarts. The log file must be used by a single process at a time, and
+	// the store must be closed after usage.
+	FileMetadataStore struct {
+		mem     memoryMetadataStore
+		mu      sync.Mutex // serializes log file writes
+		path    string
+		out     *os.File
+		records int // records in the log file
+	}
+
+	// metadataRecord is the metadata log file record.
+	metadataRecord struct {
+		Op   string    `json:"op"`
+		Name string    `json:"name"`
+		Meta *Metadata `json:"meta,omitempty"`
+	}
+)
+
+// NewFileMetadataStore opens (or creates) the persistent metadata store, backed by the named log file. Incomplete (or
+// broken) last record, written on the crash, is ignored.
+func NewFileMetadataStore(path string) (*FileMetadataStore, error) {
+	s := &FileMetadataStore{mem: memoryMetadataStore{entries: make(map[string]Metadata)}, path: path}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, DefaultItemFilePerms)
+	if err != nil {
+		return nil, err
+	}
+
+	s.out = out
+
+	return s, nil
+}
+
+// load replays the log file records (missing file means "empty store").
+func (s *FileMetadataStore) load() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var rec metadataRecord
+
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			break // the last record can be incomplete
+		}
+
+		switch {
+		case rec.Op == metadataOpSet && rec.Meta != nil:
+			s.mem.entries[rec.Name] = *rec.Meta
+
+		case rec.Op == metadataOpDelete:
+			delete(s.mem.entries, rec.Name)
+		}
+
+		s.records++
+	}
+
+	return scanner.Err()
+}
+
+// Get returns metadata for the item file name.
+func (s *FileMetadataStore) Get(name string) (Metadata, bool, error) { return s.mem.Get(name) }
+
+// Set stores metadata for the item file name.
+func (s *FileMetadataStore) Set(name string, m Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.mem.Set(name, m) // compaction writes the in-memory state
+
+	return s.append(metadataRecord{Op: metadataOpSet, Name: name, Meta: &m})
+}
+
+// Delete removes metadata for the item file name.
+func (s *FileMetadataStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists, _ := s.mem.Get(name); !exists {
+		return nil
+	}
+
+	_ = s.mem.Delete(name)
+
+	return s.append(metadataRecord{Op: metadataOpDelete, Name: name})
+}
+
+// Range calls fn for every stored metadata, until fn returns false.
+func (s *FileMetadataStore) Range(fn func(name string, m Metadata) bool) error {
+	return s.mem.Range(fn)
+}
+
+// Len returns the number of stored metadata entries.
+func (s *FileMetadataStore) Len() (int, error) { return s.mem.Len() }
+
+// Close closes the log file (the store cannot be changed after closing).
+func (s *FileMetadataStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.out == nil {
+		return nil
+	}
+
+	err := s.out.Close()
+	s.out = nil
+
+	return err
+}
+
+// append appends the record into the log file and compacts the file, when it contains too many dead records. Lock
+// must be held.
+func (s *FileMetadataStore) append(rec metadataRecord) error {
+	if s.out == nil {
+		return fmt.Errorf("metadata store [%s] is closed", s.path)
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.out.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	s.records++
+
+	if n, _ := s.mem.Len(); s.records-n > metadataCompactionThreshold && s.records-n > n {
+		return s.compact()
+	}
+
+	return nil
+}
+
+// compact writes all the entries into the temporary file and replaces the log file with it. Lock must be held.
+func (s *FileMetadataStore) compact() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".filecache-meta-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // removing fails after the successful renaming
+
+	var (
+		w       = bufio.NewWriter(tmp)
+		enc     = json.NewEncoder(w)
+		records int
+	)
+
+	_ = s.mem.Range(func(name string, m Metadata) bool {
+		records++
+		err = enc.Encode(metadataRecord{Op: metadataOpSet, Name: name, Meta: &m})
+
+		return err == nil
+	})
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	_ = s.out.Close()
+	s.out, s.records = out, records
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package migrate provides the cache pools migration helper: entries of the source pool are rewritten into the
+// destination pool, so the destination pool settings (data format, checksums, directory, etc.) are applied to them.
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+type (
+	// Option allows to setup the migration.
+	Option func(*options)
+
+	options struct {
+		dryRun   bool
+		move     bool
+		progress func(p Progress)
+	}
+
+	// Progress describes the migration progress (it is passed to the progress callback after every entry).
+	Progress struct {
+		Key   string
+		Done  int   // number of processed entries
+		Total int   // total number of entries
+		Err   error // entry migration error (nil on success)
+	}
+
+	// Report is the migration result.
+	Report struct {
+		Total    int // total number of entries
+		Migrated int // number of migrated entries (or entries, that would be migrated in dry-run mode)
+		Failed   int // number of failed entries
+	}
+)
+
+// WithDryRun enables dry-run mode: entries are listed and reported, but not written.
+func WithDryRun() Option { return func(o *options) { o.dryRun = true } }
+
+// WithMove enables source entries removing after successful migration.
+func WithMove() Option { return func(o *options) { o.move = true } }
+
+// WithProgress sets the progress callback.
+func WithProgress(fn func(p Progress)) Option { return func(o *options) { o.progress = fn } }
+
+// Run migrates all entries of the source pool into the destination pool. Entries are streamed with their expiration
+// times and metadata (see filecache.Pool.CopyTo). Migration is continued on entries errors (they are counted in the
+// report and passed to the progress callback). Entries, written before the keys persisting, can not be migrated (see
+// filecache.Pool.Keys), and source and destination directories must differ.
+func Run(src, dst *filecache.Pool, opts ...Option) (Report, error) {
+	var o options
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if filepath.Clean(src.GetDirPath()) == filepath.Clean(dst.GetDirPath()) {
+		return Report{}, errors.New("source and destination directories must differ")
+	}
+
+	keys, err := src.Keys("")
+	if err != nil {
+		return Report{}, err
+	}
+
+	var report = Report{Total: len(keys)}
+
+	for i, key := range keys {
+		var keyErr error
+
+		switch {
+		case o.dryRun:
+		case o.move:
+			keyErr = src.MoveTo(dst, key)
+		default:
+			keyErr = src.CopyTo(dst, key)
+		}
+
+		if keyErr != nil {
+			report.Failed++
+		} else {
+			report.Migrated++
+		}
+
+		if o.progress != nil {
+			o.progress(Progress{Key: key, Done: i + 1, Total: len(keys), Err: keyErr})
+		}
+	}
+
+	return report, nil
+}
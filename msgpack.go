@@ -0,0 +1,1033 @@
+package filecache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessagePack format (see https://github.com/msgpack/msgpack/blob/master/spec.md) type markers.
+const (
+	msgpackNil      byte = 0xc0
+	msgpackFalse    byte = 0xc2
+	msgpackTrue     byte = 0xc3
+	msgpackBin8     byte = 0xc4
+	msgpackBin16    byte = 0xc5
+	msgpackBin32    byte = 0xc6
+	msgpackExt8     byte = 0xc7
+	msgpackExt16    byte = 0xc8
+	msgpackExt32    byte = 0xc9
+	msgpackFloat32  byte = 0xca
+	msgpackFloat64  byte = 0xcb
+	msgpackUint8    byte = 0xcc
+	msgpackUint16   byte = 0xcd
+	msgpackUint32   byte = 0xce
+	msgpackUint64   byte = 0xcf
+	msgpackInt8     byte = 0xd0
+	msgpackInt16    byte = 0xd1
+	msgpackInt32    byte = 0xd2
+	msgpackInt64    byte = 0xd3
+	msgpackFixExt1  byte = 0xd4
+	msgpackFixExt2  byte = 0xd5
+	msgpackFixExt4  byte = 0xd6
+	msgpackFixExt8  byte = 0xd7
+	msgpackFixExt16 byte = 0xd8
+	msgpackStr8     byte = 0xd9
+	msgpackStr16    byte = 0xda
+	msgpackStr32    byte = 0xdb
+	msgpackArray16  byte = 0xdc
+	msgpackArray32  byte = 0xdd
+	msgpackMap16    byte = 0xde
+	msgpackMap32    byte = 0xdf
+
+	msgpackTimestampExt byte = 0xff // timestamp extension type (-1) for time.Time values
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{}) //nolint:gochecknoglobals
+
+	msgpackFieldsCache sync.Map //nolint:gochecknoglobals // struct type fields (reflect.Type -> []msgpackField)
+)
+
+type (
+	// msgpackEncoder encodes values into the MessagePack format (see MsgpackCodec).
+	msgpackEncoder struct{ buf bytes.Buffer }
+
+	// msgpackDecoder decodes MessagePack encoded data.
+	msgpackDecoder struct {
+		data []byte
+		off  int
+	}
+
+	// msgpackField is the encoded struct field.
+	msgpackField struct {
+		name      string
+		index     []int
+		omitEmpty bool
+	}
+)
+
+// msgpackFields returns the encoded struct fields (embedded structs without the name tag are flattened).
+func msgpackFields(t reflect.Type) []msgpackField {
+	if cached, ok := msgpackFieldsCache.Load(t); ok {
+		return cached.([]msgpackField)
+	}
+
+	var fields []msgpackField
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("msgpack")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, opts = tag[:comma], tag[comma+1:]
+		}
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			for _, f := range msgpackFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+
+			continue
+		}
+
+		if sf.PkgPath != "" { // unexported field
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, msgpackField{name: name, index: []int{i}, omitEmpty: opts == "omitempty"})
+	}
+
+	msgpackFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+// isEmptyValue reports whether the value is empty (for the "omitempty" fields).
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+func (e *msgpackEncoder) writeByte(b byte) { _ = e.buf.WriteByte(b) }
+
+// writeLength writes the length with one of passed markers (fix marker is used, if the length is less than fixMax).
+func (e *msgpackEncoder) writeLength(n int, fix byte, fixMax int, m8, m16, m32 byte) {
+	switch {
+	case n < fixMax:
+		e.writeByte(fix | byte(n))
+	case m8 != 0 && n <= math.MaxUint8:
+		e.writeByte(m8)
+		e.writeByte(byte(n))
+	case n <= math.MaxUint16:
+		e.writeByte(m16)
+		e.writeUint16(uint16(n))
+	default:
+		e.writeByte(m32)
+		e.writeUint32(uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) writeUint16(v uint16) {
+	var b [2]byte
+
+	binary.BigEndian.PutUint16(b[:], v)
+	_, _ = e.buf.Write(b[:])
+}
+
+func (e *msgpackEncoder) writeUint32(v uint32) {
+	var b [4]byte
+
+	binary.BigEndian.PutUint32(b[:], v)
+	_, _ = e.buf.Write(b[:])
+}
+
+func (e *msgpackEncoder) writeUint64(v uint64) {
+	var b [8]byte
+
+	binary.BigEndian.PutUint64(b[:], v)
+	_, _ = e.buf.Write(b[:])
+}
+
+func (e *msgpackEncoder) encodeUint(v uint64) {
+	switch {
+	case v <= math.MaxInt8:
+		e.writeByte(byte(v))
+	case v <= math.MaxUint8:
+		e.writeByte(msgpackUint8)
+		e.writeByte(byte(v))
+	case v <= math.MaxUint16:
+		e.writeByte(msgpackUint16)
+		e.writeUint16(uint16(v))
+	case v <= math.MaxUint32:
+		e.writeByte(msgpackUint32)
+		e.writeUint32(uint32(v))
+	default:
+		e.writeByte(msgpackUint64)
+		e.writeUint64(v)
+	}
+}
+
+func (e *msgpackEncoder) encodeInt(v int64) {
+	switch {
+	case v >= 0:
+		e.encodeUint(uint64(v))
+	case v >= -32:
+		e.writeByte(byte(v)) // negative fixint
+	case v >= math.MinInt8:
+		e.writeByte(msgpackInt8)
+		e.writeByte(byte(v))
+	case v >= math.MinInt16:
+		e.writeByte(msgpackInt16)
+		e.writeUint16(uint16(v))
+	case v >= math.MinInt32:
+		e.writeByte(msgpackInt32)
+		e.writeUint32(uint32(v))
+	default:
+		e.writeByte(msgpackInt64)
+		e.writeUint64(uint64(v))
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	e.writeLength(len(s), 0xa0, 32, msgpackStr8, msgpackStr16, msgpackStr32)
+	_, _ = e.buf.WriteString(s)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	e.writeLength(len(b), msgpackBin8, 0, msgpackBin8, msgpackBin16, msgpackBin32)
+	_, _ = e.buf.Write(b)
+}
+
+// encodeTime encodes the time using the smallest timestamp extension format.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+
+	if uint64(sec)>>34 == 0 {
+		if data := nsec<<34 | uint64(sec); data>>32 == 0 {
+			e.writeByte(msgpackFixExt4)
+			e.writeByte(msgpackTimestampExt)
+			e.writeUint32(uint32(data))
+		} else {
+			e.writeByte(msgpackFixExt8)
+			e.writeByte(msgpackTimestampExt)
+			e.writeUint64(data)
+		}
+
+		return
+	}
+
+	e.writeByte(msgpackExt8)
+	e.writeByte(12)
+	e.writeByte(msgpackTimestampExt)
+	e.writeUint32(uint32(nsec))
+	e.writeUint64(uint64(sec))
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		e.writeByte(msgpackNil)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.writeByte(msgpackNil)
+
+			return nil
+		}
+
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.writeByte(msgpackTrue)
+		} else {
+			e.writeByte(msgpackFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.writeByte(msgpackFloat32)
+		e.writeUint32(math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.writeByte(msgpackFloat64)
+		e.writeUint64(math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.writeByte(msgpackNil)
+
+			return nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+
+			return nil
+		}
+
+		return e.encodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			e.encodeBytes(b)
+
+			return nil
+		}
+
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.writeByte(msgpackNil)
+
+			return nil
+		}
+
+		return e.encodeMap(v)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			e.encodeTime(v.Interface().(time.Time))
+
+			return nil
+		}
+
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value) error {
+	e.writeLength(v.Len(), 0x90, 16, 0, msgpackArray16, msgpackArray32)
+
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeMap encodes the map with entries, sorted by encoded keys (so equal maps are encoded equally).
+func (e *msgpackEncoder) encodeMap(v reflect.Value) error {
+	type entry struct{ key, value []byte }
+
+	var entries = make([]entry, 0, v.Len())
+
+	for iter := v.MapRange(); iter.Next(); {
+		var k, val msgpackEncoder
+
+		if err := k.encode(iter.Key()); err != nil {
+			return err
+		}
+
+		if err := val.encode(iter.Value()); err != nil {
+			return err
+		}
+
+		entries = append(entries, entry{key: k.buf.Bytes(), value: val.buf.Bytes()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+
+	e.writeLength(len(entries), 0x80, 16, 0, msgpackMap16, msgpackMap32)
+
+	for _, en := range entries {
+		_, _ = e.buf.Write(en.key)
+		_, _ = e.buf.Write(en.value)
+	}
+
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(v reflect.Value) error {
+	var fields = make([]msgpackField, 0, v.NumField())
+
+	for _, f := range msgpackFields(v.Type()) {
+		if fv := v.FieldByIndex(f.index); !f.omitEmpty || !isEmptyValue(fv) {
+			fields = append(fields, f)
+		}
+	}
+
+	e.writeLength(len(fields), 0x80, 16, 0, msgpackMap16, msgpackMap32)
+
+	for _, f := range fields {
+		e.encodeString(f.name)
+
+		if err := e.encode(v.FieldByIndex(f.index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// read returns next n bytes of the data.
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.off < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := d.data[d.off : d.off+n]
+	d.off += n
+
+	return b, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// readLength reads the length of the value with passed marker (the size of the length field is 1, 2 or 4 bytes).
+// Lengths, exceeding the rest of the data, are rejected (each element is encoded using one byte at least).
+func (d *msgpackDecoder) readLength(size int) (int, error) {
+	n, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > uint64(len(d.data)-d.off) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return int(n), nil
+}
+
+// peek returns the next value marker.
+func (d *msgpackDecoder) peek() (byte, error) {
+	if d.off >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return d.data[d.off], nil
+}
+
+// decodeAny decodes the next value into the interface value: integers are decoded as int64 (or uint64, if it does
+// not fit), floats as float64, maps with string keys as map[string]interface{} (map[interface{}]interface{} for
+// another keys), and arrays as []interface{}.
+func (d *msgpackDecoder) decodeAny() (interface{}, error) { //nolint:gocyclo
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == msgpackNil:
+		d.off++
+
+		return nil, nil
+	case c == msgpackFalse, c == msgpackTrue:
+		d.off++
+
+		return c == msgpackTrue, nil
+	case c <= 0x7f, c >= 0xe0, c >= msgpackUint8 && c <= msgpackInt64:
+		if c >= msgpackUint8 && c <= msgpackUint64 {
+			u, uErr := d.decodeUint()
+			if uErr == nil && u > math.MaxInt64 {
+				return u, nil
+			}
+
+			return int64(u), uErr
+		}
+
+		return d.decodeInt()
+	case c == msgpackFloat32, c == msgpackFloat64:
+		return d.decodeFloat()
+	case c >= 0xa0 && c <= 0xbf, c >= msgpackStr8 && c <= msgpackStr32:
+		b, sErr := d.decodeRaw()
+
+		return string(b), sErr
+	case c >= msgpackBin8 && c <= msgpackBin32:
+		b, bErr := d.decodeRaw()
+
+		return append([]byte(nil), b...), bErr
+	case c >= 0x90 && c <= 0x9f, c == msgpackArray16, c == msgpackArray32:
+		n, aErr := d.decodeArrayLength()
+		if aErr != nil {
+			return nil, aErr
+		}
+
+		var values = make([]interface{}, n)
+
+		for i := range values {
+			if values[i], err = d.decodeAny(); err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	case c >= 0x80 && c <= 0x8f, c == msgpackMap16, c == msgpackMap32:
+		return d.decodeAnyMap()
+	}
+
+	t, err := d.decodeTime()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (d *msgpackDecoder) decodeAnyMap() (interface{}, error) {
+	n, err := d.decodeMapLength()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		strMap = make(map[string]interface{}, n)
+		anyMap map[interface{}]interface{}
+	)
+
+	for i := 0; i < n; i++ {
+		k, kErr := d.decodeAny()
+		if kErr != nil {
+			return nil, kErr
+		}
+
+		v, vErr := d.decodeAny()
+		if vErr != nil {
+			return nil, vErr
+		}
+
+		if s, ok := k.(string); ok && anyMap == nil {
+			strMap[s] = v
+
+			continue
+		}
+
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("msgpack: unsupported map key type %T", k)
+		}
+
+		if anyMap == nil {
+			anyMap = make(map[interface{}]interface{}, n)
+
+			for s, sv := range strMap {
+				anyMap[s] = sv
+			}
+		}
+
+		anyMap[k] = v
+	}
+
+	if anyMap != nil {
+		return anyMap, nil
+	}
+
+	return strMap, nil
+}
+
+func (d *msgpackDecoder) decodeInt() (int64, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		d.off++
+
+		return int64(c), nil
+	case c >= 0xe0:
+		d.off++
+
+		return int64(int8(c)), nil
+	case c >= msgpackInt8 && c <= msgpackInt64:
+		d.off++
+
+		u, uErr := d.readUint(1 << (c - msgpackInt8))
+
+		switch c {
+		case msgpackInt8:
+			return int64(int8(u)), uErr
+		case msgpackInt16:
+			return int64(int16(u)), uErr
+		case msgpackInt32:
+			return int64(int32(u)), uErr
+		}
+
+		return int64(u), uErr
+	case c >= msgpackUint8 && c <= msgpackUint64:
+		u, uErr := d.decodeUint()
+		if uErr == nil && u > math.MaxInt64 {
+			return 0, fmt.Errorf("msgpack: integer %d overflows int64", u)
+		}
+
+		return int64(u), uErr
+	}
+
+	return 0, fmt.Errorf("msgpack: unexpected marker 0x%02x for integer", c)
+}
+
+func (d *msgpackDecoder) decodeUint() (uint64, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	if c >= msgpackUint8 && c <= msgpackUint64 {
+		d.off++
+
+		return d.readUint(1 << (c - msgpackUint8))
+	}
+
+	i, err := d.decodeInt()
+	if err == nil && i < 0 {
+		return 0, fmt.Errorf("msgpack: negative integer %d for unsigned value", i)
+	}
+
+	return uint64(i), err
+}
+
+func (d *msgpackDecoder) decodeFloat() (float64, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	switch c {
+	case msgpackFloat32:
+		d.off++
+
+		u, uErr := d.readUint(4)
+
+		return float64(math.Float32frombits(uint32(u))), uErr
+	case msgpackFloat64:
+		d.off++
+
+		u, uErr := d.readUint(8)
+
+		return math.Float64frombits(u), uErr
+	}
+
+	if c >= msgpackUint8 && c <= msgpackUint64 {
+		u, uErr := d.decodeUint()
+
+		return float64(u), uErr
+	}
+
+	i, err := d.decodeInt()
+
+	return float64(i), err
+}
+
+// decodeRaw decodes the string or binary data (returned slice refers to the decoder data).
+func (d *msgpackDecoder) decodeRaw() ([]byte, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	d.off++
+
+	var n int
+
+	switch {
+	case c >= 0xa0 && c <= 0xbf:
+		n = int(c & 0x1f)
+	case c == msgpackStr8, c == msgpackBin8:
+		n, err = d.readLength(1)
+	case c == msgpackStr16, c == msgpackBin16:
+		n, err = d.readLength(2)
+	case c == msgpackStr32, c == msgpackBin32:
+		n, err = d.readLength(4)
+	default:
+		return nil, fmt.Errorf("msgpack: unexpected marker 0x%02x for string or binary data", c)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d.read(n)
+}
+
+func (d *msgpackDecoder) decodeArrayLength() (int, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	d.off++
+
+	switch {
+	case c >= 0x90 && c <= 0x9f:
+		return int(c & 0x0f), nil
+	case c == msgpackArray16:
+		return d.readLength(2)
+	case c == msgpackArray32:
+		return d.readLength(4)
+	}
+
+	return 0, fmt.Errorf("msgpack: unexpected marker 0x%02x for array", c)
+}
+
+func (d *msgpackDecoder) decodeMapLength() (int, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	d.off++
+
+	switch {
+	case c >= 0x80 && c <= 0x8f:
+		return int(c & 0x0f), nil
+	case c == msgpackMap16:
+		return d.readLength(2)
+	case c == msgpackMap32:
+		return d.readLength(4)
+	}
+
+	return 0, fmt.Errorf("msgpack: unexpected marker 0x%02x for map", c)
+}
+
+// decodeTime decodes the timestamp extension type value (another extension types are not supported).
+func (d *msgpackDecoder) decodeTime() (time.Time, error) {
+	c, err := d.peek()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	d.off++
+
+	var n int
+
+	switch c {
+	case msgpackFixExt4:
+		n = 4
+	case msgpackFixExt8:
+		n = 8
+	case msgpackExt8:
+		if n, err = d.readLength(1); err != nil {
+			return time.Time{}, err
+		}
+	case msgpackFixExt1, msgpackFixExt2, msgpackFixExt16, msgpackExt16, msgpackExt32:
+		return time.Time{}, errors.New("msgpack: unsupported extension type")
+	default:
+		return time.Time{}, fmt.Errorf("msgpack: unexpected marker 0x%02x", c)
+	}
+
+	b, err := d.read(n + 1)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if b[0] != msgpackTimestampExt {
+		return time.Time{}, fmt.Errorf("msgpack: unsupported extension type %d", int8(b[0]))
+	}
+
+	switch b = b[1:]; n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0), nil
+	case 8:
+		data := binary.BigEndian.Uint64(b)
+
+		return time.Unix(int64(data&(1<<34-1)), int64(data>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b))), nil
+	}
+
+	return time.Time{}, fmt.Errorf("msgpack: wrong timestamp length %d", n)
+}
+
+// decode decodes the next value into v (it must be settable).
+func (d *msgpackDecoder) decode(v reflect.Value) error { //nolint:gocyclo,funlen
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if c == msgpackNil {
+		d.off++
+
+		v.Set(reflect.Zero(v.Type()))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("msgpack: cannot decode into non-empty interface %s", v.Type())
+		}
+
+		value, anyErr := d.decodeAny()
+		if anyErr != nil {
+			return anyErr
+		}
+
+		if value != nil {
+			v.Set(reflect.ValueOf(value))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+		return nil
+	case reflect.Bool:
+		if c != msgpackFalse && c != msgpackTrue {
+			return fmt.Errorf("msgpack: unexpected marker 0x%02x for %s", c, v.Type())
+		}
+
+		d.off++
+
+		v.SetBool(c == msgpackTrue)
+
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, intErr := d.decodeInt()
+		if intErr != nil {
+			return intErr
+		}
+
+		if v.OverflowInt(i) {
+			return fmt.Errorf("msgpack: integer %d overflows %s", i, v.Type())
+		}
+
+		v.SetInt(i)
+
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, uintErr := d.decodeUint()
+		if uintErr != nil {
+			return uintErr
+		}
+
+		if v.OverflowUint(u) {
+			return fmt.Errorf("msgpack: integer %d overflows %s", u, v.Type())
+		}
+
+		v.SetUint(u)
+
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, floatErr := d.decodeFloat()
+		if floatErr != nil {
+			return floatErr
+		}
+
+		v.SetFloat(f)
+
+		return nil
+	case reflect.String:
+		b, rawErr := d.decodeRaw()
+		if rawErr != nil {
+			return rawErr
+		}
+
+		v.SetString(string(b))
+
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (c < 0x90 || c > 0x9f) && c != msgpackArray16 &&
+			c != msgpackArray32 {
+			b, rawErr := d.decodeRaw()
+			if rawErr != nil {
+				return rawErr
+			}
+
+			v.SetBytes(append([]byte{}, b...))
+
+			return nil
+		}
+
+		n, lenErr := d.decodeArrayLength()
+		if lenErr != nil {
+			return lenErr
+		}
+
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+
+		return d.decodeElements(v, n)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (c < 0x90 || c > 0x9f) && c != msgpackArray16 &&
+			c != msgpackArray32 {
+			b, rawErr := d.decodeRaw()
+			if rawErr != nil {
+				return rawErr
+			}
+
+			if len(b) > v.Len() {
+				return fmt.Errorf("msgpack: %d bytes do not fit %s", len(b), v.Type())
+			}
+
+			v.Set(reflect.Zero(v.Type()))
+			reflect.Copy(v, reflect.ValueOf(b))
+
+			return nil
+		}
+
+		n, lenErr := d.decodeArrayLength()
+		if lenErr != nil {
+			return lenErr
+		}
+
+		if n > v.Len() {
+			return fmt.Errorf("msgpack: array of %d elements does not fit %s", n, v.Type())
+		}
+
+		v.Set(reflect.Zero(v.Type()))
+
+		return d.decodeElements(v, n)
+	case reflect.Map:
+		return d.decodeMap(v)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			t, timeErr := d.decodeTime()
+			if timeErr != nil {
+				return timeErr
+			}
+
+			v.Set(reflect.ValueOf(t))
+
+			return nil
+		}
+
+		return d.decodeStruct(v)
+	}
+
+	return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+}
+
+func (d *msgpackDecoder) decodeElements(v reflect.Value, n int) error {
+	for i := 0; i < n; i++ {
+		if err := d.decode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *msgpackDecoder) decodeMap(v reflect.Value) error {
+	n, err := d.decodeMapLength()
+	if err != nil {
+		return err
+	}
+
+	t := v.Type()
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, n))
+	}
+
+	for i := 0; i < n; i++ {
+		k, elem := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+
+		if err = d.decode(k); err != nil {
+			return err
+		}
+
+		if err = d.decode(elem); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(k, elem)
+	}
+
+	return nil
+}
+
+// decodeStruct decodes the map into the struct fields (field names are matched case-insensitively, if there is no
+// exact match), unknown fields are skipped.
+func (d *msgpackDecoder) decodeStruct(v reflect.Value) error {
+	n, err := d.decodeMapLength()
+	if err != nil {
+		return err
+	}
+
+	fields := msgpackFields(v.Type())
+
+	for i := 0; i < n; i++ {
+		name, rawErr := d.decodeRaw()
+		if rawErr != nil {
+			return rawErr
+		}
+
+		var field *msgpackField
+
+		for j := range fields {
+			if fields[j].name == string(name) {
+				field = &fields[j]
+
+				break
+			}
+
+			if field == nil && strings.EqualFold(fields[j].name, string(name)) {
+				field = &fields[j]
+			}
+		}
+
+		if field == nil {
+			if _, err = d.decodeAny(); err != nil { // unknown field is skipped
+				return err
+			}
+
+			continue
+		}
+
+		if err = d.decode(v.FieldByIndex(field.index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package filecache
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackCodecEncoding(t *testing.T) {
+	for name, tt := range map[string]struct {
+		value interface{}
+		want  string // hex encoded
+	}{
+		"nil":              {value: nil, want: "c0"},
+		"true":             {value: true, want: "c3"},
+		"positive fixint":  {value: 127, want: "7f"},
+		"negative fixint":  {value: -32, want: "e0"},
+		"uint8":            {value: 200, want: "ccc8"},
+		"int16":            {value: -1000, want: "d1fc18"},
+		"uint64":           {value: uint64(math.MaxUint64), want: "cfffffffffffffffff"},
+		"float64":          {value: 1.5, want: "cb3ff8000000000000"},
+		"fixstr":           {value: "abc", want: "a3616263"},
+		"str8":             {value: strings.Repeat("a", 32), want: "d920" + strings.Repeat("61", 32)},
+		"bin8":             {value: []byte{1, 2}, want: "c4020102"},
+		"fixarray":         {value: []int{1, 2}, want: "920102"},
+		"sorted map":       {value: map[string]int{"b": 2, "a": 1}, want: "82a16101a16202"},
+		"timestamp 32":     {value: time.Unix(1, 0), want: "d6ff00000001"},
+		"timestamp 64":     {value: time.Unix(1, 1), want: "d7ff0000000400000001"},
+		"timestamp 96":     {value: time.Unix(-1, 0), want: "c70cff00000000ffffffffffffffff"},
+		"struct with tags": {value: struct{ A, B int }{A: 1}, want: "82a14101a14200"},
+		"omitempty": {value: struct {
+			A int    `msgpack:"a,omitempty"`
+			B string `msgpack:"-"`
+		}{B: "skipped"}, want: "80"},
+	} {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := (MsgpackCodec{}).Marshal(&buf, tt.value); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := hex.EncodeToString(buf.Bytes()); got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMsgpackCodecRoundTrip(t *testing.T) {
+	type (
+		Embedded struct{ ID uint16 }
+
+		value struct {
+			Embedded
+			Name     string            `msgpack:"name"`
+			Count    int64             `msgpack:"count"`
+			Ratio    float32           `msgpack:"ratio"`
+			Tags     []string          `msgpack:"tags"`
+			Attrs    map[string]string `msgpack:"attrs"`
+			Data     []byte            `msgpack:"data"`
+			Sum      [4]byte           `msgpack:"sum"`
+			Next     *value            `msgpack:"next,omitempty"`
+			Created  time.Time         `msgpack:"created"`
+			Any      interface{}       `msgpack:"any"`
+			Disabled bool              `msgpack:"disabled"`
+		}
+	)
+
+	var (
+		want = value{
+			Embedded: Embedded{ID: 7},
+			Name:     strings.Repeat("name", 100),
+			Count:    math.MinInt64,
+			Ratio:    0.25,
+			Tags:     []string{"a", "b"},
+			Attrs:    map[string]string{"foo": "bar"},
+			Data:     bytes.Repeat([]byte{1}, 70000),
+			Sum:      [4]byte{1, 2, 3, 4},
+			Next:     &value{Name: "next", Created: time.Unix(1600000000, 123456789)},
+			Created:  time.Unix(1<<35, 1),
+			Any:      map[string]interface{}{"int": int64(-1), "list": []interface{}{"x", 1.5, nil}},
+			Disabled: true,
+		}
+		buf bytes.Buffer
+		got value
+	)
+
+	if err := (MsgpackCodec{}).Marshal(&buf, want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := (MsgpackCodec{}).Unmarshal(&buf, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !got.Created.Equal(want.Created) || !got.Next.Created.Equal(want.Next.Created) {
+		t.Errorf("want times %v and %v, got %v and %v", want.Created, want.Next.Created, got.Created, got.Next.Created)
+	}
+
+	got.Created, got.Next.Created = want.Created, want.Next.Created
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestMsgpackCodecUnmarshalErrors(t *testing.T) {
+	var (
+		i   int8
+		s   string
+		arr [1]int
+	)
+
+	for name, tt := range map[string]struct {
+		data string // hex encoded
+		dst  interface{}
+	}{
+		"not a pointer":     {data: "01", dst: i},
+		"empty data":        {data: "", dst: &i},
+		"integer overflow":  {data: "cd0100", dst: &i},
+		"wrong type":        {data: "a161", dst: &i},
+		"truncated string":  {data: "a361", dst: &s},
+		"too long string":   {data: "dbffffffff61", dst: &s},
+		"array overflow":    {data: "920102", dst: &arr},
+		"unsupported ext":   {data: "d40101", dst: new(interface{})},
+		"unknown marker":    {data: "c1", dst: new(interface{})},
+		"truncated map key": {data: "81a3", dst: new(map[string]int)},
+	} {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.data)
+
+			if err := (MsgpackCodec{}).Unmarshal(bytes.NewReader(data), tt.dst); err == nil {
+				t.Error("error expected")
+			}
+		})
+	}
+
+	if err := (MsgpackCodec{}).Unmarshal(bytes.NewReader([]byte{0xa1}), &s); err != io.ErrUnexpectedEOF {
+		t.Errorf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+}
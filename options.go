@@ -0,0 +1,357 @@
+package filecache
+
+import (
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Option allows to change the pool configuration on creation.
+type Option func(*Pool)
+
+// WithCreateDir sets the permissions of the pool directory, created on the pool creation (default is DefaultDirPerms),
+// if it does not exist (with all parent directories). Zero permissions disable the directory creation (directory must
+// exist).
+func WithCreateDir(perm os.FileMode) Option {
+	return func(pool *Pool) { pool.dirPerm = perm }
+}
+
+// WithFilePerms sets the permissions of the item files (and service files - index, locks, etc.), created by the pool
+// (default is DefaultItemFilePerms at the moment of the pool creation). Process umask is applied on files creation
+// (see WithChmodOnRewrite), and permissions can be set for the single item (see Pool.PutWithPerms).
+func WithFilePerms(perm os.FileMode) Option {
+	return func(pool *Pool) { pool.filePerm = perm }
+}
+
+// WithChmodOnRewrite enables permissions setting on every item file writing, so existing files (e.g. written with
+// another permissions) get the pool (or item) file permissions, and the process umask is not applied. It works for the
+// backends with "Chmod(name string, mode os.FileMode) error" method (see WithBackend).
+func WithChmodOnRewrite(enabled bool) Option {
+	return func(pool *Pool) { pool.chmodOnRewrite = enabled }
+}
+
+// WithOwner sets the owner user and group IDs of the item files, service files (index, epoch) and the pool directory,
+// created by the pool (-1 means "do not change"), e.g. for setups, where a separate serving process reads the cache.
+// Changing requires the root privileges (or CAP_CHOWN capability), and it is not supported on Windows (writing fails).
+// It works for the backends with "Chown(name string, uid, gid int) error" method (see WithBackend).
+func WithOwner(uid, gid int) Option {
+	return func(pool *Pool) { pool.uid, pool.gid = uid, gid }
+}
+
+// WithBackend sets the storage of the pool files (default is the operating system filesystem), e.g. in-memory one for
+// the tests. Files are cloned (see Pool.CopyTo) and read using memory mapping (see WithMmapReads) on the operating
+// system filesystem only, cached read handles (see WithHandleCache) are reused for the backends with os.SameFile
+// support only, and free space is checked (see WithMinFreeSpace) for the backends with "DiskSpace(path string) (total,
+// available uint64, err error)" method only. Files modification times are restored after the access statistics
+// updating by the backends with "Chtimes(name string, atime, mtime time.Time) error" method. Nil backend is ignored.
+func WithBackend(b Backend) Option {
+	return func(pool *Pool) {
+		if b != nil {
+			pool.backend = b
+		}
+	}
+}
+
+// WithWriteLocks enables the cache files write locking between the processes, sharing the cache directory: lock file
+// ("<item file>.lock") is created exclusively before the item file writing and removed after it, so the concurrent
+// writing of the same item by another process fails with ErrConcurrentWrite error (instead of the data interleaving).
+// Lock files, older than the stale timeout, are considered left by the crashed processes and are removed, so timeout
+// must be longer than the longest writing. Access statistics are not updated, while the item is locked. Zero (or
+// negative) timeout disables locking.
+func WithWriteLocks(stale time.Duration) Option {
+	return func(pool *Pool) { pool.writeLockStale = stale }
+}
+
+// WithWindowsCompat sets the number of attempts and delay between them for file opening and removing operations that
+// failed with "sharing violation" (or "access is denied") error. Such errors happen on Windows when file is opened by
+// another process or goroutine, and they are usually transient. On another operating systems retries never happen.
+// Zero attempts disables retrying.
+func WithWindowsCompat(attempts int, delay time.Duration) Option {
+	return func(pool *Pool) {
+		pool.retryAttempts, pool.retryDelay = attempts, delay
+	}
+}
+
+// WithRetry sets the number of attempts and the initial delay (it is doubled for every next attempt, up to 5 seconds)
+// for file opening, renaming and removing operations, failed with transient errors: interrupted system calls (EINTR),
+// busy resources (EBUSY), stale NFS file handles (ESTALE) and Windows "sharing violation" errors (see
+// WithWindowsCompat). Caches on network filesystems should enable retrying. Errors of the opened files reading and
+// writing are not retried, because data can be partially copied already. Zero attempts disables retrying.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(pool *Pool) {
+		pool.retryAttempts, pool.retryDelay, pool.retryTransient = attempts, backoff, true
+	}
+}
+
+// WithCorruptionPolicy sets the policy for cache items with corrupted data (detected on reading).
+func WithCorruptionPolicy(policy CorruptionPolicy) Option {
+	return func(pool *Pool) { pool.corruptionPolicy = policy }
+}
+
+// WithCorruptionCallback sets the CorruptionCallback policy with passed callback. Callback receives item key and
+// corruption error, and returned error will be returned to the caller (nil means "ignore corruption"). Callback is
+// called under the item lock, so pool and cache item methods must not be called inside it.
+func WithCorruptionCallback(fn func(key string, err error) error) Option {
+	return func(pool *Pool) { pool.corruptionPolicy, pool.corruptionCallback = CorruptionCallback, fn }
+}
+
+// WithErrorHandler sets the handler for errors, that cannot be returned to the caller: expired items removing on
+// access (see GetItem and HasItem), background pruning (see WithCleanupInterval), broadcasted events applying (see
+// WithBroadcaster), etc. Handler must be safe for concurrent calling.
+func WithErrorHandler(fn func(error)) Option {
+	return func(pool *Pool) { pool.errorHandler = fn }
+}
+
+// WithExpiredReadPolicy sets the policy for expired cache items reading (default is ExpiredReadError).
+func WithExpiredReadPolicy(policy ExpiredReadPolicy) Option {
+	return func(pool *Pool) { pool.expiredReadPolicy = policy }
+}
+
+// WithExpiredCleanup sets the policy of expired (and invalidated) cache items removing (default is
+// ExpiredCleanupOnAccess).
+func WithExpiredCleanup(policy ExpiredCleanup) Option {
+	return func(pool *Pool) { pool.expiredCleanup = policy }
+}
+
+// WithMaxEntryAge sets the max age of the cache items: items, written earlier than d ago, are removed on pruning (see
+// Pool.Prune and ExpiredCleanupBackground) regardless of their expiration time, including the items without expiration
+// time. Item age is counted from the entry creation time, stored in the item file on the value writing (see
+// file.Header.CreatedAt) and measured with the pool clock (see WithClock), so header and metadata changes (SetMeta,
+// SetExpiresAt, access tracking, etc.) do not reset it. File modification time is used for the files without it (e.g.
+// written by older versions). Aged items remain readable until pruning. Zero (or negative) d means "disabled"
+// (default).
+func WithMaxEntryAge(d time.Duration) Option {
+	return func(pool *Pool) { pool.maxEntryAge = d }
+}
+
+// WithCleanupInterval sets the interval of the background expired items cleanup (default is 1 minute). It is used
+// with ExpiredCleanupBackground policy only.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(pool *Pool) { pool.cleanupInterval = interval }
+}
+
+// WithMmapReads enables memory-mapped reading for values of passed size (in bytes) and larger, so large values are not
+// copied through the read buffer (it reduces CPU usage for multi-megabyte values). Regular reading is used on
+// platforms without memory mapping support. Important notice: cache files must not be truncated by another processes
+// while they are read (e.g. by the tools, that do not use this package).
+func WithMmapReads(threshold int64) Option {
+	return func(pool *Pool) { pool.mmapThreshold = threshold }
+}
+
+// WithChunkedWrites enables chunked (v2) data format for the written values: data is stored in chunks of passed size
+// (in bytes), each with its own checksum. It allows interrupted writing resuming (see Item.Resume) and appending
+// without existing data reading. Zero value disables chunked writes.
+func WithChunkedWrites(chunkSize int) Option {
+	return func(pool *Pool) { pool.chunkSize = chunkSize }
+}
+
+// WithTransformers sets the values transformers (e.g. compressing and encrypting ones), that are applied in passed
+// order on writing (Put, Set and other methods, that write the whole value) and in reverse order on reading (Get and
+// GetReader). Every transformer must use its own header flag, so the item files are self-describing: values, written
+// without transformers, are still readable, and values, transformed by unknown transformer, cannot be read. Value size
+// (Size) is the original value size, range reads (GetRange, DataSectionReader) return ErrNotSupported error for the
+// transformed values, and they cannot be appended.
+func WithTransformers(transformers ...Transformer) Option {
+	return func(pool *Pool) { pool.transformers = transformers }
+}
+
+// WithSplitLayout enables the split layout for the written values: item files contain the header values only, and
+// values are stored as is in the separate raw data files ("<hash>.data" next to the "<hash>.meta" item files), so
+// they can be published using hard links (see Item.LinkTo), memory mapped or served by the static file servers. Raw
+// data files are replaced (not rewritten in place) on every value writing together with the item files, and verified
+// on reading. Transformers (see WithTransformers) and chunked writes (see WithChunkedWrites) are not applied to the
+// detached values, and they cannot be appended. Values, written in the regular layout ("<hash>.cache" item files), are
+// still readable (and vice versa), and their item files are renamed on the value writing.
+func WithSplitLayout(enabled bool) Option {
+	return func(pool *Pool) { pool.splitLayout = enabled }
+}
+
+// WithDeduplication enables the deduplication of the identical values for the split layout (see WithSplitLayout): raw
+// data files with the same data are hard links to the single content file in the pool directory, so repeated values
+// are stored once (values are written as usual, and the duplicate copy is dropped, if the content file with the same
+// data hash sum and size exists - see WithDeduplicationVerify). Content file link count is used as the reference
+// counter, and content files without references are removed on pruning and clearing (see Pool.Prune and Pool.Clear).
+// Deduplicated values must not be modified in place (see Item.LinkTo). Option is ignored for the custom backends (see
+// WithBackend) and on the platforms without link counts support (e.g. Windows).
+func WithDeduplication(enabled bool) Option {
+	return func(pool *Pool) { pool.dedup = enabled }
+}
+
+// WithDeduplicationVerify enables byte-by-byte comparing of the written data with the content file before linking
+// (see WithDeduplication), so the hash sums collisions (and content files, modified in place) do not cause the data
+// substitution. Mismatched content file is replaced with the written data.
+func WithDeduplicationVerify(enabled bool) Option {
+	return func(pool *Pool) { pool.dedupVerify = enabled }
+}
+
+// WithCommitConcurrency sets the number of goroutines, used for writing deferred items on Commit (default is 1).
+func WithCommitConcurrency(n int) Option {
+	return func(pool *Pool) { pool.commitConcurrency = n }
+}
+
+// WithMaxValueSize limits the size of stored values (in bytes). Values, that are larger than the limit, are rejected
+// with ErrTooLarge error or replaced with "too large" marker (depending on the policy).
+func WithMaxValueSize(bytes int64, policy OversizePolicy) Option {
+	return func(pool *Pool) { pool.maxValueSize, pool.oversizePolicy = bytes, policy }
+}
+
+// WithMinFreeSpace sets the threshold (in percents, e.g. 5 for 5%) of the filesystem free space, that is checked before
+// writing. When the free space drops below the threshold - writing is refused or cache items are evicted (depending on
+// the policy).
+func WithMinFreeSpace(percent float64, policy FreeSpacePolicy) Option {
+	return func(pool *Pool) { pool.minFreeSpace, pool.freeSpacePolicy = percent, policy }
+}
+
+// WithNamespaceQuota sets the quota for the keys namespace - cache items with keys, started with the prefix (keys
+// belong to the namespace with the longest matching prefix, and option can be used for several namespaces). When the
+// namespace exceeds maxBytes of the values data or maxItems items (zero means "unlimited"), its items are evicted after
+// the writing (expired and invalidated first, then in the eviction policy order - see WithEvictionPolicy), so the
+// namespace growth never evicts items of another namespaces. Pinned and currently used items are not evicted, so the
+// namespace can exceed the quota. Usage is counted on the first write into any namespace, and then it is tracked for
+// the pool changes (changes, made by another processes, are not tracked). Items without stored keys are not counted.
+func WithNamespaceQuota(prefix string, maxBytes int64, maxItems int) Option {
+	return func(pool *Pool) {
+		pool.namespaceQuotas = append(pool.namespaceQuotas, namespaceQuota{
+			prefix:   prefix,
+			maxBytes: maxBytes,
+			maxItems: maxItems,
+		})
+	}
+}
+
+// WithWalkConcurrency sets the number of goroutines, used for the cache files walking (clearing, pruning, etc.).
+func WithWalkConcurrency(n int) Option {
+	return func(pool *Pool) { pool.walkConcurrency = n }
+}
+
+// WithWalkSignatureCheck enables the strict mode of the cache files walking (clearing, pruning, etc.): every file,
+// matched by the naming convention, is opened for the signature verification before processing. By default, files
+// are filtered by the naming convention only, and signature is verified lazily (by operations, that open files).
+func WithWalkSignatureCheck(enabled bool) Option {
+	return func(pool *Pool) { pool.walkSignatureCheck = enabled }
+}
+
+// WithIndex enables the persistent pool index - append-only (and periodically compacted) file with cache item files
+// metadata (size, expiration time, flags), so lookups, pruning and eviction do not require files opening. Index is
+// rebuilt from the directory scan, if index file is missing. All the processes, sharing the cache directory, must use
+// the index.
+func WithIndex(enabled bool) Option {
+	return func(pool *Pool) { pool.indexEnabled = enabled }
+}
+
+// WithMetadataStore enables cache items metadata (size, expiration time, access statistics and tags) storing in the
+// passed store, while payloads remain in the item files (use NewFileMetadataStore for the metadata, that must survive
+// the process restarts, and NewMemoryMetadataStore - for the process lifetime only). Store is not closed by the pool.
+func WithMetadataStore(store MetadataStore) Option {
+	return func(pool *Pool) {
+		if store != nil {
+			pool.metadata = &metadata{store: store, pool: pool}
+		}
+	}
+}
+
+// WithCodec sets the codec for PutValue and GetValue methods (default is JSONCodec).
+func WithCodec(codec Codec) Option {
+	return func(pool *Pool) {
+		if codec != nil {
+			pool.codec = codec
+		}
+	}
+}
+
+// WithTTLJitter enables expiration times randomization: stored TTL is shortened by a random value up to the passed
+// fraction of TTL (e.g. 0.1 means "up to 10% earlier"). It prevents synchronized mass expiry of cache items, created at
+// the same time. Fraction must be in (0, 1] range (another values disable jittering).
+func WithTTLJitter(fraction float64) Option {
+	return func(pool *Pool) {
+		if fraction > 0 && fraction <= 1 {
+			pool.jitter = newJitter(fraction)
+		} else {
+			pool.jitter = nil
+		}
+	}
+}
+
+// WithBroadcaster enables cache pool events broadcasting: DeleteItem and Clear (and item Delete) calls are published
+// using passed broadcaster, and events, published by other nodes, are applied to the pool. It allows to invalidate
+// caches of the nodes that share no filesystem. Broadcaster is not closed by the pool.
+func WithBroadcaster(b Broadcaster) Option {
+	return func(pool *Pool) { pool.broadcaster = b }
+}
+
+// WithFileWatcher enables the cache directory watching: pool index and metadata are updated when another process
+// rewrites or deletes cache files, and optional fn is called for every change (e.g. to invalidate an in-memory layer,
+// Item.GetFilePath can be used for the keys mapping). Callback is called from the watcher goroutine, and it must not
+// block for a long time.
+func WithFileWatcher(w FileWatcher, fn func(e FileEvent)) Option {
+	return func(pool *Pool) { pool.watcher, pool.watchCallback = w, fn }
+}
+
+// WithClock sets the time source for the expiration logic (expiration times checking, TTL calculation, pruning, etc.),
+// entry creation and access times, versions and access statistics, so expiration can be tested without real sleeps.
+// Files modification times are not affected.
+func WithClock(clock Clock) Option {
+	return func(pool *Pool) {
+		if clock != nil {
+			pool.clock = clock
+		}
+	}
+}
+
+// WithHandleCache enables caching of read-only file descriptors for hot items: up to size descriptors are kept open
+// (least recently used ones are closed first), and descriptors, unused during the idle timeout, are closed in the
+// background (zero idle means 30 seconds). Cached descriptors are shared between readers, and files, replaced by
+// another processes, are reopened. Zero (or negative) size disables caching.
+func WithHandleCache(size int, idle time.Duration) Option {
+	return func(pool *Pool) {
+		if size > 0 {
+			pool.handles = newHandles(size, idle)
+		} else {
+			pool.handles = nil
+		}
+	}
+}
+
+// WithSignature sets the signature of the item files (default is DefaultItemFileSignature), so pools with different
+// signatures can share the directory without treating each other files as their own (see WithWalkSignatureCheck).
+// Signature length must be 1..file.MaxSignatureSize bytes (see file.ValidateSignature), NewPool panics otherwise.
+// Signatures, longer than file.SignatureSize bytes, are partially stored in the metadata block, so their verification
+// requires the whole header reading. Nil signature means file.DefaultSignature.
+func WithSignature(signature []byte) Option {
+	return func(pool *Pool) {
+		if signature == nil {
+			pool.signature = nil
+		} else {
+			pool.signature = append(file.FSignature{}, signature...)
+		}
+	}
+}
+
+// WithStrictSignature enables the item files signature verification on every reading (Item.Get and another reading
+// methods): files with another signature (e.g. created by another pool or placed into the cache directory by hand) are
+// not parsed, and ErrWrongSignature error is returned. By default, signature is not verified on reading.
+func WithStrictSignature(enabled bool) Option {
+	return func(pool *Pool) { pool.strictSignature = enabled }
+}
+
+// WithAccessTracking enables recording of the last access (successful reading) time into the item files headers (see
+// Item.AccessedAt and ItemInfo.AccessedAt). To limit write amplification, access time is updated at most once per
+// passed precision (e.g. a minute). Free space eviction removes least recently used items (instead of least recently
+// written) when tracking is enabled. Zero (or negative) precision disables tracking.
+func WithAccessTracking(precision time.Duration) Option {
+	return func(pool *Pool) { pool.accessPrecision = precision }
+}
+
+// WithHitCounting enables counting of successful reads in the item files headers (see Item.Hits, ItemInfo.Hits and
+// Pool.HotKeys), so the least frequently used items can be evicted first (see WithEvictionPolicy). Important notice:
+// every successful reading writes into the item file.
+func WithHitCounting(enabled bool) Option {
+	return func(pool *Pool) { pool.hitCounting = enabled }
+}
+
+// WithEvictionPolicy sets the order of cache items eviction, when the free space is not enough (see WithMinFreeSpace,
+// default policy is EvictLeastRecentlyUsed).
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(pool *Pool) { pool.evictionPolicy = policy }
+}
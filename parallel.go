@@ -0,0 +1,59 @@
+package filecache
+
+import (
+	"context"
+	"sync"
+)
+
+// parallel calls the function for every index in [0, n) using the bounded number of goroutines. The first occurred
+// error will be returned. When the context is canceled - remaining indexes are skipped, and context error will be
+// returned (if there was no another error).
+func parallel(ctx context.Context, concurrency, n int, fn func(i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		indexes  = make(chan int)
+	)
+
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case indexes <- i:
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+
+	return firstErr
+}
@@ -0,0 +1,20 @@
+package filecache
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// PutWithPerms puts a cache item with expiring time (zero means "without expiring time") and the item file
+// permissions (instead of the pool file permissions, see WithFilePerms), e.g. for the values, that must be readable by
+// another users. Permissions of the existing item file are changed, only if it is enabled (see WithChmodOnRewrite), and
+// the following item writes use the pool file permissions.
+func (pool *Pool) PutWithPerms(key string, from io.Reader, expiresAt time.Time, perm os.FileMode) (CacheItem, error) {
+	item := newItem(pool, key)
+	item.perm = perm
+
+	return pool.putItem(item, from, pool.jitter.header(pool.now(), file.Header{ExpiresAt: expiresAt}))
+}
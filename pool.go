@@ -1,10 +1,16 @@
 package filecache
 
 import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"errors"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tarampampam/go-filecache/file"
@@ -12,13 +18,365 @@ import (
 
 type Pool struct {
 	dirPath string
+	dirPerm os.FileMode // pool directory creation permissions (zero means "do not create")
+	dirErr  error       // pool directory preparing error (see prepareDir)
+	backend Backend     // pool files storage (see WithBackend)
+
+	filePerm       os.FileMode // item files creation permissions (see WithFilePerms)
+	chmodOnRewrite bool        // permissions of the rewritten item files are changed (see WithChmodOnRewrite)
+	uid, gid       int         // owner of the created files and directory (-1 means "do not change", see WithOwner)
+
+	writeLockStale time.Duration // write lock files timeout (zero means "write locks are disabled")
+	tempDir        bool          // pool directory is removed on closing (see NewTempPool)
+
+	retryAttempts int           // attempts for operations, failed with "sharing violation" error
+	retryDelay    time.Duration // delay between attempts (initial delay, if transient errors are retried)
+
+	retryTransient bool // retry operations, failed with transient errors, with exponential backoff (see WithRetry)
+
+	corruptionPolicy   CorruptionPolicy
+	corruptionCallback func(key string, err error) error
+
+	errorHandler func(error) // nil, if errors, that cannot be returned, are ignored (see WithErrorHandler)
+
+	epoch    epoch
+	deferred deferred
+
+	commitConcurrency int // number of goroutines for deferred items committing
+	walkConcurrency   int // number of goroutines for the cache files walking (clearing, pruning, etc.)
+
+	walkSignatureCheck bool // verify signature of every file on walking
+
+	indexEnabled bool
+	index        *index    // nil, if index is disabled
+	metadata     *metadata // nil, if metadata store is not configured
+
+	codec Codec // values codec (see PutValue and GetValue)
+	clock Clock // time source for the expiration logic
+
+	expiredReadPolicy ExpiredReadPolicy
+	mmapThreshold     int64 // values of this size (and larger) are read using memory mapping (zero means "disabled")
+	chunkSize         int   // chunked (v2) data format chunk size (zero means "regular data format")
+	splitLayout       bool  // values are stored in the separate raw data files (see WithSplitLayout)
+	dedup             bool  // identical raw data files are hard links to the same content file (see WithDeduplication)
+	dedupVerify       bool  // content files are compared with the written data before linking (WithDeduplicationVerify)
+	expiredCleanup    ExpiredCleanup
+	cleanupInterval   time.Duration
+	maxEntryAge       time.Duration // entries, written earlier, are pruned (zero means "disabled")
+
+	jitter *jitter // nil, if expiration times jittering is disabled
+
+	broadcaster Broadcaster // nil, if events broadcasting is disabled
+	nodeID      string      // pool ID for the broadcasted events
+	unsubscribe func()      // nil, if pool is not subscribed to the events
+
+	watcher       FileWatcher     // nil, if cache directory watching is disabled
+	watchCallback func(FileEvent) // optional
+	unwatch       func()          // nil, if cache directory is not watched
+
+	handles *handles // nil, if read handles caching is disabled
+
+	transformers []Transformer // values transformers in the writing order (see WithTransformers)
+
+	signature       file.FSignature // item files signature (nil means file.DefaultSignature)
+	strictSignature bool            // verify item files signature on every reading
+
+	accessPrecision time.Duration // last access time updating interval (zero means "access tracking is disabled")
+	hitCounting     bool          // count item reads in the item files headers
+	evictionPolicy  EvictionPolicy
+
+	itemLocks [itemLocksCount]stripeLock // striped by item file name, shared by the items with the same key
+
+	maxValueSize   int64 // zero means "unlimited"
+	oversizePolicy OversizePolicy
+
+	minFreeSpace    float64 // in percents, zero means "do not check"
+	freeSpacePolicy FreeSpacePolicy
+	evictMu         sync.Mutex
+
+	namespaceQuotas []namespaceQuota // see WithNamespaceQuota
+	quotas          *quotas          // nil, if namespace quotas are not set
+
+	closed   int32          // non-zero after closing (pool is not usable, see acquire)
+	inFlight int32          // number of the in-flight operations (see acquire)
+	idle     chan struct{}  // signaled, when the last in-flight operation of the closed pool is finished
+	done     chan struct{}  // closed on pool closing (background workers must stop on it)
+	stop     sync.Once      // done channel closing
+	workers  sync.WaitGroup // background workers
 }
 
-// NewPool creates new cache items pool.
-func NewPool(dirPath string) *Pool {
-	return &Pool{
-		dirPath: dirPath,
+// Default settings for retrying of operations, failed with "sharing violation" error.
+const (
+	defaultRetryAttempts = 5
+	defaultRetryDelay    = time.Millisecond * 20
+	maxRetryDelay        = time.Second * 5 // delay is not doubled further (see WithRetry)
+)
+
+// defaultWalkConcurrency is the default number of goroutines for the cache files walking.
+const defaultWalkConcurrency = 8
+
+// NewPool creates new cache items pool. Pool directory is created, if it does not exist (see WithCreateDir). Directory
+// preparing errors are returned by the writing operations (while directory is not available), use NewPoolE for the
+// directory validation on creation.
+func NewPool(dirPath string, opts ...Option) *Pool {
+	pool := newPool(dirPath, opts...)
+
+	if err := file.ValidateSignature(pool.signature); err != nil {
+		panic("filecache: " + err.Error()) // signatures are set by the code, so it is a programming error
 	}
+
+	pool.dirErr = pool.prepareDir()
+	pool.start()
+
+	return pool
+}
+
+// NewPoolE creates new cache items pool, like NewPool, but pool directory is validated on creation: it must exist (or
+// be created - see WithCreateDir), be a directory and be writable. Invalid signature (see WithSignature) is returned
+// as an error too.
+func NewPoolE(dirPath string, opts ...Option) (*Pool, error) {
+	pool := newPool(dirPath, opts...)
+
+	if err := file.ValidateSignature(pool.signature); err != nil {
+		return nil, newError(ErrUnknown, "wrong item files signature", err)
+	}
+
+	if err := pool.prepareDir(); err != nil {
+		return nil, err
+	}
+
+	if err := pool.checkDirWritable(); err != nil {
+		return nil, err
+	}
+
+	pool.start()
+
+	return pool, nil
+}
+
+// newPool creates the pool with applied options (background workers are not started).
+func newPool(dirPath string, opts ...Option) *Pool {
+	pool := &Pool{
+		dirPath:           dirPath,
+		dirPerm:           DefaultDirPerms,
+		filePerm:          DefaultItemFilePerms,
+		uid:               -1,
+		gid:               -1,
+		retryAttempts:     defaultRetryAttempts,
+		retryDelay:        defaultRetryDelay,
+		commitConcurrency: 1,
+		walkConcurrency:   defaultWalkConcurrency,
+		codec:             JSONCodec{},
+		clock:             systemClock{},
+		cleanupInterval:   defaultCleanupInterval,
+		nodeID:            newNodeID(),
+		signature:         DefaultItemFileSignature,
+		backend:           osBackend{},
+		idle:              make(chan struct{}, 1),
+		done:              make(chan struct{}),
+	}
+
+	for i := range pool.itemLocks {
+		pool.itemLocks[i] = make(stripeLock, 1)
+	}
+
+	for _, opt := range opts {
+		opt(pool)
+	}
+
+	return pool
+}
+
+// start creates the pool index and starts the background workers.
+func (pool *Pool) start() {
+	if pool.indexEnabled {
+		pool.index = newIndex(pool)
+	}
+
+	pool.quotas = newQuotas(pool, pool.namespaceQuotas)
+
+	pool.startCleanup()
+	pool.startHandlesSweep()
+	pool.subscribe()
+	pool.watch()
+}
+
+// itemLocksCount is the number of item lock stripes.
+const itemLocksCount = 256
+
+// stripeLock is the item lock stripe: mutual exclusion lock, that can be acquired without blocking (see TryLock).
+type stripeLock chan struct{}
+
+// Lock locks the stripe (it blocks until the stripe is available).
+func (l stripeLock) Lock() { l <- struct{}{} }
+
+// TryLock tries to lock the stripe without blocking and reports whether it succeeded.
+func (l stripeLock) TryLock() bool {
+	select {
+	case l <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Unlock unlocks the stripe.
+func (l stripeLock) Unlock() { <-l }
+
+// itemLock returns the lock for the item file name. Items with the same key share the lock (and different keys may
+// share it too, so only one item lock must be held at a time - see lockItems for two items locking).
+func (pool *Pool) itemLock(fileName string) stripeLock {
+	return pool.itemLocks[itemLockIndex(fileName)]
+}
+
+// itemLockIndex returns the item lock stripe index for the item file name (item files of both layouts share the lock,
+// see WithSplitLayout).
+func itemLockIndex(fileName string) uint32 {
+	var h uint32 = 2166136261 // FNV-1a
+
+	fileName, _ = trimItemFileExt(fileName)
+
+	for i := 0; i < len(fileName); i++ {
+		h = (h ^ uint32(fileName[i])) * 16777619
+	}
+
+	return h % itemLocksCount
+}
+
+// fileLock returns the item lock for the cache file (staged, backup and temporary raw data files share the lock with
+// the item file).
+func (pool *Pool) fileLock(path string) stripeLock {
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), stagedFileSuffix), backupFileSuffix)
+
+	return pool.itemLock(strings.TrimSuffix(name, dataTempFileExt))
+}
+
+// acquire marks the beginning of an operation. If pool is closed - false will be returned (and release must not be
+// called in this case). Operations are counted (not locked), so they can be nested (e.g. public methods, called by
+// another ones) - acquiring never blocks, and nested operations, started after the closing, fail with closed pool
+// error instead of the deadlock.
+func (pool *Pool) acquire() bool {
+	atomic.AddInt32(&pool.inFlight, 1)
+
+	if atomic.LoadInt32(&pool.closed) != 0 {
+		pool.release()
+
+		return false
+	}
+
+	return true
+}
+
+// release marks the end of an operation, started with acquire (closing is notified about the last one).
+func (pool *Pool) release() {
+	if atomic.AddInt32(&pool.inFlight, -1) == 0 && atomic.LoadInt32(&pool.closed) != 0 {
+		select {
+		case pool.idle <- struct{}{}:
+		default: // closing is notified already
+		}
+	}
+}
+
+// retry calls the function again (with delay) while it fails with "sharing violation" error (this error can be returned
+// on Windows only, when file is used by another process), or with another transient error, if retrying of transient
+// errors is enabled (see WithRetry - delay is doubled for every next attempt). Function must be safe for repeated
+// calling.
+func (pool *Pool) retry(fn func() error) error {
+	var delay = pool.retryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > pool.retryAttempts || !pool.retryable(err) {
+			return err
+		}
+
+		time.Sleep(delay)
+
+		if pool.retryTransient && delay < maxRetryDelay {
+			delay *= 2
+		}
+	}
+}
+
+// retryable reports whether the failed operation can be retried.
+func (pool *Pool) retryable(err error) bool {
+	return isSharingViolation(err) || (pool.retryTransient && isTransient(err))
+}
+
+// removeFile removes the file, retrying on "sharing violation" errors (index and metadata entries are removed too).
+func (pool *Pool) removeFile(path string) error {
+	pool.handles.forget(path) // opened files cannot be removed on some platforms
+
+	err := pool.retry(func() error { return pool.backend.Remove(path) })
+
+	if err == nil || os.IsNotExist(err) {
+		pool.fileRemoved(path)
+
+		if isItemFileName(filepath.Base(path)) { // raw data file of the detached value (see WithSplitLayout)
+			_ = pool.retry(func() error { return pool.backend.Remove(dataPath(path)) })
+		}
+	}
+
+	return err
+}
+
+// fileChanged updates the pool index and metadata for the written file (empty key means "unknown").
+func (pool *Pool) fileChanged(key, path string) {
+	pool.index.refresh(path)
+	pool.metadata.changed(key, path)
+	pool.quotas.changed(key, path)
+}
+
+// fileRemoved removes the pool index and metadata entries for the removed file.
+func (pool *Pool) fileRemoved(path string) {
+	pool.index.forget(path)
+	pool.metadata.forget(path)
+	pool.quotas.forget(path)
+}
+
+// errPoolClosed creates an error for operations on closed pool.
+func errPoolClosed() *Error { return newError(ErrPoolClosed, "cache pool is closed", nil) }
+
+// Close stops background workers, waits for in-flight operations completion and marks the pool unusable (temporary
+// pool directory is removed - see NewTempPool).
+// Close will return an error if it has already been called.
+func (pool *Pool) Close() error {
+	pool.stop.Do(func() { // workers must be stopped before in-flight operations waiting
+		close(pool.done)
+
+		if pool.unsubscribe != nil {
+			pool.unsubscribe()
+		}
+
+		if pool.unwatch != nil {
+			pool.unwatch()
+		}
+	})
+
+	if !atomic.CompareAndSwapInt32(&pool.closed, 0, 1) {
+		return errPoolClosed()
+	}
+
+	for atomic.LoadInt32(&pool.inFlight) > 0 { // in-flight operations completion waiting
+		<-pool.idle
+	}
+
+	pool.workers.Wait()
+	pool.handles.close()
+
+	var err error
+
+	if pool.index != nil {
+		err = pool.index.close()
+	}
+
+	if pool.tempDir {
+		if rmErr := os.RemoveAll(pool.dirPath); rmErr != nil && err == nil {
+			err = newError(ErrUnknown, fmt.Sprintf("cannot remove temporary directory [%s]", pool.dirPath), rmErr)
+		}
+	}
+
+	return err
 }
 
 // GetDirPath returns cache directory path.
@@ -28,56 +386,219 @@ func (pool *Pool) GetDirPath() string { return pool.dirPath }
 func (pool *Pool) GetItem(key string) CacheItem {
 	item := newItem(pool, key)
 
-	// Make check for exists and "is expired?"
-	if item.IsHit() {
-		if expired, _ := item.IsExpired(); expired {
-			_, _ = pool.DeleteItem(key)
+	if pool.expiredCleanup != ExpiredCleanupOnAccess {
+		return item // expired items are removed in the background (or manually)
+	}
+
+	if pool.index != nil {
+		if e, exists, err := pool.index.get(item.fileName); err == nil {
+			if exists && e.outdated(pool.now(), pool.currentEpoch()) {
+				pool.removeOutdated(key)
+			}
+
+			return item
+		}
+	}
+
+	// Make check for "is invalidated?", exists and "is expired?"
+	if item.IsInvalidated() {
+		pool.removeOutdated(key)
+	} else if item.IsHit() {
+		if expired, err := item.hasExpired(); err != nil {
+			pool.reportError(err)
+		} else if expired {
+			pool.removeOutdated(key)
 		}
 	}
 
 	return item
 }
 
+// removeOutdated removes the expired (or invalidated) cache item on access. Removing error cannot be returned, so it
+// is passed to the error handler (see WithErrorHandler).
+func (pool *Pool) removeOutdated(key string) {
+	if _, err := pool.deleteItem(key); err != nil && !os.IsNotExist(err) { // item can be removed concurrently
+		pool.reportError(writeError(fmt.Sprintf("cannot remove outdated item [%s] file", key), err))
+	}
+}
+
+// reportError passes the error, that cannot be returned to the caller (e.g. on background pruning), to the error
+// handler. Errors of the closing pool (and canceled operations) are not reported.
+func (pool *Pool) reportError(err error) {
+	if err == nil || pool.errorHandler == nil || errors.Is(err, ErrPoolClosed) || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	pool.errorHandler(err)
+}
+
 // HasItem confirms if the cache contains specified cache item.
 func (pool *Pool) HasItem(key string) bool {
+	if pool.index != nil {
+		if e, exists, err := pool.index.get(pool.itemFileName(key)); err == nil && !exists {
+			return false
+		} else if err == nil && !e.outdated(pool.now(), pool.currentEpoch()) {
+			return true
+		}
+	}
+
+	if pool.expiredCleanup != ExpiredCleanupOnAccess {
+		item := newItem(pool, key)
+
+		expired, err := item.hasExpired() // expired items are not removed on access
+		pool.reportError(err)
+
+		return !expired && item.IsHit()
+	}
+
 	return pool.GetItem(key).IsHit()
 }
 
-func (pool *Pool) walkOverCacheFiles(fn func(string, os.FileInfo)) error {
-	files, err := ioutil.ReadDir(pool.dirPath)
+// walkOverCacheFiles calls the function for every cache file in the pool directory using the bounded number of
+// goroutines, so function must be safe for concurrent calling. Files are filtered by the naming convention only (file
+// signature is not verified, because opening of every file is slow), so function must verify the signature, if it
+// opens the file (see WithWalkSignatureCheck option for the strict mode). Walking is stopped when the context is
+// canceled.
+func (pool *Pool) walkOverCacheFiles(ctx context.Context, fn func(path string)) error {
+	names, err := pool.backend.List(pool.dirPath)
 	if err != nil {
 		return err
 	}
 
-	for _, f := range files {
-		path := filepath.Join(pool.dirPath, f.Name())
-		cacheFile, err := file.OpenRead(path, DefaultItemFileSignature)
+	return parallel(ctx, pool.walkConcurrency, len(names), func(i int) error {
+		if !isCacheFileName(names[i]) {
+			return nil
+		}
+
+		path := filepath.Join(pool.dirPath, names[i])
 
-		// skip "wrong" or errored file
-		if err != nil || cacheFile == nil {
-			continue
+		if pool.walkSignatureCheck && !pool.signatureMatched(path) {
+			return nil
 		}
 
-		// verify file signature and close file (closing error will be skipped)
-		matched, _ := cacheFile.SignatureMatched()
+		fn(path)
 
-		if closeErr := cacheFile.Close(); matched && closeErr == nil {
-			// if all is ok - fall the func
-			fn(path, f)
+		return nil
+	})
+}
+
+// isCacheFileName reports whether the file name matches the cache files naming convention ("<md5 hex>.cache" or
+// "<md5 hex>.meta", optionally with staged or backup file suffix, and "<md5 hex>.data.tmp" temporary raw data files).
+func isCacheFileName(name string) bool {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, stagedFileSuffix), backupFileSuffix)
+
+	name, ok := trimItemFileExt(name)
+	if !ok && isDataTempFileName(name) {
+		name, ok = strings.TrimSuffix(name, dataTempFileExt), true
+	}
+
+	if !ok || len(name) != md5.Size*2 {
+		return false
+	}
+
+	for _, c := range name[:md5.Size*2] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
 		}
 	}
 
-	return nil
+	return true
+}
+
+// isDataTempFileName reports whether the file name is the temporary raw data file name (see WithSplitLayout). Such
+// files exist during the value writing only (under the item lock), so they are stale, if the item lock is acquired.
+func isDataTempFileName(name string) bool { return strings.HasSuffix(name, dataTempFileExt) }
+
+// inspectFile opens the cache file for reading its header fields: unlike file.OpenRead, incomplete files (e.g. left by
+// interrupted writing) are opened too, so they can be pruned, cleared and evicted.
+func (pool *Pool) inspectFile(path string) (*file.File, error) {
+	f, err := pool.openFile(path, os.O_RDONLY, 0)
+	if !errors.Is(err, file.ErrIncompleteEntry) {
+		return f, err
+	}
+
+	h, err := pool.backend.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f = file.Shared(h, pool.signature, h.Close)
+
+	if err = f.VerifyHeader(); err != nil {
+		_ = f.Close()
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// signatureMatched opens the file and verifies its signature (any error means "not matched").
+func (pool *Pool) signatureMatched(path string) bool {
+	f, err := pool.inspectFile(path)
+	if err != nil {
+		return false
+	}
+
+	// verify file signature and close file (closing error will be skipped)
+	matched, _ := f.SignatureMatched()
+
+	return f.Close() == nil && matched
 }
 
 // Clear deletes all items in the pool.
-func (pool *Pool) Clear() (bool, error) {
-	var lastErr error
+func (pool *Pool) Clear() (bool, error) { return pool.ClearContext(context.Background()) }
+
+// ClearContext deletes all items in the pool using the bounded number of goroutines (see WithWalkConcurrency option).
+// Clearing is stopped when the context is canceled. EventClear is published on success (see WithBroadcaster option).
+// Files are removed under the item locks, so in-flight writes are completed before the file removing, and writes,
+// started after it, create new files (that are not removed). Item files, staged for Commit, can be removed, and Commit
+// fails with ErrFileWriting error in this case.
+func (pool *Pool) ClearContext(ctx context.Context) (bool, error) {
+	return pool.ClearProgress(ctx, nil)
+}
+
+// ClearProgress is ClearContext with the progress callback: fn (optional) is called after every processed cache file
+// with the number of scanned and removed files. Calls are serialized, but they are made from the walking goroutines,
+// so fn must not block for a long time.
+func (pool *Pool) ClearProgress(ctx context.Context, fn func(Progress)) (bool, error) {
+	if ok, err := pool.clear(ctx, fn); !ok {
+		return false, err
+	}
+
+	if err := pool.publish(EventClear, ""); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// clear deletes all items in the pool without the event publishing (progress callback is optional).
+func (pool *Pool) clear(ctx context.Context, fn func(Progress)) (bool, error) {
+	if !pool.acquire() {
+		return false, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		lastErr error
+		mu      sync.Mutex
+		p       = progress{fn: fn}
+	)
+
+	err := pool.walkOverCacheFiles(ctx, func(path string) {
+		lock := pool.fileLock(path) // in-flight item writes are completed before removing
+		lock.Lock()
+		rmErr := pool.removeFile(path)
+		lock.Unlock()
 
-	err := pool.walkOverCacheFiles(func(path string, _ os.FileInfo) {
-		if rmErr := os.Remove(path); rmErr != nil {
+		if rmErr != nil {
+			mu.Lock()
 			lastErr = rmErr
+			mu.Unlock()
 		}
+
+		p.processed(rmErr == nil)
 	})
 
 	if err != nil {
@@ -88,14 +609,146 @@ func (pool *Pool) Clear() (bool, error) {
 		return false, lastErr
 	}
 
+	pool.collectBlobs()
+
 	return true, nil
 }
 
-// DeleteItem removes the item from the pool.
+// Prune deletes expired, invalidated (and aged, see WithMaxEntryAge) items from the pool and returns the number of
+// deleted items. Stale temporary raw data files (left after the interrupted writing, see WithSplitLayout) are
+// deleted too.
+func (pool *Pool) Prune() (int, error) { return pool.PruneContext(context.Background()) }
+
+// PruneContext deletes expired and invalidated items from the pool using the bounded number of goroutines (see
+// WithWalkConcurrency option) and returns the number of deleted items. Pruning is stopped when the context is
+// canceled.
+func (pool *Pool) PruneContext(ctx context.Context) (int, error) { return pool.PruneProgress(ctx, nil) }
+
+// PruneProgress is PruneContext with the progress callback: fn (optional) is called after every processed cache file
+// with the number of scanned and removed files (see ClearProgress).
+func (pool *Pool) PruneProgress(ctx context.Context, fn func(Progress)) (int, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		lastErr error
+		mu      sync.Mutex
+		p       = progress{fn: fn}
+		epoch   = pool.currentEpoch()
+	)
+
+	var entries map[string]indexEntry // index entries allow to skip files opening
+
+	if pool.index != nil {
+		entries, _ = pool.index.snapshot()
+	}
+
+	err := pool.walkOverCacheFiles(ctx, func(path string) {
+		lock := pool.fileLock(path) // item must not be rewritten between the checking and removing
+		lock.Lock()
+		defer lock.Unlock()
+
+		e, indexed := entries[filepath.Base(path)]
+
+		switch {
+		case isDataTempFileName(filepath.Base(path)): // stale temporary raw data file of the interrupted writing
+		case indexed && !e.outdated(pool.now(), epoch) && !pool.aged(e.CreatedAt, pool.now()),
+			!indexed && !pool.fileOutdated(path, pool.now(), epoch) && !pool.fileAged(path, pool.now()):
+			p.processed(false)
+
+			return
+		}
+
+		rmErr := pool.removeFile(path)
+
+		if rmErr != nil && !os.IsNotExist(rmErr) {
+			mu.Lock()
+			lastErr = rmErr
+			mu.Unlock()
+		}
+
+		p.processed(rmErr == nil)
+	})
+
+	if err != nil {
+		return p.removed(), err
+	}
+
+	pool.collectBlobs()
+
+	return p.removed(), lastErr
+}
+
+// fileOutdated reports whether the cache file is expired or stamped with an epoch, older than passed. Files with wrong
+// signature are never outdated.
+func (pool *Pool) fileOutdated(path string, now time.Time, epoch uint32) bool {
+	f, err := pool.inspectFile(path)
+	if err != nil {
+		return false
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if matched, _ := f.SignatureMatched(); !matched {
+		return false
+	}
+
+	if e, err := f.GetEpoch(); err == nil && e < epoch {
+		return true
+	}
+
+	exp, err := f.GetExpiresAt()
+
+	return err == nil && exp.Before(now)
+}
+
+// aged reports whether the entry, created at passed time, is older than the pool max entry age (see WithMaxEntryAge).
+func (pool *Pool) aged(createdAt, now time.Time) bool {
+	return pool.maxEntryAge > 0 && createdAt.Before(now.Add(-pool.maxEntryAge))
+}
+
+// fileAged reports whether the cache file entry is older than the pool max entry age. Files with wrong signature are
+// never aged.
+func (pool *Pool) fileAged(path string, now time.Time) bool {
+	if pool.maxEntryAge <= 0 {
+		return false
+	}
+
+	e, err := pool.readIndexEntry(path) // signature is verified
+	if err != nil {
+		return false
+	}
+
+	return pool.aged(e.CreatedAt, now)
+}
+
+// DeleteItem removes the item from the pool. EventDelete is published on success (see WithBroadcaster option).
 func (pool *Pool) DeleteItem(key string) (bool, error) {
+	if ok, err := pool.deleteItem(key); !ok {
+		return false, err
+	}
+
+	if err := pool.publish(EventDelete, key); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// deleteItem removes the item from the pool without the event publishing.
+func (pool *Pool) deleteItem(key string) (bool, error) {
+	if !pool.acquire() {
+		return false, errPoolClosed()
+	}
+	defer pool.release()
+
 	item := newItem(pool, key)
 
-	if rmErr := os.Remove(item.GetFilePath()); rmErr != nil {
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	if rmErr := item.delete(); rmErr != nil {
 		return false, rmErr
 	}
 
@@ -104,24 +757,35 @@ func (pool *Pool) DeleteItem(key string) (bool, error) {
 
 // Put a cache item with expiring time.
 func (pool *Pool) Put(key string, from io.Reader, expiresAt time.Time) (CacheItem, error) {
-	item, putError := pool.PutForever(key, from)
+	return pool.put(key, from, file.Header{ExpiresAt: expiresAt})
+}
 
-	if putError != nil {
-		return item, putError
-	}
+// Put a cache item without expiring time.
+func (pool *Pool) PutForever(key string, from io.Reader) (CacheItem, error) {
+	return pool.put(key, from, file.Header{})
+}
 
-	if err := item.SetExpiresAt(expiresAt); err != nil {
-		return item, err
-	}
+// put writes the whole cache item (zero expiration time means "without expiring time").
+func (pool *Pool) put(key string, from io.Reader, h file.Header) (CacheItem, error) {
+	return pool.putHeader(key, from, pool.jitter.header(pool.now(), h))
+}
 
-	return item, nil
+// putHeader writes a cache item with passed header values (values are written as is, without jittering).
+func (pool *Pool) putHeader(key string, from io.Reader, h file.Header) (CacheItem, error) {
+	return pool.putItem(newItem(pool, key), from, h)
 }
 
-// Put a cache item without expiring time.
-func (pool *Pool) PutForever(key string, from io.Reader) (CacheItem, error) {
-	item := newItem(pool, key)
+// putItem writes the cache item with passed header values.
+func (pool *Pool) putItem(item *Item, from io.Reader, h file.Header) (CacheItem, error) {
+	if !pool.acquire() {
+		return nil, errPoolClosed()
+	}
+	defer pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
 
-	if err := item.Set(from); err != nil {
+	if err := item.put(from, h); err != nil {
 		return item, err
 	}
 
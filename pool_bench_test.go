@@ -0,0 +1,50 @@
+package filecache_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tarampampam/go-filecache"
+)
+
+func BenchmarkPool_Put(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		size int
+	}{
+		{name: "small 1KiB", size: 1 << 10},
+		{name: "large 1MiB", size: 1 << 20},
+	} {
+		bm := bm
+
+		b.Run(bm.name, func(b *testing.B) { benchmarkPut(b, bm.size) })
+	}
+}
+
+func benchmarkPut(b *testing.B, size int) {
+	dir, err := ioutil.TempDir("", "filecache-bench-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var (
+		pool      = filecache.NewPool(dir)
+		data      = bytes.Repeat([]byte{'x'}, size)
+		expiresAt = time.Now().Add(time.Hour)
+	)
+	defer func() { _ = pool.Close() }()
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := pool.Put("key", bytes.NewReader(data), expiresAt); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package filecache
+
+import (
+	"io"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// PutWithPriority puts a cache item with expiring time (zero means "without expiring time") and the eviction
+// priority: items with lower priority are evicted first, when the free space is not enough (see WithMinFreeSpace).
+// Items, written by another methods, have zero (the lowest) priority, so priority should be raised for valuable items
+// (e.g. user-facing results), while prefetched data can be written without it.
+func (pool *Pool) PutWithPriority(key string, from io.Reader, expiresAt time.Time, priority uint8) (CacheItem, error) {
+	return pool.put(key, from, file.Header{ExpiresAt: expiresAt, Priority: priority})
+}
+
+// Priority returns the eviction priority of this cache item (see Pool.PutWithPriority). Zero will be returned, if
+// priority cannot be read.
+func (item *Item) Priority() uint8 {
+	if !item.pool.acquire() {
+		return 0
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var priority uint8
+
+	_ = item.view(func(f *file.File) (err error) {
+		priority, err = f.GetPriority()
+		return
+	})
+
+	return priority
+}
@@ -0,0 +1,41 @@
+package filecache
+
+import "sync"
+
+// Progress describes the progress of the cache files walking operation (see Pool.ClearProgress and
+// Pool.PruneProgress).
+type Progress struct {
+	Scanned int // number of processed cache files
+	Removed int // number of removed cache files
+}
+
+// progress counts the processed cache files and reports the progress (it is safe for concurrent usage).
+type progress struct {
+	mu      sync.Mutex
+	current Progress
+	fn      func(Progress) // optional
+}
+
+// processed counts the processed file and calls the progress callback (calls are serialized).
+func (p *progress) processed(removed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current.Scanned++
+
+	if removed {
+		p.current.Removed++
+	}
+
+	if p.fn != nil {
+		p.fn(p.current)
+	}
+}
+
+// removed returns the number of removed files.
+func (p *progress) removed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.current.Removed
+}
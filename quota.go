@@ -0,0 +1,258 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+type (
+	// namespaceQuota is the cache items quota for the keys namespace (see WithNamespaceQuota).
+	namespaceQuota struct {
+		prefix   string
+		maxBytes int64 // zero means "unlimited"
+		maxItems int   // zero means "unlimited"
+	}
+
+	// quotaUsage is the namespace usage: number of the items and their data size in bytes.
+	quotaUsage struct {
+		bytes int64
+		items int
+	}
+
+	// quotaFile is the counted item file of the namespace.
+	quotaFile struct {
+		quota int // namespace quota index
+		size  int64
+	}
+
+	// quotas tracks the namespaces usage and evicts the namespace items, when it exceeds the quota. Usage is counted
+	// on the first write into any namespace (the pool directory is walked), and it is updated on the pool files
+	// changes after that.
+	quotas struct {
+		mu    sync.Mutex
+		pool  *Pool
+		list  []namespaceQuota     // sorted by the prefix length (longest first)
+		usage []quotaUsage         // by the quota index
+		files map[string]quotaFile // item file name to the counted file (nil, if usage is not counted yet)
+	}
+)
+
+// newQuotas creates the namespaces usage tracker (nil is returned without quotas).
+func newQuotas(pool *Pool, list []namespaceQuota) *quotas {
+	if len(list) == 0 {
+		return nil
+	}
+
+	list = append([]namespaceQuota(nil), list...)
+
+	sort.SliceStable(list, func(i, j int) bool { return len(list[i].prefix) > len(list[j].prefix) })
+
+	return &quotas{pool: pool, list: list, usage: make([]quotaUsage, len(list))}
+}
+
+// namespace returns the quota index for the key (-1, if the key does not belong to any namespace with the quota).
+func (q *quotas) namespace(key string) int {
+	for i := range q.list {
+		if strings.HasPrefix(key, q.list[i].prefix) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// exceeded reports whether the namespace usage exceeds its quota. Lock must be held.
+func (q *quotas) exceeded(i int) bool {
+	var (
+		quota = q.list[i]
+		usage = q.usage[i]
+	)
+
+	return (quota.maxBytes > 0 && usage.bytes > quota.maxBytes) || (quota.maxItems > 0 && usage.items > quota.maxItems)
+}
+
+// count walks the pool directory and counts the namespaces usage. Lock must be held.
+func (q *quotas) count() {
+	var mu sync.Mutex
+
+	q.files, q.usage = make(map[string]quotaFile), make([]quotaUsage, len(q.list))
+
+	_ = q.pool.walkOverCacheFiles(context.Background(), func(path string) {
+		if !isItemFileName(filepath.Base(path)) {
+			return
+		}
+
+		key, size, err := q.pool.quotaEntry(path)
+		if err != nil {
+			return
+		}
+
+		if i := q.namespace(key); i >= 0 {
+			mu.Lock()
+			q.set(filepath.Base(path), quotaFile{quota: i, size: size})
+			mu.Unlock()
+		}
+	})
+}
+
+// set updates the counted item file (previous file usage is subtracted). Lock must be held.
+func (q *quotas) set(name string, f quotaFile) {
+	q.unset(name)
+
+	q.files[name] = f
+	q.usage[f.quota].bytes += f.size
+	q.usage[f.quota].items++
+}
+
+// unset removes the counted item file. Lock must be held.
+func (q *quotas) unset(name string) {
+	if prev, exists := q.files[name]; exists {
+		q.usage[prev.quota].bytes -= prev.size
+		q.usage[prev.quota].items--
+
+		delete(q.files, name)
+	}
+}
+
+// changed updates the namespace usage for the written item file, and evicts the namespace items, when it exceeds the
+// quota. Quotas are nil-safe, and non-item files are ignored. Empty key means "unknown" (it is read from the file).
+func (q *quotas) changed(key, path string) {
+	if q == nil || !isItemFileName(filepath.Base(path)) {
+		return
+	}
+
+	if key != "" && q.namespace(key) < 0 && !q.counted() { // usage is counted on the first write into namespace
+		return
+	}
+
+	storedKey, size, err := q.pool.quotaEntry(path)
+	if err != nil {
+		q.forget(path)
+
+		return
+	}
+
+	if key == "" {
+		key = storedKey
+	}
+
+	i := q.namespace(key)
+
+	q.mu.Lock()
+
+	if q.files == nil {
+		if i < 0 {
+			q.mu.Unlock()
+
+			return
+		}
+
+		q.count() // written file is counted too
+	} else if i >= 0 {
+		q.set(filepath.Base(path), quotaFile{quota: i, size: size})
+	} else {
+		q.unset(filepath.Base(path))
+	}
+
+	exceeded := i >= 0 && q.exceeded(i)
+	q.mu.Unlock()
+
+	if exceeded {
+		q.evict(i)
+	}
+}
+
+// counted reports whether the namespaces usage is counted.
+func (q *quotas) counted() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.files != nil
+}
+
+// forget removes the item file from the namespace usage. Quotas are nil-safe, and non-item files are ignored.
+func (q *quotas) forget(path string) {
+	if q == nil || !isItemFileName(filepath.Base(path)) {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.files != nil {
+		q.unset(filepath.Base(path))
+	}
+}
+
+// evict removes the namespace items (expired and invalidated first, then in the eviction policy order - see
+// WithEvictionPolicy) until the namespace usage fits the quota. Pinned and currently locked items are skipped.
+func (q *quotas) evict(i int) {
+	q.mu.Lock()
+
+	var names = make([]string, 0, q.usage[i].items)
+
+	for name, f := range q.files {
+		if f.quota == i {
+			names = append(names, name)
+		}
+	}
+
+	q.mu.Unlock()
+
+	var (
+		pool       = q.pool
+		epoch      = pool.currentEpoch()
+		candidates = make([]evictionCandidate, 0, len(names))
+	)
+
+	for _, name := range names {
+		path := filepath.Join(pool.dirPath, name)
+
+		e, err := pool.readIndexEntry(path)
+		if err != nil || e.Flags.Has(file.FlagPinned) {
+			continue
+		}
+
+		c := evictionCandidate{path: path, usedAt: e.ModTime, expired: e.outdated(pool.now(), epoch)}
+
+		// priority, access time and reads counter are not indexed
+		if f, err := pool.openFile(path, os.O_RDONLY, 0); err == nil {
+			c.usedAt, c.hits = pool.lastUsed(f, c.usedAt), pool.evictionHits(f)
+			c.priority, _ = f.GetPriority()
+			_ = f.Close()
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	_ = pool.evictCandidates(candidates, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		return !q.exceeded(i)
+	})
+}
+
+// quotaEntry reads the stored key and the data size of the cache item file (file signature is verified).
+func (pool *Pool) quotaEntry(path string) (string, int64, error) {
+	f, err := pool.openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	e, err := pool.fileIndexEntry(f, path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	key, err := f.GetKey()
+
+	return key, e.Size, err
+}
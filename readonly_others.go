@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package filecache
+
+// isReadOnlyFS always returns false, because read-only filesystem errors are not distinguished on this operating
+// system (permission errors are reported only).
+func isReadOnlyFS(error) bool { return false }
@@ -0,0 +1,12 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package filecache
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isReadOnlyFS reports whether the error is caused by the read-only filesystem.
+func isReadOnlyFS(err error) bool { return errors.Is(err, syscall.EROFS) }
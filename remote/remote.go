@@ -0,0 +1,293 @@
+// Package remote provides the cache pool over the object store (S3, GCS, etc.) client. Object store clients must be
+// wrapped into the ObjectStore interface implementation (package has no hard dependency on any SDK), so the remote
+// pool can be used as the secondary tier behind the file cache pool (see tiered package) with identical code paths.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+// ErrObjectNotFound must be returned (can be wrapped) by ObjectStore implementations for missing objects.
+var ErrObjectNotFound = errors.New("object not found")
+
+type (
+	// ObjectInfo contains object attributes (expiration time must be stored in the object metadata).
+	ObjectInfo struct {
+		Size      int64
+		ExpiresAt time.Time // zero value means "without expiring time"
+	}
+
+	// ObjectStore is the object store client.
+	ObjectStore interface {
+		// GetObject returns the object content reader and attributes.
+		GetObject(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+
+		// HeadObject returns the object attributes.
+		HeadObject(ctx context.Context, key string) (ObjectInfo, error)
+
+		// PutObject creates (or replaces) the object. Object size is passed in the info.
+		PutObject(ctx context.Context, key string, r io.Reader, info ObjectInfo) error
+
+		// DeleteObject removes the object.
+		DeleteObject(ctx context.Context, key string) error
+
+		// ListObjects calls fn for every object key with passed prefix.
+		ListObjects(ctx context.Context, prefix string, fn func(key string) error) error
+	}
+
+	// Option allows to setup the remote pool.
+	Option func(*Pool)
+
+	// Pool is the cache pool over the object store.
+	Pool struct {
+		store   ObjectStore
+		prefix  string
+		timeout time.Duration
+		now     func() time.Time // expiration time source (see WithClock)
+	}
+
+	// Item is the cache item, stored in the object store.
+	Item struct {
+		pool *Pool
+		key  string
+	}
+)
+
+// Interfaces implementation checks.
+var (
+	_ filecache.CachePool = (*Pool)(nil)
+	_ filecache.CacheItem = (*Item)(nil)
+)
+
+// WithPrefix sets the object keys prefix (e.g. "cache/").
+func WithPrefix(prefix string) Option { return func(p *Pool) { p.prefix = prefix } }
+
+// WithTimeout sets the timeout for every object store operation (zero means "without timeout").
+func WithTimeout(timeout time.Duration) Option { return func(p *Pool) { p.timeout = timeout } }
+
+// WithClock sets the time source for the expiration checks (system time is used by default). It should be the same
+// clock, that is used by the file cache pool (see filecache.WithClock), when the remote pool is the secondary tier.
+func WithClock(clock filecache.Clock) Option {
+	return func(p *Pool) {
+		if clock != nil {
+			p.now = clock.Now
+		}
+	}
+}
+
+// New creates the cache pool over the object store.
+func New(store ObjectStore, opts ...Option) *Pool {
+	p := &Pool{store: store, now: time.Now}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// context returns the context for the object store operation.
+func (p *Pool) context() (context.Context, context.CancelFunc) {
+	if p.timeout > 0 {
+		return context.WithTimeout(context.Background(), p.timeout)
+	}
+
+	return context.WithCancel(context.Background())
+}
+
+// objectKey returns the object key for the cache item key.
+func (p *Pool) objectKey(key string) string { return p.prefix + key }
+
+// GetDirPath returns the object keys prefix.
+func (p *Pool) GetDirPath() string { return p.prefix }
+
+// GetItem returns a Cache Item representing the specified key.
+func (p *Pool) GetItem(key string) filecache.CacheItem { return &Item{pool: p, key: key} }
+
+// HasItem confirms if the object store contains specified (not expired) cache item.
+func (p *Pool) HasItem(key string) bool { return p.GetItem(key).IsHit() }
+
+// Clear deletes all objects with the pool prefix.
+func (p *Pool) Clear() (bool, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	if err := p.store.ListObjects(ctx, p.prefix, func(key string) error {
+		if err := p.store.DeleteObject(ctx, key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteItem removes the item object.
+func (p *Pool) DeleteItem(key string) (bool, error) {
+	if err := p.GetItem(key).Delete(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Put a cache item with expiring time (value is read into memory, because object size must be known).
+func (p *Pool) Put(key string, from io.Reader, expiresAt time.Time) (filecache.CacheItem, error) {
+	item := &Item{pool: p, key: key}
+
+	if err := item.put(from, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// PutForever puts a cache item without expiring time (value is read into memory).
+func (p *Pool) PutForever(key string, from io.Reader) (filecache.CacheItem, error) {
+	return p.Put(key, from, time.Time{})
+}
+
+// Close does nothing (object store client must be closed by the caller).
+func (p *Pool) Close() error { return nil }
+
+// GetFilePath returns the object key.
+func (i *Item) GetFilePath() string { return i.pool.objectKey(i.key) }
+
+// GetKey returns the key for the current cache item.
+func (i *Item) GetKey() string { return i.key }
+
+// Get retrieves the object content. filecache.ErrNotFound and filecache.ErrExpired errors are returned (wrapped) for
+// missing and expired objects.
+func (i *Item) Get(to io.Writer) error {
+	ctx, cancel := i.pool.context()
+	defer cancel()
+
+	r, info, err := i.pool.store.GetObject(ctx, i.GetFilePath())
+	if err != nil {
+		return i.wrapError(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	if i.pool.expired(info) {
+		return fmt.Errorf("object [%s]: %w", i.GetFilePath(), filecache.ErrExpired)
+	}
+
+	_, err = io.Copy(to, r)
+
+	return err
+}
+
+// IsHit confirms if the object exists and is not expired.
+func (i *Item) IsHit() bool {
+	info, err := i.head()
+
+	return err == nil && !i.pool.expired(info)
+}
+
+// Set the object content (expiration time is kept).
+func (i *Item) Set(from io.Reader) error {
+	var exp time.Time
+
+	if info, err := i.head(); err == nil {
+		exp = info.ExpiresAt
+	}
+
+	return i.put(from, exp)
+}
+
+// ExpiresAt returns the object expiration time. If expiration doesn't set - nil will be returned.
+func (i *Item) ExpiresAt() *time.Time {
+	if info, err := i.head(); err == nil && !info.ExpiresAt.IsZero() {
+		return &info.ExpiresAt
+	}
+
+	return nil
+}
+
+// SetExpiresAt sets the object expiration time (object is re-uploaded, because objects metadata is immutable).
+func (i *Item) SetExpiresAt(when time.Time) error {
+	buf := bytes.NewBuffer(nil)
+
+	ctx, cancel := i.pool.context()
+	defer cancel()
+
+	r, _, err := i.pool.store.GetObject(ctx, i.GetFilePath())
+	if err != nil {
+		return i.wrapError(err)
+	}
+
+	_, err = io.Copy(buf, r)
+	_ = r.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return i.put(buf, when)
+}
+
+// Delete removes the object. filecache.ErrNotFound error is returned (wrapped) for missing objects.
+func (i *Item) Delete() error {
+	ctx, cancel := i.pool.context()
+	defer cancel()
+
+	return i.wrapError(i.pool.store.DeleteObject(ctx, i.GetFilePath()))
+}
+
+// Size returns the object size.
+func (i *Item) Size() (int64, error) {
+	info, err := i.head()
+
+	return info.Size, err
+}
+
+// head returns the object attributes.
+func (i *Item) head() (ObjectInfo, error) {
+	ctx, cancel := i.pool.context()
+	defer cancel()
+
+	info, err := i.pool.store.HeadObject(ctx, i.GetFilePath())
+
+	return info, i.wrapError(err)
+}
+
+// put uploads the object.
+func (i *Item) put(from io.Reader, expiresAt time.Time) error {
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := i.pool.context()
+	defer cancel()
+
+	return i.pool.store.PutObject(ctx, i.GetFilePath(), bytes.NewReader(data), ObjectInfo{
+		Size:      int64(len(data)),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// wrapError wraps ErrObjectNotFound errors into filecache.ErrNotFound.
+func (i *Item) wrapError(err error) error {
+	if errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("object [%s]: %w", i.GetFilePath(), filecache.ErrNotFound)
+	}
+
+	return err
+}
+
+// expired reports whether the object expiration time is exceeded.
+func (p *Pool) expired(info ObjectInfo) bool {
+	return !info.ExpiresAt.IsZero() && info.ExpiresAt.Before(p.now())
+}
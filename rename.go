@@ -0,0 +1,134 @@
+package filecache
+
+import (
+	"fmt"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Rename moves the cache item to the new key (existing new key item is replaced), keeping its value, expiration time,
+// metadata and access statistics, so values can be promoted from temporary keys to the final ones. Stored key (see
+// Pool.Keys) is overwritten in place, when the keys are of the same length, and the item file is renamed without the
+// payload rewriting. Otherwise, data is verified and copied into the new item file (stored key precedes data). Missing
+// (and unreadable) items are not renamed, and ErrImmutable error is returned, if the new key item is immutable (see
+// PutImmutable). Rename is published as the old key deleting (see WithBroadcaster).
+func (pool *Pool) Rename(oldKey, newKey string) error {
+	if err := pool.renameItem(oldKey, newKey); err != nil {
+		return err
+	}
+
+	if oldKey == newKey {
+		return nil
+	}
+
+	return pool.publish(EventDelete, oldKey)
+}
+
+// renameItem renames the cache item without the event publishing.
+func (pool *Pool) renameItem(oldKey, newKey string) error {
+	if !pool.acquire() {
+		return errPoolClosed()
+	}
+	defer pool.release()
+
+	src, dst := newItem(pool, oldKey), newItem(pool, newKey)
+
+	unlock := lockItems(src, dst)
+	defer unlock()
+
+	f, err := src.open()
+	if err != nil {
+		return src.openError(err)
+	}
+
+	if err = src.readable(f); err == nil && oldKey != newKey {
+		err = dst.mutable()
+	}
+
+	if err != nil || oldKey == newKey {
+		_ = f.Close()
+
+		return err
+	}
+
+	stored, err := f.GetKey()
+	if err != nil {
+		_ = f.Close()
+
+		return newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] key", src.GetFilePath()), err)
+	}
+
+	if stored == "" || len(stored) != len(newKey) {
+		return src.rewriteTo(dst, f)
+	}
+
+	return src.moveTo(dst, f)
+}
+
+// moveTo overwrites the stored key in the opened item file (it must be of the same length) and renames the file to the
+// destination item file path. Opened file is closed.
+func (item *Item) moveTo(dst *Item, f *file.File) error {
+	current, err := dst.version()
+	if err == nil {
+		err = f.SetKey(dst.key)
+	}
+
+	if err == nil {
+		err = f.SetVersion(uint64(item.pool.nextVersion(current)))
+	}
+
+	if closeErr := f.Close(); err == nil { // file must be closed before renaming
+		err = closeErr
+	}
+
+	if err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", item.GetFilePath()), err)
+	}
+
+	if err = item.pool.rename(item.GetFilePath(), dst.GetFilePath()); err != nil {
+		return writeError(fmt.Sprintf("cannot rename file [%s]", item.GetFilePath()), err)
+	}
+
+	item.pool.fileChanged(dst.key, dst.GetFilePath())
+
+	return nil
+}
+
+// rewriteTo copies the opened item file (with its header values) into the destination item file and removes the item
+// file. Opened file is closed.
+func (item *Item) rewriteTo(dst *Item, f *file.File) error {
+	h, err := f.GetHeader()
+	if err != nil {
+		_ = f.Close()
+
+		return newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] header", item.GetFilePath()), err)
+	}
+
+	if err = dst.rewrite(f, h); err != nil {
+		return err
+	}
+
+	return item.delete()
+}
+
+// lockItems locks both items in the lock stripes order (so concurrent renames cannot deadlock) and returns the
+// function for unlocking. Items, sharing the lock stripe, are locked once.
+func lockItems(a, b *Item) func() {
+	if a.mutex == b.mutex {
+		a.mutex.Lock()
+
+		return a.mutex.Unlock
+	}
+
+	if itemLockIndex(a.fileName) > itemLockIndex(b.fileName) {
+		a, b = b, a
+	}
+
+	a.mutex.Lock()
+	b.mutex.Lock()
+
+	return func() {
+		b.mutex.Unlock()
+		a.mutex.Unlock()
+	}
+}
@@ -0,0 +1,362 @@
+// Package replicated provides the replicating cache pool: every value is written into all replica pools (e.g. local
+// SSD and shared NFS directories), and values are read from the first replica, that contains the item (so replicas
+// must be passed in the preferred reading order - the fastest first). Replicas, that miss the found value, can be
+// repaired on reading (see WithReadRepair).
+package replicated
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+// Consistency defines how many replicas must be written successfully.
+type Consistency uint8
+
+const (
+	// WriteAll requires all replicas to be written (default). The first replica writing error is returned, and
+	// replicas, written before the failure, keep the new value.
+	WriteAll Consistency = iota
+
+	// WriteAny requires at least one replica to be written. Failed replicas are skipped (they can contain the previous
+	// value, so it is good for the replicas with the independent expiration), and the first error is returned only if
+	// all replicas are failed.
+	WriteAny
+)
+
+type (
+	// Pool is the replicating cache pool.
+	Pool struct {
+		replicas    []filecache.CachePool
+		consistency Consistency
+		readRepair  bool
+	}
+
+	// Option allows to set up the replicating cache pool.
+	Option func(*Pool)
+
+	// Item is the replicating cache item.
+	Item struct {
+		pool  *Pool
+		key   string
+		items []filecache.CacheItem // in the replicas order
+	}
+)
+
+// Interfaces implementation checks.
+var (
+	_ filecache.CachePool = (*Pool)(nil)
+	_ filecache.CacheItem = (*Item)(nil)
+)
+
+// New creates the replicating cache pool. At least one replica must be passed.
+func New(replicas []filecache.CachePool, opts ...Option) *Pool {
+	if len(replicas) == 0 {
+		panic("replicated: at least one replica is required") // it is a programming error
+	}
+
+	p := &Pool{replicas: replicas, consistency: WriteAll}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// WithConsistency sets the replicas writing consistency (default is WriteAll).
+func WithConsistency(c Consistency) Option {
+	return func(p *Pool) { p.consistency = c }
+}
+
+// WithReadRepair enables writing of the found value (with the same expiration time) into the preceding replicas, that
+// miss it, so hot values migrate into the faster replicas (default is disabled). Repairing errors are ignored.
+func WithReadRepair(enabled bool) Option {
+	return func(p *Pool) { p.readRepair = enabled }
+}
+
+// GetDirPath returns the first replica directory path.
+func (p *Pool) GetDirPath() string { return p.replicas[0].GetDirPath() }
+
+// GetItem returns a Cache Item representing the specified key.
+func (p *Pool) GetItem(key string) filecache.CacheItem {
+	items := make([]filecache.CacheItem, len(p.replicas))
+
+	for i, replica := range p.replicas {
+		items[i] = replica.GetItem(key)
+	}
+
+	return &Item{pool: p, key: key, items: items}
+}
+
+// HasItem confirms if any replica contains specified cache item.
+func (p *Pool) HasItem(key string) bool {
+	for _, replica := range p.replicas {
+		if replica.HasItem(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clear deletes all items in all replicas.
+func (p *Pool) Clear() (bool, error) {
+	var (
+		cleared = true
+		err     error
+	)
+
+	for _, replica := range p.replicas {
+		if ok, clearErr := replica.Clear(); !ok {
+			cleared, err = false, firstError(err, clearErr)
+		}
+	}
+
+	return cleared, err
+}
+
+// DeleteItem removes the item from all replicas. Missing item in some of the replicas is not an error.
+func (p *Pool) DeleteItem(key string) (bool, error) {
+	var (
+		deleted bool
+		err     error
+	)
+
+	for _, replica := range p.replicas {
+		ok, delErr := replica.DeleteItem(key)
+		deleted, err = deleted || ok, firstError(err, delErr)
+	}
+
+	if deleted {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// Put a cache item with expiring time into the replicas (value is read into memory).
+func (p *Pool) Put(key string, from io.Reader, expiresAt time.Time) (filecache.CacheItem, error) {
+	return p.put(key, from, func(replica filecache.CachePool, r io.Reader) error {
+		_, err := replica.Put(key, r, expiresAt)
+		return err
+	})
+}
+
+// PutForever puts a cache item without expiring time into the replicas (value is read into memory).
+func (p *Pool) PutForever(key string, from io.Reader) (filecache.CacheItem, error) {
+	return p.put(key, from, func(replica filecache.CachePool, r io.Reader) error {
+		_, err := replica.PutForever(key, r)
+		return err
+	})
+}
+
+// put writes the value into the replicas according to the pool consistency.
+func (p *Pool) put(
+	key string, from io.Reader, put func(filecache.CachePool, io.Reader) error,
+) (filecache.CacheItem, error) {
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.write(len(p.replicas), func(i int) error {
+		return put(p.replicas[i], bytes.NewReader(data))
+	}); err != nil {
+		return nil, err
+	}
+
+	return p.GetItem(key), nil
+}
+
+// write calls the function for every replica index according to the pool consistency.
+func (p *Pool) write(n int, fn func(i int) error) error {
+	var (
+		written int
+		err     error
+	)
+
+	for i := 0; i < n; i++ {
+		if writeErr := fn(i); writeErr != nil {
+			if p.consistency == WriteAll {
+				return writeErr
+			}
+
+			err = firstError(err, writeErr)
+
+			continue
+		}
+
+		written++
+	}
+
+	if written == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// Close closes all replicas.
+func (p *Pool) Close() error {
+	var err error
+
+	for _, replica := range p.replicas {
+		err = firstError(err, replica.Close())
+	}
+
+	return err
+}
+
+// GetFilePath returns the first replica item file path.
+func (i *Item) GetFilePath() string { return i.items[0].GetFilePath() }
+
+// GetKey returns the key for the current cache item.
+func (i *Item) GetKey() string { return i.key }
+
+// Get retrieves the value from the first replica, that contains the item. Failed replicas are skipped, and the first
+// replica error (real errors are preferred over misses) is returned, if no replica returned the value.
+func (i *Item) Get(to io.Writer) error {
+	var (
+		err    error
+		missed []int // indexes of the preceding replicas, that miss the value
+	)
+
+	for n, item := range i.items {
+		buf := bytes.NewBuffer(nil) // partially read value must not be written
+
+		getErr := item.Get(buf)
+		if getErr == nil {
+			if i.pool.readRepair {
+				i.repair(missed, item.ExpiresAt(), buf.Bytes())
+			}
+
+			_, err = to.Write(buf.Bytes())
+
+			return err
+		}
+
+		if isMiss(getErr) {
+			missed = append(missed, n)
+		}
+
+		if err == nil || isMiss(err) && !isMiss(getErr) { // real errors are preferred over misses
+			err = getErr
+		}
+	}
+
+	return err
+}
+
+// repair writes the found value into the replicas with passed indexes (nil expiration time means "without expiring
+// time").
+func (i *Item) repair(missed []int, exp *time.Time, data []byte) {
+	for _, n := range missed {
+		if exp != nil {
+			_, _ = i.pool.replicas[n].Put(i.key, bytes.NewReader(data), *exp)
+		} else {
+			_, _ = i.pool.replicas[n].PutForever(i.key, bytes.NewReader(data))
+		}
+	}
+}
+
+// IsHit confirms if any replica contains the cache item.
+func (i *Item) IsHit() bool {
+	for _, item := range i.items {
+		if item.IsHit() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set sets the value in the replicas according to the pool consistency (value is read into memory).
+func (i *Item) Set(from io.Reader) error {
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return err
+	}
+
+	return i.pool.write(len(i.items), func(n int) error { return i.items[n].Set(bytes.NewReader(data)) })
+}
+
+// ExpiresAt returns the expiration time from the first replica, that contains the item.
+func (i *Item) ExpiresAt() *time.Time {
+	for _, item := range i.items {
+		if item.IsHit() {
+			return item.ExpiresAt()
+		}
+	}
+
+	return nil
+}
+
+// SetExpiresAt sets the expiration time in the replicas, that contain the item, according to the pool consistency.
+func (i *Item) SetExpiresAt(when time.Time) error {
+	var found = make([]filecache.CacheItem, 0, len(i.items))
+
+	for _, item := range i.items {
+		if item.IsHit() {
+			found = append(found, item)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	return i.pool.write(len(found), func(n int) error { return found[n].SetExpiresAt(when) })
+}
+
+// Delete removes the item from all replicas. Missing item in some of the replicas is not an error.
+func (i *Item) Delete() error {
+	var (
+		deleted bool
+		err     error
+	)
+
+	for _, item := range i.items {
+		delErr := item.Delete()
+		deleted, err = deleted || delErr == nil, firstError(err, delErr)
+	}
+
+	if deleted {
+		return nil
+	}
+
+	return err
+}
+
+// Size returns the value size from the first replica, that contains the item.
+func (i *Item) Size() (int64, error) {
+	for _, item := range i.items[:len(i.items)-1] {
+		if item.IsHit() {
+			return item.Size()
+		}
+	}
+
+	return i.items[len(i.items)-1].Size()
+}
+
+// isMiss reports whether the error means the cache miss.
+func isMiss(err error) bool {
+	return errors.Is(err, filecache.ErrNotFound) ||
+		errors.Is(err, filecache.ErrExpired) ||
+		errors.Is(err, filecache.ErrInvalidated)
+}
+
+// firstError returns the first non-nil error.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package filecache
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Report contains the largest and the most frequently read cache items (see Pool.Report).
+	Report struct {
+		Largest []ReportEntry // ordered by size (descending)
+		Hottest []ReportEntry // ordered by reads counter (descending), nil if hit counting is disabled
+	}
+
+	// ReportEntry describes the cache item in the report.
+	ReportEntry struct {
+		Key       string        // empty for items, written before the keys persisting (see Keys)
+		Size      int64         // value size in bytes
+		Age       time.Duration // time since the last writing
+		ExpiresAt time.Time     // zero value means "without expiring time"
+		Hits      uint32        // see WithHitCounting
+	}
+)
+
+// Report returns up to n largest cache items and up to n most frequently read items (if hit counting is enabled - see
+// WithHitCounting), for the capacity planning and debugging (negative n means "all items"). Expired and invalidated
+// items are skipped. Item files are opened for the attributes reading.
+func (pool *Pool) Report(n int) (Report, error) {
+	if !pool.acquire() {
+		return Report{}, errPoolClosed()
+	}
+	defer pool.release()
+
+	entries, err := pool.reportEntries(context.Background())
+	if err != nil {
+		return Report{}, err
+	}
+
+	var report Report
+
+	report.Largest = topEntries(entries, n, func(a, b ReportEntry) bool { return a.Size > b.Size })
+
+	if pool.hitCounting {
+		report.Hottest = topEntries(entries, n, func(a, b ReportEntry) bool { return a.Hits > b.Hits })
+	}
+
+	return report, nil
+}
+
+// reportEntries returns the entries of all actual (not expired and not invalidated) cache items in the walking order.
+func (pool *Pool) reportEntries(ctx context.Context) ([]ReportEntry, error) {
+	var (
+		entries = make([]ReportEntry, 0)
+		mu      sync.Mutex
+		now     = pool.now()
+		epoch   = pool.currentEpoch()
+	)
+
+	if err := pool.walkOverCacheFiles(ctx, func(filePath string) {
+		if !isItemFileName(filepath.Base(filePath)) || pool.fileOutdated(filePath, now, epoch) {
+			return
+		}
+
+		key, info, ok := pool.readItemInfo(filePath)
+		if !ok {
+			return
+		}
+
+		mu.Lock()
+		entries = append(entries, ReportEntry{
+			Key:       key,
+			Size:      info.Size,
+			Age:       now.Sub(info.ModTime),
+			ExpiresAt: info.ExpiresAt,
+			Hits:      info.Hits,
+		})
+		mu.Unlock()
+	}); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// topEntries returns up to n first entries (the copy) in the passed order (entries with the same order are ordered by
+// keys).
+func topEntries(entries []ReportEntry, n int, less func(a, b ReportEntry) bool) []ReportEntry {
+	sorted := append([]ReportEntry(nil), entries...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	if n >= 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
@@ -0,0 +1,179 @@
+package filecache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// resumeFlushSize is the resume writer buffer size (buffered data is appended to the item value, when the buffer is
+// full).
+const resumeFlushSize = 1024 * 1024
+
+// PartialState is the state of the partially written (e.g. interrupted downloading) chunked item value.
+type PartialState struct {
+	Size   int64            // size of the data in complete chunks
+	Chunks []file.ChunkInfo // complete chunks (e.g. for the comparison with the source checksums)
+}
+
+// PartialState returns the state of the partially written item value: complete chunks are read (chunks payloads are not
+// verified, see Resume), and the torn tail is skipped. Values must be written in chunked data format (see
+// WithChunkedWrites option).
+func (item *Item) PartialState() (PartialState, error) {
+	if !item.pool.acquire() {
+		return PartialState{}, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var filePath = item.GetFilePath()
+
+	f, openErr := item.open()
+	if openErr != nil {
+		return PartialState{}, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if !f.IsChunked() {
+		return PartialState{}, newError(ErrUnknown, fmt.Sprintf("file [%s] data is not chunked", filePath), nil)
+	}
+
+	chunks, err := f.Chunks()
+	if err != nil {
+		return PartialState{}, newError(ErrFileReading, fmt.Sprintf("file [%s] read error", filePath), err)
+	}
+
+	var state = PartialState{Chunks: chunks}
+
+	for _, c := range chunks {
+		state.Size += int64(c.Length)
+	}
+
+	return state, nil
+}
+
+// ResumeWriter returns the writer, that continues interrupted item value writing (e.g. HTTP downloading with the
+// "Range" header) from passed offset: incomplete (and corrupted) chunks are removed, the data after the offset is
+// truncated, and written data is appended to the value (see Append) by 1 MiB parts, so the writing can be resumed
+// again after the next interruption (see PartialState). Item is created for zero offset, if it does not exist.
+// Writer must be closed for the buffered data writing. Values must be written in chunked data format (see
+// WithChunkedWrites option), and ErrOutOfRange is returned, if the offset exceeds the size of the valid data.
+func (item *Item) ResumeWriter(offset int64) (io.WriteCloser, error) {
+	if !item.pool.acquire() {
+		return nil, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	var filePath = item.GetFilePath()
+
+	if item.pool.chunkSize <= 0 {
+		return nil, newError(ErrUnknown, fmt.Sprintf("file [%s] data is not chunked", filePath), nil)
+	}
+
+	if err := item.mutable(); err != nil {
+		return nil, err
+	}
+
+	f, openErr := item.open()
+	if os.IsNotExist(openErr) && offset == 0 {
+		h := file.Header{Key: item.key, Epoch: item.pool.currentEpoch()}
+
+		err := item.pool.writeEntry(filePath, item.filePerm(), h, bytes.NewReader(nil), item.pool.chunkSize)
+		if err != nil {
+			return nil, writeError(fmt.Sprintf("cannot create file [%s]", filePath), err)
+		}
+
+		item.pool.fileChanged(item.key, filePath)
+
+		return &resumeWriter{item: item}, nil
+	}
+
+	if openErr != nil {
+		return nil, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if !f.IsChunked() {
+		return nil, newError(ErrUnknown, fmt.Sprintf("file [%s] data is not chunked", filePath), nil)
+	}
+
+	if err := f.TruncateChunks(offset); err != nil {
+		if errors.Is(err, file.ErrOutOfRange) {
+			return nil, newError(ErrOutOfRange, fmt.Sprintf("file [%s] offset is out of bounds", filePath), err)
+		}
+
+		return nil, writeError(fmt.Sprintf("cannot truncate file [%s]", filePath), err)
+	}
+
+	item.pool.fileChanged(item.key, filePath)
+
+	return &resumeWriter{item: item}, nil
+}
+
+// resumeWriter buffers the written data and appends it to the item value.
+type resumeWriter struct {
+	item   *Item
+	buf    bytes.Buffer
+	err    error // the first writing error (writer is broken after it)
+	closed bool
+}
+
+// Write implements io.Writer interface.
+func (w *resumeWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf.Write(p)
+
+	if w.buf.Len() >= resumeFlushSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the buffered data.
+func (w *resumeWriter) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.flush()
+}
+
+// flush appends the buffered data to the item value.
+func (w *resumeWriter) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	if w.err = w.item.Append(&w.buf); w.err != nil {
+		return w.err
+	}
+
+	w.buf.Reset()
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeleteExpiredBefore deletes items, that expire before passed time (items without expiration time are kept), and
+// returns the number of deleted items, so custom retention schedules can be implemented (e.g. "remove everything, that
+// expires this week").
+func (pool *Pool) DeleteExpiredBefore(t time.Time) (int, error) {
+	return pool.DeleteExpiredBeforeContext(context.Background(), t)
+}
+
+// DeleteExpiredBeforeContext is DeleteExpiredBefore, that is stopped when the context is canceled.
+func (pool *Pool) DeleteExpiredBeforeContext(ctx context.Context, t time.Time) (int, error) {
+	return pool.deleteMatched(ctx, func(e indexEntry) bool {
+		return !e.ExpiresAt.IsZero() && e.ExpiresAt.Before(t)
+	})
+}
+
+// DeleteOlderThan deletes items, that were written (or updated) earlier than age ago, regardless of their expiration
+// time, and returns the number of deleted items.
+func (pool *Pool) DeleteOlderThan(age time.Duration) (int, error) {
+	return pool.DeleteOlderThanContext(context.Background(), age)
+}
+
+// DeleteOlderThanContext is DeleteOlderThan, that is stopped when the context is canceled.
+func (pool *Pool) DeleteOlderThanContext(ctx context.Context, age time.Duration) (int, error) {
+	var threshold = pool.now().Add(-age)
+
+	return pool.deleteMatched(ctx, func(e indexEntry) bool { return e.ModTime.Before(threshold) })
+}
+
+// deleteMatched deletes cache item files, that match the function, using the bounded number of goroutines (see
+// WithWalkConcurrency option) and returns the number of deleted items. Pool index entries are used, if the index is
+// enabled; otherwise, item files headers are read (files with wrong signature, and incomplete files are kept).
+func (pool *Pool) deleteMatched(ctx context.Context, match func(e indexEntry) bool) (int, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	var (
+		lastErr error
+		mu      sync.Mutex
+		p       progress
+	)
+
+	var entries map[string]indexEntry // index entries allow to skip files opening
+
+	if pool.index != nil {
+		entries, _ = pool.index.snapshot()
+	}
+
+	err := pool.walkOverCacheFiles(ctx, func(path string) {
+		if !isItemFileName(filepath.Base(path)) {
+			return
+		}
+
+		lock := pool.fileLock(path) // item must not be rewritten between the checking and removing
+		lock.Lock()
+		defer lock.Unlock()
+
+		e, indexed := entries[filepath.Base(path)]
+		if !indexed {
+			var readErr error
+
+			if e, readErr = pool.readIndexEntry(path); readErr != nil {
+				p.processed(false)
+
+				return
+			}
+		}
+
+		if !match(e) {
+			p.processed(false)
+
+			return
+		}
+
+		rmErr := pool.removeFile(path)
+
+		if rmErr != nil && !os.IsNotExist(rmErr) {
+			mu.Lock()
+			lastErr = rmErr
+			mu.Unlock()
+		}
+
+		p.processed(rmErr == nil)
+	})
+
+	if err != nil {
+		return p.removed(), err
+	}
+
+	return p.removed(), lastErr
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package filecache
+
+// isTransient always returns false, because only "sharing violation" errors are known as transient on this operating
+// system (see isSharingViolation).
+func isTransient(error) bool { return false }
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package filecache
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTransient reports whether the error is transient: interrupted system call, busy resource or stale NFS file handle
+// (see WithRetry).
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ESTALE)
+}
@@ -0,0 +1,129 @@
+package filecache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// DataSection is the reader of the cache item file data section (it implements io.ReaderAt, io.ReadSeeker and
+// io.Closer interfaces, so it can be used with http.ServeContent, etc.). It must be closed after usage.
+type DataSection struct {
+	*io.SectionReader
+	f    *file.File
+	data file.Handle // raw data file (nil for the regular values, see WithSplitLayout)
+}
+
+// Close closes the cache item file (and the raw data file for the detached values).
+func (s *DataSection) Close() error {
+	if s.data != nil {
+		_ = s.data.Close()
+	}
+
+	return s.f.Close()
+}
+
+// DataSectionReader opens the cache item file and returns the reader of its data section and data size, so the value
+// can be served without buffering. Data hash sum is NOT verified, and the value must not be overwritten while it is
+// read (overwriting must be avoided by the caller). Returned section must be closed. ErrNotSupported error is returned
+// for the transformed values (see WithTransformers).
+func (item *Item) DataSectionReader() (*DataSection, int64, error) {
+	if !item.pool.acquire() {
+		return nil, 0, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return nil, 0, item.openError(openErr)
+	}
+
+	if err := item.readable(f); err != nil {
+		_ = f.Close()
+
+		return nil, 0, err
+	}
+
+	if err := item.untransformed(f); err != nil {
+		_ = f.Close()
+
+		return nil, 0, err
+	}
+
+	r, data, err := item.pool.dataSection(f)
+	if err != nil {
+		_ = f.Close()
+
+		return nil, 0, newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
+	}
+
+	return &DataSection{SectionReader: r, f: f, data: data}, r.Size(), nil
+}
+
+// GetRange writes length bytes of the cache item value, starting from the value offset off, to the writer
+// (ErrOutOfRange error is returned for ranges, exceeding the value size). Regular values hash sum is NOT verified for
+// the range, but chunked values (see WithChunkedWrites option) are verified for the chunks, overlapping with the range.
+// ErrNotSupported error is returned for the transformed values (see WithTransformers).
+func (item *Item) GetRange(to io.Writer, off, length int64) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if err := item.readable(f); err != nil {
+		return err
+	}
+
+	if err := item.untransformed(f); err != nil {
+		return err
+	}
+
+	if err := item.pool.getDataRange(f, to, off, length); err != nil {
+		switch {
+		case errors.Is(err, file.ErrOutOfRange):
+			return newError(ErrOutOfRange, fmt.Sprintf("file [%s] range is out of bounds", item.GetFilePath()), err)
+
+		case errors.Is(err, file.ErrDataCorrupted):
+			_ = f.Close() // file must be closed before removing
+
+			return item.onCorruption(
+				newError(ErrCorrupted, fmt.Sprintf("file [%s] data is corrupted", item.GetFilePath()), err),
+			)
+		}
+
+		return newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
+	}
+
+	return nil
+}
+
+// untransformed returns ErrNotSupported error for the item files with transformed data (stored data cannot be read
+// partially, see WithTransformers).
+func (item *Item) untransformed(f *file.File) error {
+	flags, err := f.GetFlags()
+	if err != nil {
+		return newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
+	}
+
+	if transformed := flags & transformFlags; transformed != 0 {
+		return newError(ErrNotSupported, fmt.Sprintf(
+			"file [%s] data is transformed (%s) and cannot be read partially", item.GetFilePath(), transformed,
+		), nil)
+	}
+
+	return nil
+}
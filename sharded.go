@@ -0,0 +1,241 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ShardFunc returns the shard index (in range [0, shards)) for the cache item key. It must be deterministic, so the
+// same key is always routed into the same shard.
+type ShardFunc func(key string, shards int) int
+
+// ShardedPool spreads the cache items across multiple pools (e.g. directories on different disks) by the keys hashing,
+// so disks throughput is summed, and every shard applies its own capacity limits (see WithMinFreeSpace and
+// WithMaxValueSize).
+type ShardedPool struct {
+	shards []*Pool
+	hash   ShardFunc
+}
+
+var _ CachePool = (*ShardedPool)(nil)
+
+// HashShard is the default shard function (FNV-1a hash of the key modulo the number of shards). Keys are remapped
+// almost completely, when the number of shards is changed.
+func HashShard(key string, shards int) int { return int(hashString(key) % uint32(shards)) }
+
+// defaultShardReplicas is the default number of the consistent hash ring points per shard.
+const defaultShardReplicas = 128
+
+// ConsistentShard returns the consistent hashing shard function for the shards with passed names (e.g. directory
+// paths, in the shards order), so only about 1/n of keys are remapped, when the shard is added (see
+// ShardedPool.Rebalance). Every shard is placed on the hash ring replicas times (zero or negative replicas means 128).
+// Function must be used with the same number of shards, as the number of passed names.
+func ConsistentShard(names []string, replicas int) ShardFunc {
+	if replicas <= 0 {
+		replicas = defaultShardReplicas
+	}
+
+	type point struct {
+		hash  uint32
+		shard int
+	}
+
+	var ring = make([]point, 0, len(names)*replicas)
+
+	for i, name := range names {
+		for r := 0; r < replicas; r++ {
+			ring = append(ring, point{hash: ringHash(name + "#" + strconv.Itoa(r)), shard: i})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	return func(key string, _ int) int {
+		h := ringHash(key)
+
+		i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+		if i == len(ring) {
+			i = 0 // ring is wrapped
+		}
+
+		return ring[i].shard
+	}
+}
+
+// ringHash returns the hash ring position of the string: FNV-1a hash is finalized (as in MurmurHash3), so similar
+// strings (e.g. "/mnt/disk1" and "/mnt/disk2") are spread over the ring evenly.
+func ringHash(s string) uint32 {
+	h := hashString(s)
+
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return h
+}
+
+// hashString returns FNV-1a hash of the string.
+func hashString(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+
+	return h.Sum32()
+}
+
+// NewShardedPool creates the pool for every directory (with the same options) and returns the sharded pool. Nil hash
+// means HashShard. At least one directory must be passed.
+func NewShardedPool(dirs []string, hash ShardFunc, opts ...Option) *ShardedPool {
+	if len(dirs) == 0 {
+		panic("filecache: sharded pool requires at least one directory") // it is a programming error
+	}
+
+	if hash == nil {
+		hash = HashShard
+	}
+
+	shards := make([]*Pool, len(dirs))
+
+	for i, dir := range dirs {
+		shards[i] = NewPool(dir, opts...)
+	}
+
+	return &ShardedPool{shards: shards, hash: hash}
+}
+
+// Shards returns the shard pools (in the directories order).
+func (s *ShardedPool) Shards() []*Pool { return append([]*Pool(nil), s.shards...) }
+
+// Shard returns the shard pool for the key.
+func (s *ShardedPool) Shard(key string) *Pool { return s.shards[s.hash(key, len(s.shards))] }
+
+// GetDirPath returns the first shard directory path.
+func (s *ShardedPool) GetDirPath() string { return s.shards[0].GetDirPath() }
+
+// GetItem returns a Cache Item representing the specified key.
+func (s *ShardedPool) GetItem(key string) CacheItem { return s.Shard(key).GetItem(key) }
+
+// HasItem confirms if the cache contains specified cache item.
+func (s *ShardedPool) HasItem(key string) bool { return s.Shard(key).HasItem(key) }
+
+// Clear deletes all items in all shards.
+func (s *ShardedPool) Clear() (bool, error) {
+	var (
+		cleared = true
+		err     error
+	)
+
+	for _, shard := range s.shards {
+		if ok, clearErr := shard.Clear(); !ok {
+			cleared = false
+
+			if err == nil {
+				err = clearErr
+			}
+		}
+	}
+
+	return cleared, err
+}
+
+// DeleteItem removes the item from the pool.
+func (s *ShardedPool) DeleteItem(key string) (bool, error) { return s.Shard(key).DeleteItem(key) }
+
+// Put a cache item with expiring time.
+func (s *ShardedPool) Put(key string, from io.Reader, expiresAt time.Time) (CacheItem, error) {
+	return s.Shard(key).Put(key, from, expiresAt)
+}
+
+// PutForever puts a cache item without expiring time.
+func (s *ShardedPool) PutForever(key string, from io.Reader) (CacheItem, error) {
+	return s.Shard(key).PutForever(key, from)
+}
+
+// Prune deletes expired and invalidated items from all shards and returns the number of deleted items.
+func (s *ShardedPool) Prune() (int, error) {
+	var (
+		total int
+		err   error
+	)
+
+	for _, shard := range s.shards {
+		n, pruneErr := shard.Prune()
+		total += n
+
+		if err == nil {
+			err = pruneErr
+		}
+	}
+
+	return total, err
+}
+
+// Count returns the number of valid cache items in all shards (see Pool.Count).
+func (s *ShardedPool) Count() (int, error) {
+	var total int
+
+	for _, shard := range s.shards {
+		n, err := shard.Count()
+		if err != nil {
+			return 0, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+// Rebalance moves the cache items, located in the wrong shards (e.g. after the directory adding), into the shards,
+// returned by the shard function, and returns the number of moved items. Items without stored keys (see Pool.Keys)
+// cannot be moved and are left in place. Directory can be removed from the shards set only after moving its items
+// into another shards (see Pool.MoveTo). Rebalancing is stopped, when the context is canceled.
+func (s *ShardedPool) Rebalance(ctx context.Context) (int, error) {
+	var moved int
+
+	for i, shard := range s.shards {
+		keys, err := shard.keys(ctx, func(string) bool { return true })
+		if err != nil {
+			return moved, err
+		}
+
+		for _, key := range keys {
+			if err = ctx.Err(); err != nil {
+				return moved, err
+			}
+
+			if target := s.hash(key, len(s.shards)); target != i {
+				switch err = shard.MoveTo(s.shards[target], key); {
+				case err == nil:
+					moved++
+
+				case errors.Is(err, ErrNotFound) || errors.Is(err, ErrExpired): // removed (or expired) after listing
+
+				default:
+					return moved, err
+				}
+			}
+		}
+	}
+
+	return moved, nil
+}
+
+// Close closes all shards.
+func (s *ShardedPool) Close() error {
+	var err error
+
+	for _, shard := range s.shards {
+		if closeErr := shard.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package filecache
+
+// isSharingViolation always returns false, because files that are used by another process can be opened, renamed and
+// removed on this operating system.
+func isSharingViolation(error) bool { return false }
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package filecache
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows system error codes (https://docs.microsoft.com/en-us/windows/win32/debug/system-error-codes--0-499-).
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isSharingViolation reports whether the error was caused by file that is used by another process.
+func isSharingViolation(err error) bool {
+	var errno syscall.Errno
+
+	if errors.As(err, &errno) {
+		switch errno {
+		case errorAccessDenied, errorSharingViolation, errorLockViolation:
+			return true
+		}
+	}
+
+	return false
+}
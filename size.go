@@ -0,0 +1,214 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// OversizePolicy defines what to do with values, that are larger than the limit (see WithMaxValueSize).
+type OversizePolicy uint8
+
+const (
+	// OversizeReject returns ErrTooLarge error, and value is not stored (default policy).
+	OversizeReject OversizePolicy = iota
+
+	// OversizeMarker stores the "too large, don't cache" marker instead of the value. Reading of such item returns
+	// ErrTooLarge error.
+	OversizeMarker
+)
+
+type (
+	// lenReader is implemented by readers, that know their unread data length (bytes.Buffer, bytes.Reader, etc.).
+	lenReader interface{ Len() int }
+
+	// countingReader counts read bytes.
+	countingReader struct {
+		r io.Reader
+		n int64
+	}
+)
+
+// Read implements io.Reader interface.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
+
+// writeLimited checks the item mutability and the free space, calls the write function with the reader, limited to the
+// pool max value size, and applies the oversize policy if the limit is exceeded. Values of known length are checked
+// before writing, and the write function gets nil "fits" function. Another values are limited to "max value size + 1
+// byte" for exceeding detection, and the write function must write them into the staged files and call "fits" before
+// publishing, so the existing value is kept if the limit is exceeded. Nil expiresAt means "keep existing expiration
+// time" (is used for the marker writing).
+func (item *Item) writeLimited(from io.Reader, expiresAt *time.Time, write func(io.Reader, func() error) error) error {
+	if err := item.mutable(); err != nil {
+		return err
+	}
+
+	if err := item.pool.ensureFreeSpace(); err != nil {
+		return err
+	}
+
+	var limit = item.pool.maxValueSize
+
+	if limit <= 0 {
+		return write(from, nil)
+	}
+
+	var (
+		counter = &countingReader{r: io.LimitReader(from, limit+1)}
+		fits    = func() error {
+			if counter.n > limit {
+				return errTooLarge(item.key, limit)
+			}
+
+			return nil
+		}
+	)
+
+	if l, ok := from.(lenReader); ok {
+		if int64(l.Len()) > limit {
+			return item.onOversize(expiresAt, false)
+		}
+
+		fits = nil
+	}
+
+	err := write(counter, fits)
+
+	if counter.n > limit {
+		return item.onOversize(expiresAt, fits == nil)
+	}
+
+	return err
+}
+
+// onOversize applies the pool oversize policy. Flag "written" means that (partial) value was already written.
+func (item *Item) onOversize(expiresAt *time.Time, written bool) error {
+	var filePath = item.GetFilePath()
+
+	if item.pool.oversizePolicy == OversizeMarker {
+		var exp time.Time
+
+		if expiresAt != nil {
+			exp = *expiresAt
+		} else if existing, err := item.expiresAt(); err == nil {
+			exp = *existing
+		}
+
+		return item.writeFile(filePath, bytes.NewReader(nil), file.Header{ExpiresAt: exp, Flags: file.FlagTooLarge})
+	}
+
+	if written {
+		if err := item.pool.removeFile(filePath); err != nil && !os.IsNotExist(err) {
+			return writeError(fmt.Sprintf("cannot remove file [%s] with too large value", filePath), err)
+		}
+	}
+
+	return errTooLarge(item.key, item.pool.maxValueSize)
+}
+
+// errTooLarge creates an error for values, that are larger than the limit.
+func errTooLarge(key string, limit int64) *Error {
+	return newError(ErrTooLarge, fmt.Sprintf("value for the key [%s] is larger than %d bytes", key, limit), nil)
+}
+
+// Size returns the value size in bytes (file size without the header). Original value size is returned for the
+// transformed values (see WithTransformers).
+func (item *Item) Size() (int64, error) {
+	if !item.pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return 0, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	size, err := item.pool.valueSize(f)
+	if err != nil {
+		return 0, newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
+	}
+
+	return size, nil
+}
+
+// valueSize returns the item file value size: stored data size, or the original size for the transformed data (data
+// is decoded, if the original size is not stored, e.g. for the files, written by older versions).
+func (pool *Pool) valueSize(f *file.File) (int64, error) {
+	h, err := f.GetHeader()
+	if err != nil {
+		return 0, err
+	}
+
+	if h.Flags&transformFlags == 0 {
+		return dataSize(f)
+	}
+
+	if h.DecodedLength >= 0 {
+		return h.DecodedLength, nil
+	}
+
+	r, err := f.DataReader()
+	if err != nil {
+		return 0, err
+	}
+
+	decoded, err := pool.decode(r, h.Flags)
+	if err != nil {
+		return 0, err
+	}
+
+	return io.Copy(ioutil.Discard, decoded)
+}
+
+// TotalSize returns the sum of all valid cache item values sizes (files with wrong signature are skipped). Pool index
+// is used, if it is enabled.
+func (pool *Pool) TotalSize() (int64, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	var total int64
+
+	if pool.index != nil {
+		if entries, err := pool.index.snapshot(); err == nil {
+			for _, e := range entries {
+				total += e.Size
+			}
+
+			return total, nil
+		}
+	}
+
+	if err := pool.walkOverCacheFiles(context.Background(), func(path string) {
+		if !isItemFileName(filepath.Base(path)) {
+			return
+		}
+
+		if e, err := pool.readIndexEntry(path); err == nil {
+			atomic.AddInt64(&total, e.Size)
+		}
+	}); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
@@ -0,0 +1,399 @@
+package filecache
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Detached values (see WithSplitLayout) are stored as is in the raw data files next to the item files ("<hash>.data"
+// for "<hash>.meta"), and the item files contain the header values with the data descriptor only:
+// +------------------------+-------------------+
+// | Data length 0..7 (LE)  | Data SHA1 8..27   |
+// +------------------------+-------------------+
+// Descriptor is verified as the regular item file data, so the raw data file replacing is detected on reading.
+
+const (
+	// dataFileExt is the extension of the item files with raw (detached) data.
+	dataFileExt = ".data"
+
+	// dataTempFileExt is the extension of the temporary raw data files (they are renamed into the raw data files on
+	// publishing, so stale ones are left after the interrupted writing only).
+	dataTempFileExt = dataFileExt + ".tmp"
+
+	// dataDescriptorSize is the detached data descriptor size.
+	dataDescriptorSize = 8 + sha1.Size
+
+	// dataFlagsMask is the set of flags, describing the item file data (they are set on every value writing).
+	dataFlagsMask = transformFlags | file.FlagDetached
+)
+
+// dataDescriptor describes the detached data, stored in the raw data file.
+type dataDescriptor struct {
+	size int64
+	sum  []byte // SHA1 hash sum
+}
+
+// itemFileExt returns the item files extension for the pool layout.
+func (pool *Pool) itemFileExt() string {
+	if pool.splitLayout {
+		return metaFileExt
+	}
+
+	return cacheFileExt
+}
+
+// otherItemFileExt returns the item files extension for another layout (than the pool one).
+func (pool *Pool) otherItemFileExt() string {
+	if pool.splitLayout {
+		return cacheFileExt
+	}
+
+	return metaFileExt
+}
+
+// trimItemFileExt removes the item file extension (of any layout) from the name or path (false is returned, if the
+// extension is missing).
+func trimItemFileExt(name string) (string, bool) {
+	for _, ext := range [...]string{cacheFileExt, metaFileExt} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+
+	return name, false
+}
+
+// dataPath returns path to the raw data file for the item file path (item files of both layouts share it).
+func dataPath(itemFilePath string) string {
+	path, _ := trimItemFileExt(itemFilePath)
+
+	return path + dataFileExt
+}
+
+// dataTempPath returns path to the temporary raw data file for the item file path.
+func dataTempPath(itemFilePath string) string {
+	path, _ := trimItemFileExt(itemFilePath)
+
+	return path + dataTempFileExt
+}
+
+// dataFilePath returns path to the item raw data file (it exists for the items with detached data only).
+func (item *Item) dataFilePath() string { return dataPath(item.GetFilePath()) }
+
+// isDetached reports whether the item file data is detached.
+func isDetached(f *file.File) bool {
+	flags, err := f.GetFlags()
+
+	return err == nil && flags.Has(file.FlagDetached)
+}
+
+// relayout renames the existing item file of another layout (see Pool.itemFileName) into the item file name of the
+// pool layout before the value writing, so the written item files are named by the pool layout. Raw data file is shared
+// by the item files of both layouts, so it is not moved (and it must be removed after writing of the regular value -
+// true is returned, if the item file was renamed).
+func (item *Item) relayout() (bool, error) {
+	name := keyToFileName(item.key, item.pool.itemFileExt())
+	if name == item.fileName {
+		return false, nil
+	}
+
+	from, to := item.GetFilePath(), filepath.Join(item.pool.dirPath, name)
+
+	if err := item.pool.rename(from, to); err != nil && !os.IsNotExist(err) {
+		return false, writeError(fmt.Sprintf("cannot rename file [%s]", from), err)
+	}
+
+	item.fileName = name
+
+	return true, nil
+}
+
+// removeDataFile removes the item raw data file (it is used after the detached value replacing with the regular one).
+func (item *Item) removeDataFile() {
+	_ = item.pool.retry(func() error { return item.pool.backend.Remove(item.dataFilePath()) })
+}
+
+// writeDetached writes the value into the temporary raw data file and publishes it with the item file, written with
+// passed header values (see publishData), so the raw data file is never replaced before the item file is written.
+// Nothing is published, if the value does not fit the limit (see Item.writeLimited).
+func (item *Item) writeDetached(from io.Reader, h file.Header, fits func() error) error {
+	tmpPath, d, err := item.writeDataFile(from)
+	if err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", item.dataFilePath()), err)
+	}
+
+	if fits != nil {
+		err = fits()
+	}
+
+	if err == nil {
+		err = item.publishData(tmpPath, d, h)
+	}
+
+	if err != nil {
+		_ = item.pool.backend.Remove(tmpPath)
+
+		return err
+	}
+
+	return nil
+}
+
+// publishData writes the item file with the data descriptor into the staged file, and replaces the item raw data file
+// with the temporary one and the item file with the staged one. Replaced files are restored (and the temporary file
+// is left) on errors.
+func (item *Item) publishData(tmpPath string, d []byte, h file.Header) error {
+	var (
+		filePath = item.GetFilePath()
+		dataPath = item.dataFilePath()
+	)
+
+	h.Flags = h.Flags.Without(dataFlagsMask).With(file.FlagDetached)
+
+	if err := item.writeFile(filePath+stagedFileSuffix, bytes.NewReader(d), h); err != nil {
+		_ = item.pool.removeFile(filePath + stagedFileSuffix)
+
+		return err
+	}
+
+	if err := item.pool.rename(dataPath, dataPath+backupFileSuffix); err != nil && !os.IsNotExist(err) {
+		_ = item.pool.removeFile(filePath + stagedFileSuffix)
+
+		return writeError(fmt.Sprintf("cannot backup file [%s]", dataPath), err)
+	}
+
+	err := item.pool.rename(tmpPath, dataPath)
+	if err != nil {
+		err = writeError(fmt.Sprintf("cannot replace file [%s]", dataPath), err)
+	} else if err = item.pool.replaceWithStaged(item); err != nil {
+		_ = item.pool.rename(dataPath, tmpPath)
+	}
+
+	if err != nil {
+		_ = item.pool.rename(dataPath+backupFileSuffix, dataPath)
+		_ = item.pool.removeFile(filePath + stagedFileSuffix)
+
+		return err
+	}
+
+	_ = item.pool.removeFile(dataPath + backupFileSuffix)
+	_ = item.pool.removeFile(filePath + backupFileSuffix)
+	item.pool.fileChanged(item.key, filePath)
+
+	return nil
+}
+
+// setDetached writes the value (like Item.Set does) for the split layout: existing item file header values are kept
+// (unreadable item files are replaced), and the raw data file is published with the item file (see writeDetached).
+func (item *Item) setDetached(from io.Reader, fits func() error) error {
+	h, err := item.currentHeader()
+	if err != nil {
+		return err
+	}
+
+	h.Flags = h.Flags.Without(file.FlagImmutable | file.FlagChunked) // expired immutable value is replaced
+
+	return item.writeDetached(from, h, fits)
+}
+
+// writeDataFile writes the value into the temporary raw data file (raw data file is replaced with it on publishing, so
+// the data, published using hard links, is never changed in place), and returns the temporary file path with the
+// encoded data descriptor.
+func (item *Item) writeDataFile(from io.Reader) (string, []byte, error) {
+	var tmpPath = dataTempPath(item.GetFilePath())
+
+	f, err := item.pool.backend.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, item.filePerm())
+	if err != nil {
+		return "", nil, err
+	}
+
+	hashing := sha1.New() //nolint:gosec
+
+	n, err := io.Copy(io.MultiWriter(&handleWriter{h: f}, hashing), from)
+	if err == nil {
+		if s, ok := f.(interface{ Sync() error }); ok {
+			err = s.Sync()
+		}
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = item.pool.chmod(tmpPath, item.filePerm())
+	}
+
+	if err == nil {
+		err = item.pool.chown(tmpPath)
+	}
+
+	if err != nil {
+		_ = item.pool.backend.Remove(tmpPath)
+
+		return "", nil, err
+	}
+
+	if item.pool.deduplicated() {
+		item.pool.dedupData(tmpPath, n, hashing.Sum(nil))
+	}
+
+	return tmpPath, encodeDataDescriptor(n, hashing.Sum(nil)), nil
+}
+
+// encodeDataDescriptor encodes the detached data descriptor (it is stored as the item file data).
+func encodeDataDescriptor(size int64, sum []byte) []byte {
+	d := make([]byte, dataDescriptorSize)
+
+	binary.LittleEndian.PutUint64(d, uint64(size))
+	copy(d[8:], sum)
+
+	return d
+}
+
+// readDataDescriptor reads the detached data descriptor from the item file (its data is verified).
+func readDataDescriptor(f *file.File) (dataDescriptor, error) {
+	var buf bytes.Buffer
+
+	if err := f.GetData(&buf); err != nil {
+		return dataDescriptor{}, err
+	}
+
+	if buf.Len() != dataDescriptorSize {
+		return dataDescriptor{}, fmt.Errorf("%w: wrong data descriptor length %d", file.ErrDataCorrupted, buf.Len())
+	}
+
+	b := buf.Bytes()
+
+	return dataDescriptor{size: int64(binary.LittleEndian.Uint64(b)), sum: b[8:]}, nil
+}
+
+// openDataFile opens the raw data file of the item file with detached data and reads the data descriptor. Missing
+// (and truncated) raw data file is reported as corrupted data (file.ErrDataCorrupted).
+func (pool *Pool) openDataFile(f *file.File) (file.Handle, dataDescriptor, error) {
+	d, err := readDataDescriptor(f)
+	if err != nil {
+		return nil, d, err
+	}
+
+	h, err := pool.backend.OpenFile(dataPath(f.Name()), os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, d, fmt.Errorf("%w: data file does not exist", file.ErrDataCorrupted)
+		}
+
+		return nil, d, err
+	}
+
+	if info, statErr := h.Stat(); statErr != nil || info.Size() != d.size {
+		_ = h.Close()
+
+		if statErr != nil {
+			return nil, d, statErr
+		}
+
+		return nil, d, fmt.Errorf("%w: data file length mismatched", file.ErrDataCorrupted)
+	}
+
+	return h, d, nil
+}
+
+// detached returns the data reading function for the item file: detached data is read from the raw data file (and
+// verified using the data descriptor), and passed read function is returned for the regular item files.
+func (pool *Pool) detached(f *file.File, read func(io.Writer) error) func(io.Writer) error {
+	if !isDetached(f) {
+		return read
+	}
+
+	return func(out io.Writer) error {
+		h, d, err := pool.openDataFile(f)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = h.Close() }()
+
+		hashing := sha1.New() //nolint:gosec
+
+		if _, err = io.Copy(io.MultiWriter(out, hashing), io.NewSectionReader(h, 0, d.size)); err != nil {
+			return err
+		}
+
+		if !bytes.Equal(hashing.Sum(nil), d.sum) {
+			return file.ErrDataCorrupted
+		}
+
+		return nil
+	}
+}
+
+// dataSize returns the item file data size (detached data size is read from the data descriptor).
+func dataSize(f *file.File) (int64, error) {
+	if !isDetached(f) {
+		return f.DataSize()
+	}
+
+	d, err := readDataDescriptor(f)
+
+	return d.size, err
+}
+
+// dataSection returns the reader of the item file data section (raw data file is opened for the detached data, and it
+// must be closed after usage - nil is returned for the regular item files).
+func (pool *Pool) dataSection(f *file.File) (*io.SectionReader, file.Handle, error) {
+	if !isDetached(f) {
+		r, err := f.DataReader()
+
+		return r, nil, err
+	}
+
+	h, d, err := pool.openDataFile(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return io.NewSectionReader(h, 0, d.size), h, nil
+}
+
+// getDataRange writes length bytes of the item file data, starting from the data offset off, to the writer (see
+// file.GetDataRange). Detached data hash sum is NOT verified for the range.
+func (pool *Pool) getDataRange(f *file.File, out io.Writer, off, length int64) error {
+	if !isDetached(f) {
+		return f.GetDataRange(out, off, length)
+	}
+
+	r, h, err := pool.dataSection(f)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = h.Close() }()
+
+	if size := r.Size(); off < 0 || length < 0 || off+length > size {
+		return fmt.Errorf("%w: offset %d, length %d, data size %d", file.ErrOutOfRange, off, length, size)
+	}
+
+	_, err = io.Copy(out, io.NewSectionReader(r, off, length))
+
+	return err
+}
+
+// handleWriter writes into the file handle sequentially (handles are not required to implement io.Writer).
+type handleWriter struct {
+	h   file.Handle
+	off int64
+}
+
+// Write writes the data at the current offset.
+func (w *handleWriter) Write(p []byte) (int, error) {
+	n, err := w.h.WriteAt(p, w.off)
+	w.off += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,89 @@
+package filecache
+
+import (
+	"errors"
+	"io"
+)
+
+// TeeReader returns the reader, that reads the data from src and writes it into the item value at the same time (see
+// Set), e.g. for the proxy caches, where the upstream response is served and cached in a single pass. Item value is
+// complete, when the reader returns io.EOF (the header and data hash sum are written before it). Source reading error
+// aborts the value writing (partially written value is removed), and value writing errors do not break the reading -
+// they are passed to the pool error handler (see WithErrorHandler). Returned reader implements io.Closer: closing
+// before io.EOF aborts the value writing, so the reader must be read until io.EOF or closed (otherwise the item and
+// pool closing are blocked).
+func (item *Item) TeeReader(src io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+
+	t := &teeReader{item: item, src: src, pw: pw, done: make(chan struct{})}
+
+	go func() {
+		defer close(t.done)
+
+		t.err = item.Set(pr)
+		_ = pr.CloseWithError(io.ErrClosedPipe) // the rest of the data must not be written into the pipe
+	}()
+
+	return t
+}
+
+// teeReader writes the data, read from the source, into the pipe, that is read by the item value writer.
+type teeReader struct {
+	item     *Item
+	src      io.Reader
+	pw       *io.PipeWriter
+	done     chan struct{} // closed, when the value writing is finished
+	err      error         // value writing error (it can be read after the done closing only)
+	failed   bool          // value writing is failed (the rest of the data is not written)
+	finished bool          // value writing is finished or aborted
+}
+
+// Read implements io.Reader interface.
+func (t *teeReader) Read(p []byte) (int, error) {
+	n, err := t.src.Read(p)
+
+	if n > 0 && !t.failed && !t.finished {
+		if _, writeErr := t.pw.Write(p[:n]); writeErr != nil {
+			t.failed = true
+		}
+	}
+
+	switch {
+	case err == io.EOF:
+		t.finish(nil)
+
+	case err != nil:
+		t.finish(err)
+	}
+
+	return n, err
+}
+
+// Close aborts the value writing, if the source was not read until io.EOF.
+func (t *teeReader) Close() error {
+	t.finish(io.ErrClosedPipe)
+
+	return nil
+}
+
+// finish closes the pipe with passed error (nil means "the data end") and waits for the value writing finishing.
+func (t *teeReader) finish(err error) {
+	if t.finished {
+		return
+	}
+
+	t.finished = true
+
+	_ = t.pw.CloseWithError(err)
+	<-t.done
+
+	switch {
+	case err == nil:
+		t.item.pool.reportError(t.err)
+
+	case t.err != nil: // partially written value is removed (writing errors are caused by the source error)
+		if delErr := t.item.Delete(); !errors.Is(delErr, ErrNotFound) {
+			t.item.pool.reportError(delErr)
+		}
+	}
+}
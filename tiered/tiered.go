@@ -0,0 +1,291 @@
+// Package tiered provides the multi-tier cache pool: primary pool (e.g. in-memory, or local disk) is chained with the
+// secondary (e.g. local disk, or network) one. Misses fall through into the secondary pool, and found values are
+// backfilled into the primary pool (see WithBackfill).
+package tiered
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	filecache "github.com/tarampampam/go-filecache"
+)
+
+type (
+	// Pool is the multi-tier cache pool.
+	Pool struct {
+		primary, secondary filecache.CachePool
+		backfill           bool
+	}
+
+	// Option allows to set up the multi-tier cache pool.
+	Option func(*Pool)
+
+	// Item is the multi-tier cache item.
+	Item struct {
+		pool               *Pool
+		key                string
+		primary, secondary filecache.CacheItem
+	}
+)
+
+// Interfaces implementation checks.
+var (
+	_ filecache.CachePool = (*Pool)(nil)
+	_ filecache.CacheItem = (*Item)(nil)
+)
+
+// New creates the multi-tier cache pool. Values are written into both pools, and read from the primary pool first.
+func New(primary, secondary filecache.CachePool, opts ...Option) *Pool {
+	p := &Pool{primary: primary, secondary: secondary, backfill: true}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// WithBackfill enables (default) or disables the primary pool backfilling with the values, found in the secondary
+// pool only (read-repair), so hot values migrate into the faster tier.
+func WithBackfill(enabled bool) Option {
+	return func(p *Pool) { p.backfill = enabled }
+}
+
+// GetDirPath returns the primary pool directory path.
+func (p *Pool) GetDirPath() string { return p.primary.GetDirPath() }
+
+// GetItem returns a Cache Item representing the specified key.
+func (p *Pool) GetItem(key string) filecache.CacheItem { return p.item(key) }
+
+// item returns the multi-tier cache item.
+func (p *Pool) item(key string) *Item {
+	return &Item{pool: p, key: key, primary: p.primary.GetItem(key), secondary: p.secondary.GetItem(key)}
+}
+
+// HasItem confirms if any pool contains specified cache item.
+func (p *Pool) HasItem(key string) bool { return p.primary.HasItem(key) || p.secondary.HasItem(key) }
+
+// Clear deletes all items in both pools.
+func (p *Pool) Clear() (bool, error) {
+	ok1, err1 := p.primary.Clear()
+	ok2, err2 := p.secondary.Clear()
+
+	return ok1 && ok2, firstError(err1, err2)
+}
+
+// DeleteItem removes the item from both pools. Missing item in one of the pools is not an error.
+func (p *Pool) DeleteItem(key string) (bool, error) {
+	ok1, err1 := p.primary.DeleteItem(key)
+	ok2, err2 := p.secondary.DeleteItem(key)
+
+	if ok1 || ok2 {
+		return true, nil
+	}
+
+	return false, firstError(err1, err2)
+}
+
+// Put a cache item with expiring time into both pools (value is read into memory).
+func (p *Pool) Put(key string, from io.Reader, expiresAt time.Time) (filecache.CacheItem, error) {
+	return p.put(key, from, func(pool filecache.CachePool, r io.Reader) error {
+		_, err := pool.Put(key, r, expiresAt)
+		return err
+	})
+}
+
+// PutForever puts a cache item without expiring time into both pools (value is read into memory).
+func (p *Pool) PutForever(key string, from io.Reader) (filecache.CacheItem, error) {
+	return p.put(key, from, func(pool filecache.CachePool, r io.Reader) error {
+		_, err := pool.PutForever(key, r)
+		return err
+	})
+}
+
+// put writes the value into the secondary pool first, and then into the primary one.
+func (p *Pool) put(
+	key string, from io.Reader, put func(filecache.CachePool, io.Reader) error,
+) (filecache.CacheItem, error) {
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pool := range [...]filecache.CachePool{p.secondary, p.primary} {
+		if err := put(pool, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.GetItem(key), nil
+}
+
+// Close closes both pools.
+func (p *Pool) Close() error { return firstError(p.primary.Close(), p.secondary.Close()) }
+
+// Warm backfills the primary pool with the secondary pool items ahead of traffic (e.g. after the deployment), using
+// up to concurrency goroutines (zero or negative concurrency means 1). Items, that are present in the primary pool
+// already, are not copied, and missing (or expired) items are skipped. The number of items in the primary pool (of
+// the passed ones) and the first occurred error are returned. Warming is stopped, when the context is canceled.
+func (p *Pool) Warm(ctx context.Context, keys []string, concurrency int) (int, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		warmed   int
+		firstErr error
+		sem      = make(chan struct{}, concurrency)
+	)
+
+loop:
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+
+		go func(key string) {
+			defer func() { <-sem; wg.Done() }()
+
+			err := p.item(key).get(ioutil.Discard, true) // found values are backfilled
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				warmed++
+
+			case errors.Is(err, filecache.ErrNotFound), errors.Is(err, filecache.ErrExpired),
+				errors.Is(err, filecache.ErrInvalidated):
+				// missing items are skipped
+
+			case firstErr == nil:
+				firstErr = err
+			}
+		}(key)
+	}
+
+	wg.Wait()
+
+	return warmed, firstError(firstErr, ctx.Err())
+}
+
+// GetFilePath returns the primary pool item file path.
+func (i *Item) GetFilePath() string { return i.primary.GetFilePath() }
+
+// GetKey returns the key for the current cache item.
+func (i *Item) GetKey() string { return i.key }
+
+// Get retrieves the value from the primary pool, or from the secondary one (found value is backfilled into the primary
+// pool with the same expiration time, if backfilling is enabled).
+func (i *Item) Get(to io.Writer) error { return i.get(to, i.pool.backfill) }
+
+// get retrieves the value, backfilling the primary pool on demand.
+func (i *Item) get(to io.Writer, backfill bool) error {
+	if i.primary.IsHit() {
+		if err := i.primary.Get(to); err == nil {
+			return nil
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := i.secondary.Get(buf); err != nil {
+		return err
+	}
+
+	var data = buf.Bytes()
+
+	if backfill {
+		if exp := i.secondary.ExpiresAt(); exp != nil {
+			_, _ = i.pool.primary.Put(i.key, bytes.NewReader(data), *exp)
+		} else {
+			_, _ = i.pool.primary.PutForever(i.key, bytes.NewReader(data))
+		}
+	}
+
+	_, err := to.Write(data)
+
+	return err
+}
+
+// IsHit confirms if any pool contains the cache item.
+func (i *Item) IsHit() bool { return i.primary.IsHit() || i.secondary.IsHit() }
+
+// Set sets the value in both pools (value is read into memory).
+func (i *Item) Set(from io.Reader) error {
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return err
+	}
+
+	if err := i.secondary.Set(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	return i.primary.Set(bytes.NewReader(data))
+}
+
+// ExpiresAt returns the expiration time from the primary pool (or from the secondary one, on the primary pool miss).
+func (i *Item) ExpiresAt() *time.Time {
+	if i.primary.IsHit() {
+		return i.primary.ExpiresAt()
+	}
+
+	return i.secondary.ExpiresAt()
+}
+
+// SetExpiresAt sets the expiration time in both pools (only in pools, that contain the item).
+func (i *Item) SetExpiresAt(when time.Time) error {
+	for _, item := range [...]filecache.CacheItem{i.secondary, i.primary} {
+		if item.IsHit() {
+			if err := item.SetExpiresAt(when); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the item from both pools. Missing item in one of the pools is not an error.
+func (i *Item) Delete() error {
+	err1, err2 := i.primary.Delete(), i.secondary.Delete()
+	if err1 == nil || err2 == nil {
+		return nil
+	}
+
+	return firstError(err1, err2)
+}
+
+// Size returns the value size from the primary pool (or from the secondary one, on the primary pool miss).
+func (i *Item) Size() (int64, error) {
+	if i.primary.IsHit() {
+		return i.primary.Size()
+	}
+
+	return i.secondary.Size()
+}
+
+// firstError returns the first non-nil error.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
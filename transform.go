@@ -0,0 +1,220 @@
+package filecache
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// transformFlags are the header flags, that can mark the transformed values.
+const transformFlags = file.TransformedFlags
+
+// Transformer transforms the values on writing (e.g. compresses or encrypts them) and reverses the transformation on
+// reading (see WithTransformers).
+type Transformer interface {
+	// Flag returns the header flag, that marks the values, transformed by this transformer (file.FlagCompressed,
+	// file.FlagEncrypted or file.FlagCustom).
+	Flag() file.Flags
+
+	// Encode returns the writer, that transforms the data and writes it into w. Writer is closed at the data end (it
+	// must not close w).
+	Encode(w io.Writer) (io.WriteCloser, error)
+
+	// Decode returns the reader of the original data, that reads the transformed data from r.
+	Decode(r io.Reader) (io.Reader, error)
+}
+
+// GzipTransformer compresses the values using gzip format with passed compression level (zero means
+// gzip.DefaultCompression).
+type GzipTransformer struct{ Level int }
+
+var _ Transformer = GzipTransformer{}
+
+// Flag returns file.FlagCompressed.
+func (GzipTransformer) Flag() file.Flags { return file.FlagCompressed }
+
+// Encode returns the gzip writer.
+func (t GzipTransformer) Encode(w io.Writer) (io.WriteCloser, error) {
+	if t.Level == 0 {
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	}
+
+	return gzip.NewWriterLevel(w, t.Level)
+}
+
+// Decode returns the gzip reader.
+func (GzipTransformer) Decode(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+
+// encode returns the reader of the data, transformed by the pool transformers, and the flags of applied transformers.
+// Returned function must be called after the reading (transforming is stopped, if the data was not read completely).
+func (pool *Pool) encode(from io.Reader) (io.Reader, file.Flags, func()) {
+	if len(pool.transformers) == 0 {
+		return from, 0, func() {}
+	}
+
+	var flags file.Flags
+
+	for _, t := range pool.transformers {
+		flags = flags.With(t.Flag())
+	}
+
+	var (
+		pr, pw = io.Pipe()
+		done   = make(chan struct{})
+	)
+
+	go func() {
+		defer close(done)
+
+		_ = pw.CloseWithError(pool.encodeTo(pw, from))
+	}()
+
+	return pr, flags, func() {
+		_ = pr.CloseWithError(io.ErrClosedPipe)
+		<-done
+	}
+}
+
+// writeValue writes the value, transformed by the pool transformers, into the item file with passed header values
+// (see Item.writeFile). The original length of the transformed value is stored in the item file (see Item.Size).
+func (item *Item) writeValue(filePath string, from io.Reader, h file.Header) error {
+	var counter = &countingReader{r: from}
+
+	data, dataFlags, stop := item.pool.encode(counter)
+	defer stop()
+
+	h.Flags, h.DecodedLength = h.Flags.Without(dataFlagsMask).With(dataFlags), -1 // length is unknown until written
+
+	if err := item.writeFile(filePath, data, h); err != nil || dataFlags&transformFlags == 0 {
+		return err
+	}
+
+	f, err := item.pool.openFile(filePath, os.O_RDWR, item.filePerm())
+	if err != nil {
+		return writeError(fmt.Sprintf("cannot open file [%s]", filePath), err)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if err = f.SetDecodedLength(counter.n); err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", filePath), err)
+	}
+
+	return nil
+}
+
+// encodeTo transforms the data using the pool transformers (in the writing order) and writes it into w.
+func (pool *Pool) encodeTo(w io.Writer, from io.Reader) error {
+	var encoders = make([]io.WriteCloser, len(pool.transformers))
+
+	for i := len(pool.transformers) - 1; i >= 0; i-- { // the first transformer receives the original data
+		enc, err := pool.transformers[i].Encode(w)
+		if err != nil {
+			return err
+		}
+
+		encoders[i], w = enc, enc
+	}
+
+	if _, err := io.Copy(w, from); err != nil {
+		return err
+	}
+
+	for _, enc := range encoders { // transformed data is flushed into the next transformer
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decode returns the reader of the original data, reversing the transformations, marked by passed flags.
+func (pool *Pool) decode(r io.Reader, flags file.Flags) (io.Reader, error) {
+	for i := len(pool.transformers) - 1; i >= 0; i-- {
+		if t := pool.transformers[i]; flags.Has(t.Flag()) {
+			var err error
+
+			if r, err = t.Decode(r); err != nil {
+				return nil, err
+			}
+
+			flags = flags.Without(t.Flag())
+		}
+	}
+
+	if unknown := flags & transformFlags; unknown != 0 {
+		return nil, fmt.Errorf("data is transformed by unknown transformer (%s)", unknown)
+	}
+
+	return r, nil
+}
+
+// decodableBy reports whether the data, transformed by the pool transformers and marked by passed flags, is decoded
+// by the destination pool in the same way (raw data can be cloned or linked into the destination pool, and it must be
+// streamed otherwise).
+func (pool *Pool) decodableBy(dst *Pool, flags file.Flags) bool {
+	return flags&transformFlags == 0 || pool == dst || reflect.DeepEqual(pool.transformers, dst.transformers)
+}
+
+// decoding returns the data reading function, that reverses the transformations, marked by passed flags, of the data,
+// read by passed function. Data reading errors (e.g. hash sum mismatch) are preferred over the decoding errors.
+func (pool *Pool) decoding(read func(io.Writer) error, flags file.Flags) func(io.Writer) error {
+	return func(out io.Writer) error {
+		var (
+			pr, pw  = io.Pipe()
+			readErr = make(chan error, 1)
+			w       = &trackingWriter{w: out}
+		)
+
+		go func() {
+			err := read(pw)
+			_ = pw.CloseWithError(err)
+			readErr <- err
+		}()
+
+		r, err := pool.decode(pr, flags)
+		if err == nil {
+			_, err = io.Copy(w, r)
+		}
+
+		if w.err == nil { // the rest of the data must be read for the hash sum verification
+			_, _ = io.Copy(ioutil.Discard, pr)
+		}
+
+		_ = pr.CloseWithError(io.ErrClosedPipe)
+
+		if rErr := <-readErr; rErr != nil && !errors.Is(rErr, io.ErrClosedPipe) {
+			return rErr
+		}
+
+		return err
+	}
+}
+
+// trackingWriter remembers the writing error.
+type trackingWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write implements io.Writer interface.
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil {
+		t.err = err
+	}
+
+	return n, err
+}
+
+// decodedReader is the reader of the decoded data, that closes the stored data reader.
+type decodedReader struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,51 @@
+package filecache
+
+import (
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Update opens (or creates, for missing items) the cache item file once, and passes it into the callback, so several
+// header and data operations can be done without reopening of the file and without interleaving with another
+// operations on the same item. Callback error is returned as is. File must not be used after the callback returns.
+func (item *Item) Update(fn func(f *file.File) error) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	return item.update(fn)
+}
+
+// update opens (or creates) the item file for reading and writing, calls fn and closes the file. Pool index and
+// metadata are notified about the item changes on success.
+func (item *Item) update(fn func(f *file.File) error) error {
+	var filePath = item.GetFilePath()
+
+	f, err := item.openOrCreateFile(filePath, item.filePerm())
+	if err != nil {
+		return err
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if err := fn(f); err != nil {
+		return err
+	}
+
+	item.pool.fileChanged(item.key, filePath)
+
+	return nil
+}
+
+// view opens the item file for reading, calls fn and closes the file (opening error is returned as is).
+func (item *Item) view(fn func(f *file.File) error) error {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		return openErr
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	return fn(f)
+}
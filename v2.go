@@ -0,0 +1,63 @@
+package filecache
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+type (
+	// PoolV2 is the pool view, that implements CachePoolV2 interface (see Pool.V2). All the pool methods, except the
+	// cache items returning ones, are available as is.
+	PoolV2 struct{ *Pool }
+
+	// ItemV2 is the cache item, that implements CacheItemV2 interface.
+	ItemV2 struct{ *Item }
+)
+
+var (
+	_ CachePoolV2 = (*PoolV2)(nil)
+	_ CacheItemV2 = (*ItemV2)(nil)
+)
+
+// V2 returns the pool view, that reports cache misses separately from the errors (see CachePoolV2). The view shares
+// the pool state, so it must not be closed separately.
+func (pool *Pool) V2() *PoolV2 { return &PoolV2{Pool: pool} }
+
+// GetItem returns a Cache Item representing the specified key.
+func (pool *PoolV2) GetItem(key string) CacheItemV2 {
+	return &ItemV2{Item: pool.Pool.GetItem(key).(*Item)}
+}
+
+// Put a cache item with expiring time.
+func (pool *PoolV2) Put(key string, from io.Reader, expiresAt time.Time) (CacheItemV2, error) {
+	return pool.itemV2(pool.Pool.Put(key, from, expiresAt))
+}
+
+// Put a cache item without expiring time.
+func (pool *PoolV2) PutForever(key string, from io.Reader) (CacheItemV2, error) {
+	return pool.itemV2(pool.Pool.PutForever(key, from))
+}
+
+// itemV2 wraps the written cache item (item and error are returned as is).
+func (pool *PoolV2) itemV2(item CacheItem, err error) (CacheItemV2, error) {
+	if item == nil {
+		return nil, err
+	}
+
+	return &ItemV2{Item: item.(*Item)}, err
+}
+
+// Get retrieves the value of the item from the cache. Missing, expired and invalidated items are not an error - false
+// will be returned (and nothing is written).
+func (item *ItemV2) Get(to io.Writer) (bool, error) {
+	if err := item.Item.Get(to); err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrExpired) || errors.Is(err, ErrInvalidated) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
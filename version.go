@@ -0,0 +1,96 @@
+package filecache
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Version is the cache item version (ETag), changed on every value writing. Versions of the item are monotonic, and
+// they are based on the time, so recreated (after deleting) items do not reuse old versions. Zero version means "item
+// does not exist" (or it was written without versioning).
+type Version uint64
+
+// nextVersion returns the version for the item, that is written over the item with passed version (versions are based
+// on the pool clock time, see WithClock).
+func (pool *Pool) nextVersion(prev Version) Version {
+	if now := Version(pool.now().UnixNano()); now > prev {
+		return now
+	}
+
+	return prev + 1
+}
+
+// Version returns the cache item version (zero, if the item does not exist).
+func (item *Item) Version() (Version, error) {
+	if !item.pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	return item.version()
+}
+
+func (item *Item) version() (Version, error) {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		if os.IsNotExist(openErr) {
+			return 0, nil
+		}
+
+		return 0, item.openError(openErr)
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	v, err := f.GetVersion()
+	if err != nil {
+		return 0, newError(ErrFileReading, fmt.Sprintf("cannot read file [%s] version", item.GetFilePath()), err)
+	}
+
+	return Version(v), nil
+}
+
+// setVersion stamps the opened item file with the next version.
+func (item *Item) setVersion(f *file.File) error {
+	v, err := f.GetVersion()
+	if err == nil {
+		err = f.SetVersion(uint64(item.pool.nextVersion(Version(v))))
+	}
+
+	if err != nil {
+		return writeError(fmt.Sprintf("cannot write into file [%s]", f.Name()), err)
+	}
+
+	return nil
+}
+
+// SetIfVersion sets the value (like Set), if the current item version matches the expected version (zero expected
+// version means "item must not exist"), so concurrent writers can use optimistic concurrency instead of "last writer
+// wins". ErrVersionMismatch error is returned on mismatch.
+func (item *Item) SetIfVersion(from io.Reader, expected Version) error {
+	if !item.pool.acquire() {
+		return errPoolClosed()
+	}
+	defer item.pool.release()
+
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+
+	current, err := item.version()
+	if err != nil {
+		return err
+	}
+
+	if current != expected {
+		return newError(ErrVersionMismatch, fmt.Sprintf(
+			"file [%s] version is %d (expected %d)", item.GetFilePath(), current, expected,
+		), nil)
+	}
+
+	return item.set(from)
+}
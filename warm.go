@@ -0,0 +1,92 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// Warm reads the cache items values ahead of traffic (e.g. after the deployment), so item files get into the operating
+// system page cache (and read handles - into the handle cache, see WithHandleCache). Items are read using up to
+// concurrency goroutines (zero or negative concurrency means the walk concurrency, see WithWalkConcurrency), and data
+// is verified, so corrupted items are handled by the corruption policy. Missing, expired and invalidated items are
+// skipped, and access statistics are not updated. The number of warmed items and the first occurred error are returned.
+// Warming is stopped, when the context is canceled.
+func (pool *Pool) Warm(ctx context.Context, keys []string, concurrency int) (int, error) {
+	if !pool.acquire() {
+		return 0, errPoolClosed()
+	}
+	defer pool.release()
+
+	if concurrency <= 0 {
+		concurrency = pool.walkConcurrency
+	}
+
+	var warmed int64
+
+	err := parallel(ctx, concurrency, len(keys), func(i int) error {
+		item := newItem(pool, keys[i])
+
+		item.mutex.Lock()
+		defer item.mutex.Unlock()
+
+		ok, err := item.warm()
+		if ok {
+			atomic.AddInt64(&warmed, 1)
+		}
+
+		return err
+	})
+
+	return int(warmed), err
+}
+
+// WarmPattern warms the cache items with keys, matched with the pattern (see Pool.Keys and Pool.Warm).
+func (pool *Pool) WarmPattern(ctx context.Context, pattern string, concurrency int) (int, error) {
+	match, err := keysMatcher(pattern)
+	if err != nil {
+		return 0, newError(ErrUnknown, "wrong keys pattern", err)
+	}
+
+	keys, err := pool.keys(ctx, match)
+	if err != nil {
+		return 0, err
+	}
+
+	return pool.Warm(ctx, keys, concurrency)
+}
+
+// warm reads the item value without copying (false is returned for missing and unreadable items).
+func (item *Item) warm() (bool, error) {
+	f, openErr := item.openRead()
+	if openErr != nil {
+		if err := item.openError(openErr); !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+
+		return false, nil
+	}
+	defer func(f *file.File) { _ = f.Close() }(f)
+
+	if item.readable(f) != nil {
+		return false, nil
+	}
+
+	if err := item.pool.detached(f, f.GetData)(ioutil.Discard); err != nil {
+		if errors.Is(err, file.ErrDataCorrupted) {
+			_ = f.Close() // file must be closed before removing
+
+			return false, item.onCorruption(
+				newError(ErrCorrupted, fmt.Sprintf("file [%s] data is corrupted", item.GetFilePath()), err),
+			)
+		}
+
+		return false, newError(ErrFileReading, fmt.Sprintf("file [%s] read error", item.GetFilePath()), err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package filecache
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+type (
+	// FileEvent is the cache directory file change event.
+	FileEvent struct {
+		Path    string
+		Removed bool // file was removed (otherwise - created or rewritten)
+	}
+
+	// FileWatcher watches the cache directory files changes, made by any process (e.g. when the directory is shared
+	// over NFS or k8s volume). Implementation over fsnotify (or another notifications API) should implement this
+	// interface.
+	FileWatcher interface {
+		// Watch starts the directory watching and calls fn for every file change. Returned function stops watching.
+		Watch(dirPath string, fn func(e FileEvent)) (stop func(), err error)
+	}
+
+	// pollingWatcher is FileWatcher implementation, that compares directory snapshots periodically.
+	pollingWatcher struct {
+		interval time.Duration
+	}
+
+	// fileState is the file state in the directory snapshot.
+	fileState struct {
+		size    int64
+		modTime time.Time
+	}
+)
+
+// defaultPollingInterval is the default polling watcher interval.
+const defaultPollingInterval = time.Second
+
+// NewPollingWatcher creates the watcher, that scans the directory with passed interval (zero or negative interval
+// means "default interval", 1 second). It works on any filesystem, including network ones, where notifications are
+// usually not available.
+func NewPollingWatcher(interval time.Duration) FileWatcher {
+	if interval <= 0 {
+		interval = defaultPollingInterval
+	}
+
+	return &pollingWatcher{interval: interval}
+}
+
+// Watch starts the directory scanning. Files, existing on the watching start, are not reported.
+func (w *pollingWatcher) Watch(dirPath string, fn func(e FileEvent)) (func(), error) {
+	prev, err := scanDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var stop, stopped = make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+
+			case <-ticker.C:
+				next, scanErr := scanDir(dirPath)
+				if scanErr != nil {
+					continue // directory can be temporary unavailable
+				}
+
+				for name, state := range next {
+					if was, exists := prev[name]; !exists || was != state {
+						fn(FileEvent{Path: filepath.Join(dirPath, name)})
+					}
+				}
+
+				for name := range prev {
+					if _, exists := next[name]; !exists {
+						fn(FileEvent{Path: filepath.Join(dirPath, name), Removed: true})
+					}
+				}
+
+				prev = next
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}, nil
+}
+
+// scanDir returns the directory regular files snapshot.
+func scanDir(dirPath string) (map[string]fileState, error) {
+	infos, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]fileState, len(infos))
+
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			snapshot[info.Name()] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// watch starts the cache directory watching (watching error disables external changes tracking).
+func (pool *Pool) watch() {
+	if pool.watcher == nil {
+		return
+	}
+
+	if stop, err := pool.watcher.Watch(pool.dirPath, pool.onFileEvent); err == nil {
+		pool.unwatch = stop
+	}
+}
+
+// onFileEvent updates the pool index and metadata for the changed item file, and calls the watching callback (another
+// files changes are ignored).
+func (pool *Pool) onFileEvent(e FileEvent) {
+	if !isItemFileName(filepath.Base(e.Path)) || !pool.acquire() {
+		return
+	}
+	defer pool.release()
+
+	if e.Removed {
+		pool.fileRemoved(e.Path)
+	} else {
+		pool.fileChanged("", e.Path)
+	}
+
+	if pool.watchCallback != nil {
+		pool.watchCallback(e)
+	}
+}
@@ -0,0 +1,98 @@
+package filecache
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tarampampam/go-filecache/file"
+)
+
+// lockFileSuffix is the suffix of the cache file write lock files (see WithWriteLocks).
+const lockFileSuffix = ".lock"
+
+type (
+	// lockedHandle is the cache file, opened for writing under the write lock (lock is released on closing).
+	lockedHandle struct {
+		file.Handle
+		unlock func()
+	}
+
+	// poolFS is the pool files opening filesystem, so entries, written by the file package functions, are written
+	// under the write locks too (and failed opening is retried - see Pool.retry).
+	poolFS struct{ pool *Pool }
+)
+
+// Close closes the file and releases the write lock.
+func (h *lockedHandle) Close() error {
+	err := h.Handle.Close()
+	h.unlock()
+
+	return err
+}
+
+// OpenFile opens the file using the pool openHandle method.
+func (fs poolFS) OpenFile(name string, flag int, perm os.FileMode) (h file.Handle, err error) {
+	err = fs.pool.retry(func() (openErr error) {
+		h, openErr = fs.pool.openHandle(name, flag, perm)
+		return
+	})
+
+	return
+}
+
+// openHandle opens the file using the pool backend. Files, opened for writing, are locked for another processes, when
+// write locks are enabled (see WithWriteLocks).
+func (pool *Pool) openHandle(path string, flag int, perm os.FileMode) (file.Handle, error) {
+	if pool.writeLockStale <= 0 || flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return pool.backend.OpenFile(path, flag, perm)
+	}
+
+	unlock, err := pool.writeLock(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := pool.backend.OpenFile(path, flag, perm)
+	if err != nil {
+		unlock()
+
+		return nil, err
+	}
+
+	return &lockedHandle{Handle: h, unlock: unlock}, nil
+}
+
+// writeLock creates the lock file for the cache file (exclusive creation fails, when the lock is held by another
+// process) and returns the function for the lock releasing. Lock files, older than the stale timeout, are left by the
+// crashed processes, so they are removed.
+func (pool *Pool) writeLock(path string) (func(), error) {
+	var lockPath = path + lockFileSuffix
+
+	for attempt := 0; ; attempt++ {
+		f, err := pool.backend.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, pool.filePerm)
+		if err == nil {
+			_ = f.Close()
+
+			return func() { _ = pool.backend.Remove(lockPath) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, writeError(fmt.Sprintf("cannot lock file [%s]", path), err)
+		}
+
+		if attempt == 0 { // lock can be released (or broken) once
+			switch info, statErr := pool.backend.Stat(lockPath); {
+			case os.IsNotExist(statErr): // released right now
+				continue
+
+			case statErr == nil && time.Since(info.ModTime()) > pool.writeLockStale:
+				_ = pool.backend.Remove(lockPath)
+
+				continue
+			}
+		}
+
+		return nil, newError(ErrConcurrentWrite, fmt.Sprintf("file [%s] is written by another process", path), nil)
+	}
+}